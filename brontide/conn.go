@@ -0,0 +1,363 @@
+package brontide
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// HandshakeTimeout bounds how long Client and Server wait for the handshake
+// to complete.
+var HandshakeTimeout = 5 * time.Second
+
+// Conn is an encrypted, authenticated net.Conn speaking brontide.
+//
+// Conns from Listener.Accept complete the handshake on the first Read or
+// Write, or an explicit call to Handshake, like tls.Conn.
+type Conn struct {
+	conn  net.Conn
+	noise *Machine
+
+	handshakeMu   sync.Mutex
+	handshakeDone bool
+	handshakeErr  error
+
+	// Deadlines set before the handshake, restored once it is done.
+	deadlineMu    sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	readMu  sync.Mutex
+	readBuf []byte
+
+	writeMu sync.Mutex
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+// Client performs the initiator side of the handshake over conn.
+func Client(conn net.Conn, localStatic *secp256k1.PrivateKey, remoteStatic *secp256k1.PublicKey) (*Conn, error) {
+	c := &Conn{
+		conn:  conn,
+		noise: NewMachine(true, localStatic, remoteStatic),
+	}
+
+	if err := c.Handshake(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Server performs the responder side of the handshake over conn.
+func Server(conn net.Conn, localStatic *secp256k1.PrivateKey) (*Conn, error) {
+	c := &Conn{
+		conn:  conn,
+		noise: NewMachine(false, localStatic, nil),
+	}
+
+	if err := c.Handshake(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Dial connects to addr over TCP and performs the handshake.
+func Dial(addr string, localStatic *secp256k1.PrivateKey, remoteStatic *secp256k1.PublicKey) (*Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, HandshakeTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Client(conn, localStatic, remoteStatic)
+}
+
+// Handshake runs the handshake if it has not run yet. It returns the
+// handshake's error on every call, and the connection is closed if the
+// handshake failed.
+func (c *Conn) Handshake() error {
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+
+	if !c.handshakeDone {
+		c.handshakeDone = true
+
+		if c.handshakeErr = c.handshake(); c.handshakeErr != nil {
+			c.conn.Close()
+		}
+	}
+
+	return c.handshakeErr
+}
+
+func (c *Conn) handshake() error {
+	c.deadlineMu.Lock()
+	readDeadline, writeDeadline := c.readDeadline, c.writeDeadline
+	c.deadlineMu.Unlock()
+
+	deadline := earliest(readDeadline, writeDeadline)
+
+	if HandshakeTimeout > 0 {
+		deadline = earliest(deadline, time.Now().Add(HandshakeTimeout))
+	}
+
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	var err error
+
+	if c.noise.initiator {
+		err = c.initiatorHandshake()
+	} else {
+		err = c.responderHandshake()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	if err = c.conn.SetReadDeadline(c.readDeadline); err != nil {
+		return err
+	}
+
+	return c.conn.SetWriteDeadline(c.writeDeadline)
+}
+
+// earliest returns the earlier of two deadlines, where zero means none.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
+
+func (c *Conn) initiatorHandshake() error {
+	actOne, err := c.noise.GenActOne()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.conn.Write(actOne[:]); err != nil {
+		return err
+	}
+
+	var actTwo [ActTwoSize]byte
+
+	if _, err = io.ReadFull(c.conn, actTwo[:]); err != nil {
+		return err
+	}
+
+	if err = c.noise.RecvActTwo(actTwo); err != nil {
+		return err
+	}
+
+	actThree, err := c.noise.GenActThree()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = c.conn.Write(actThree[:])
+
+	return err
+}
+
+func (c *Conn) responderHandshake() error {
+	var actOne [ActOneSize]byte
+
+	if _, err := io.ReadFull(c.conn, actOne[:]); err != nil {
+		return err
+	}
+
+	if err := c.noise.RecvActOne(actOne); err != nil {
+		return err
+	}
+
+	actTwo, err := c.noise.GenActTwo()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.conn.Write(actTwo[:]); err != nil {
+		return err
+	}
+
+	var actThree [ActThreeSize]byte
+
+	if _, err = io.ReadFull(c.conn, actThree[:]); err != nil {
+		return err
+	}
+
+	return c.noise.RecvActThree(actThree)
+}
+
+// RemotePub returns the authenticated static key of the peer, running
+// the handshake first if needed. It is nil if the handshake failed.
+func (c *Conn) RemotePub() *secp256k1.PublicKey {
+	if c.Handshake() != nil {
+		return nil
+	}
+
+	return c.noise.RemoteStatic()
+}
+
+// Read reads decrypted bytes, pulling in a new frame when the previous one
+// has been consumed.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for len(c.readBuf) == 0 {
+		msg, err := c.noise.ReadMessage(c.conn)
+
+		if err != nil {
+			return 0, err
+		}
+
+		c.readBuf = msg
+	}
+
+	n := copy(b, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+
+	return n, nil
+}
+
+// ReadMessage reads exactly one frame. It must not be mixed with Read.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	if err := c.Handshake(); err != nil {
+		return nil, err
+	}
+
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	return c.noise.ReadMessage(c.conn)
+}
+
+// Write encrypts b, splitting it into frames of at most MaxMessage bytes.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	total := 0
+
+	for total < len(b) {
+		end := total + MaxMessage
+
+		if end > len(b) {
+			end = len(b)
+		}
+
+		if err := c.noise.WriteMessage(c.conn, b[total:end]); err != nil {
+			return total, err
+		}
+
+		total = end
+	}
+
+	return total, nil
+}
+
+// WriteMessage writes b as a single frame.
+func (c *Conn) WriteMessage(b []byte) error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.noise.WriteMessage(c.conn, b)
+}
+
+func (c *Conn) Close() error         { return c.conn.Close() }
+func (c *Conn) LocalAddr() net.Addr  { return c.conn.LocalAddr() }
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return c.SetWriteDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	c.readDeadline = t
+
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	c.writeDeadline = t
+
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Listener accepts brontide connections. Handshakes run on the accepted
+// Conns, so a slow or broken client never blocks Accept.
+type Listener struct {
+	net.Listener
+
+	localStatic *secp256k1.PrivateKey
+}
+
+// Listen announces on the local TCP address.
+func Listen(addr string, localStatic *secp256k1.PrivateKey) (*Listener, error) {
+	l, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListener(l, localStatic), nil
+}
+
+func NewListener(l net.Listener, localStatic *secp256k1.PrivateKey) *Listener {
+	return &Listener{
+		Listener:    l,
+		localStatic: localStatic,
+	}
+}
+
+// Accept waits for the next connection. Its responder handshake runs on
+// the first Read or Write, or on Handshake, and errors are returned there.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		conn:  conn,
+		noise: NewMachine(false, l.localStatic, nil),
+	}, nil
+}
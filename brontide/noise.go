@@ -0,0 +1,416 @@
+package brontide
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	ProtocolName = "Noise_XK_secp256k1_ChaChaPoly_SHA256"
+	Prologue     = "hns"
+
+	// RotationInterval is the number of messages encrypted with a key
+	// before it is rotated.
+	RotationInterval = 1000
+
+	PubKeySize = 33
+	MacSize    = 16
+
+	// LengthSize is the size of the encrypted length prefix (without MAC).
+	LengthSize = 4
+	HeaderSize = LengthSize + MacSize
+
+	ActOneSize   = 1 + PubKeySize + MacSize
+	ActTwoSize   = 1 + PubKeySize + MacSize
+	ActThreeSize = 1 + PubKeySize + MacSize + MacSize
+
+	// MaxMessage is the largest plaintext accepted in a single frame.
+	MaxMessage = 8*1000*1000 + 9
+
+	handshakeVersion = 0
+)
+
+var (
+	ErrMaxMessage   = errors.New("message too large")
+	ErrActVersion   = errors.New("invalid handshake version")
+	ErrActSize      = errors.New("invalid handshake act size")
+	ErrNotConnected = errors.New("handshake not complete")
+)
+
+// cipherState encrypts and decrypts with a single key and a running nonce,
+// rotating the key every RotationInterval messages when salt is set.
+type cipherState struct {
+	nonce  uint64
+	key    [32]byte
+	salt   [32]byte
+	cipher cipher.AEAD
+}
+
+func (c *cipherState) init(key [32]byte) {
+	c.key = key
+	c.nonce = 0
+	// Key size is always correct, error is impossible.
+	c.cipher, _ = chacha20poly1305.New(c.key[:])
+}
+
+func (c *cipherState) initSalt(key, salt [32]byte) {
+	c.salt = salt
+	c.init(key)
+}
+
+func (c *cipherState) iv() []byte {
+	var nonce [12]byte
+	binary.LittleEndian.PutUint64(nonce[4:], c.nonce)
+	return nonce[:]
+}
+
+func (c *cipherState) rotate() {
+	if c.nonce != RotationInterval {
+		return
+	}
+
+	salt, key := expand(c.salt[:], c.key[:])
+	c.initSalt(key, salt)
+}
+
+func (c *cipherState) encrypt(dst, ad, plaintext []byte) []byte {
+	out := c.cipher.Seal(dst, c.iv(), plaintext, ad)
+	c.nonce++
+	c.rotate()
+	return out
+}
+
+func (c *cipherState) decrypt(dst, ad, ciphertext []byte) ([]byte, error) {
+	out, err := c.cipher.Open(dst, c.iv(), ciphertext, ad)
+	c.nonce++
+	c.rotate()
+	return out, err
+}
+
+type symmetricState struct {
+	cipherState
+
+	chain  [32]byte
+	temp   [32]byte
+	digest [32]byte
+}
+
+func (s *symmetricState) initSymmetric(protocol []byte) {
+	var empty [32]byte
+
+	s.digest = sha256.Sum256(protocol)
+	s.chain = s.digest
+	s.init(empty)
+}
+
+func (s *symmetricState) mixKey(input []byte) {
+	s.chain, s.temp = expand(s.chain[:], input)
+	s.init(s.temp)
+}
+
+func (s *symmetricState) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(s.digest[:])
+	h.Write(data)
+	copy(s.digest[:], h.Sum(nil))
+}
+
+func (s *symmetricState) encryptAndHash(plaintext []byte) []byte {
+	ciphertext := s.encrypt(nil, s.digest[:], plaintext)
+	s.mixHash(ciphertext)
+	return ciphertext
+}
+
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := s.decrypt(nil, s.digest[:], ciphertext)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mixHash(ciphertext)
+
+	return plaintext, nil
+}
+
+// Machine is the brontide state machine. It performs the three act Noise_XK
+// handshake and afterwards encrypts and frames messages.
+type Machine struct {
+	symmetricState
+
+	initiator bool
+
+	localStatic     *secp256k1.PrivateKey
+	localEphemeral  *secp256k1.PrivateKey
+	remoteStatic    *secp256k1.PublicKey
+	remoteEphemeral *secp256k1.PublicKey
+
+	sendCipher cipherState
+	recvCipher cipherState
+
+	// genKey generates ephemeral keys, tests override it.
+	genKey func() (*secp256k1.PrivateKey, error)
+}
+
+// NewMachine creates a handshake state. The initiator must know the remote
+// static key, the responder learns it in act three.
+func NewMachine(initiator bool, localStatic *secp256k1.PrivateKey, remoteStatic *secp256k1.PublicKey) *Machine {
+	return newMachine(initiator, localStatic, remoteStatic, Prologue)
+}
+
+func newMachine(initiator bool, localStatic *secp256k1.PrivateKey, remoteStatic *secp256k1.PublicKey, prologue string) *Machine {
+	m := &Machine{
+		initiator:    initiator,
+		localStatic:  localStatic,
+		remoteStatic: remoteStatic,
+		genKey:       secp256k1.GeneratePrivateKey,
+	}
+
+	m.initSymmetric([]byte(ProtocolName))
+	m.mixHash([]byte(prologue))
+
+	if initiator {
+		m.mixHash(remoteStatic.SerializeCompressed())
+	} else {
+		m.mixHash(localStatic.PubKey().SerializeCompressed())
+	}
+
+	return m
+}
+
+// RemoteStatic returns the authenticated static key of the peer.
+func (m *Machine) RemoteStatic() *secp256k1.PublicKey {
+	return m.remoteStatic
+}
+
+// GenActOne creates the initiator's first message: -> e, es.
+func (m *Machine) GenActOne() ([ActOneSize]byte, error) {
+	var act [ActOneSize]byte
+	var err error
+
+	if m.localEphemeral, err = m.genKey(); err != nil {
+		return act, err
+	}
+
+	ephemeral := m.localEphemeral.PubKey().SerializeCompressed()
+	m.mixHash(ephemeral)
+
+	m.mixKey(ecdh(m.remoteStatic, m.localEphemeral))
+
+	tag := m.encryptAndHash(nil)
+
+	act[0] = handshakeVersion
+	copy(act[1:], ephemeral)
+	copy(act[1+PubKeySize:], tag)
+
+	return act, nil
+}
+
+// RecvActOne processes the initiator's first message.
+func (m *Machine) RecvActOne(act [ActOneSize]byte) error {
+	var err error
+
+	if act[0] != handshakeVersion {
+		return ErrActVersion
+	}
+
+	if m.remoteEphemeral, err = secp256k1.ParsePubKey(act[1 : 1+PubKeySize]); err != nil {
+		return err
+	}
+
+	m.mixHash(m.remoteEphemeral.SerializeCompressed())
+	m.mixKey(ecdh(m.remoteEphemeral, m.localStatic))
+
+	_, err = m.decryptAndHash(act[1+PubKeySize:])
+
+	return err
+}
+
+// GenActTwo creates the responder's reply: <- e, ee.
+func (m *Machine) GenActTwo() ([ActTwoSize]byte, error) {
+	var act [ActTwoSize]byte
+	var err error
+
+	if m.localEphemeral, err = m.genKey(); err != nil {
+		return act, err
+	}
+
+	ephemeral := m.localEphemeral.PubKey().SerializeCompressed()
+	m.mixHash(ephemeral)
+
+	m.mixKey(ecdh(m.remoteEphemeral, m.localEphemeral))
+
+	tag := m.encryptAndHash(nil)
+
+	act[0] = handshakeVersion
+	copy(act[1:], ephemeral)
+	copy(act[1+PubKeySize:], tag)
+
+	return act, nil
+}
+
+// RecvActTwo processes the responder's reply.
+func (m *Machine) RecvActTwo(act [ActTwoSize]byte) error {
+	var err error
+
+	if act[0] != handshakeVersion {
+		return ErrActVersion
+	}
+
+	if m.remoteEphemeral, err = secp256k1.ParsePubKey(act[1 : 1+PubKeySize]); err != nil {
+		return err
+	}
+
+	m.mixHash(m.remoteEphemeral.SerializeCompressed())
+	m.mixKey(ecdh(m.remoteEphemeral, m.localEphemeral))
+
+	_, err = m.decryptAndHash(act[1+PubKeySize:])
+
+	return err
+}
+
+// GenActThree creates the initiator's final message: -> s, se.
+func (m *Machine) GenActThree() ([ActThreeSize]byte, error) {
+	var act [ActThreeSize]byte
+
+	ciphertext := m.encryptAndHash(m.localStatic.PubKey().SerializeCompressed())
+
+	m.mixKey(ecdh(m.remoteEphemeral, m.localStatic))
+
+	tag := m.encryptAndHash(nil)
+
+	act[0] = handshakeVersion
+	copy(act[1:], ciphertext)
+	copy(act[1+PubKeySize+MacSize:], tag)
+
+	m.split()
+
+	return act, nil
+}
+
+// RecvActThree processes the initiator's final message and learns its
+// static key.
+func (m *Machine) RecvActThree(act [ActThreeSize]byte) error {
+	if act[0] != handshakeVersion {
+		return ErrActVersion
+	}
+
+	static, err := m.decryptAndHash(act[1 : 1+PubKeySize+MacSize])
+
+	if err != nil {
+		return err
+	}
+
+	if m.remoteStatic, err = secp256k1.ParsePubKey(static); err != nil {
+		return err
+	}
+
+	m.mixKey(ecdh(m.remoteStatic, m.localEphemeral))
+
+	if _, err = m.decryptAndHash(act[1+PubKeySize+MacSize:]); err != nil {
+		return err
+	}
+
+	m.split()
+
+	return nil
+}
+
+func (m *Machine) split() {
+	first, second := expand(m.chain[:], nil)
+
+	if m.initiator {
+		m.sendCipher.initSalt(first, m.chain)
+		m.recvCipher.initSalt(second, m.chain)
+	} else {
+		m.recvCipher.initSalt(first, m.chain)
+		m.sendCipher.initSalt(second, m.chain)
+	}
+}
+
+// WriteMessage encrypts and frames a single message.
+func (m *Machine) WriteMessage(w io.Writer, data []byte) error {
+	if m.sendCipher.cipher == nil {
+		return ErrNotConnected
+	}
+
+	if len(data) > MaxMessage {
+		return ErrMaxMessage
+	}
+
+	var length [LengthSize]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+
+	packet := make([]byte, 0, HeaderSize+len(data)+MacSize)
+	packet = m.sendCipher.encrypt(packet, nil, length[:])
+	packet = m.sendCipher.encrypt(packet, nil, data)
+
+	_, err := w.Write(packet)
+
+	return err
+}
+
+// ReadMessage reads and decrypts a single framed message.
+func (m *Machine) ReadMessage(r io.Reader) ([]byte, error) {
+	if m.recvCipher.cipher == nil {
+		return nil, ErrNotConnected
+	}
+
+	var header [HeaderSize]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	length, err := m.recvCipher.decrypt(nil, nil, header[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(length)
+
+	if size > MaxMessage {
+		return nil, ErrMaxMessage
+	}
+
+	body := make([]byte, int(size)+MacSize)
+
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return m.recvCipher.decrypt(body[:0], nil, body)
+}
+
+func ecdh(pub *secp256k1.PublicKey, priv *secp256k1.PrivateKey) []byte {
+	var point, result secp256k1.JacobianPoint
+
+	pub.AsJacobian(&point)
+	secp256k1.ScalarMultNonConst(&priv.Key, &point, &result)
+	result.ToAffine()
+
+	shared := secp256k1.NewPublicKey(&result.X, &result.Y)
+	digest := sha256.Sum256(shared.SerializeCompressed())
+
+	return digest[:]
+}
+
+func expand(salt, secret []byte) ([32]byte, [32]byte) {
+	var first, second [32]byte
+
+	r := hkdf.New(sha256.New, secret, salt, nil)
+
+	// Reading 64 bytes from HKDF-SHA256 cannot fail.
+	io.ReadFull(r, first[:])
+	io.ReadFull(r, second[:])
+
+	return first, second
+}
@@ -0,0 +1,347 @@
+package brontide
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func privFromHex(t *testing.T, s string) *secp256k1.PrivateKey {
+	b, err := hex.DecodeString(s)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return secp256k1.PrivKeyFromBytes(b)
+}
+
+func fixedKey(key *secp256k1.PrivateKey) func() (*secp256k1.PrivateKey, error) {
+	return func() (*secp256k1.PrivateKey, error) {
+		return key, nil
+	}
+}
+
+func handshakePair(t *testing.T, prologue string) (*Machine, *Machine) {
+	ls := privFromHex(t, "1111111111111111111111111111111111111111111111111111111111111111")
+	rs := privFromHex(t, "2121212121212121212121212121212121212121212121212121212121212121")
+
+	initiator := newMachine(true, ls, rs.PubKey(), prologue)
+	initiator.genKey = fixedKey(privFromHex(t, "1212121212121212121212121212121212121212121212121212121212121212"))
+
+	responder := newMachine(false, rs, nil, prologue)
+	responder.genKey = fixedKey(privFromHex(t, "2222222222222222222222222222222222222222222222222222222222222222"))
+
+	return initiator, responder
+}
+
+// The handshake is the same as lnd's, so with lnd's prologue the acts must
+// match the BOLT 8 test vectors.
+func TestHandshakeVectors(t *testing.T) {
+	initiator, responder := handshakePair(t, "lightning")
+
+	actOne, err := initiator.GenActOne()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "00036360e856310ce5d294e8be33fc807077dc56ac80d95d9cd4ddbd21325eff73f70df6086551151f58b8afe6c195782c6a"
+
+	if hex.EncodeToString(actOne[:]) != expected {
+		t.Errorf("Act one mismatch: %x != %s", actOne, expected)
+	}
+
+	if err = responder.RecvActOne(actOne); err != nil {
+		t.Fatal(err)
+	}
+
+	actTwo, err := responder.GenActTwo()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "0002466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f276e2470b93aac583c9ef6eafca3f730ae"
+
+	if hex.EncodeToString(actTwo[:]) != expected {
+		t.Errorf("Act two mismatch: %x != %s", actTwo, expected)
+	}
+
+	if err = initiator.RecvActTwo(actTwo); err != nil {
+		t.Fatal(err)
+	}
+
+	actThree, err := initiator.GenActThree()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "00b9e3a702e93e3a9948c2ed6e5fd7590a6e1c3a0344cfc9d5b57357049aa22355361aa02e55a8fc28fef5bd6d71ad0c38228dc68b1c466263b47fdf31e560e139ba"
+
+	if hex.EncodeToString(actThree[:]) != expected {
+		t.Errorf("Act three mismatch: %x != %s", actThree, expected)
+	}
+
+	if err = responder.RecvActThree(actThree); err != nil {
+		t.Fatal(err)
+	}
+
+	sendKey := "969ab31b4d288cedf6218839b27a3e2140827047f2c0f01bf5c04435d43511a9"
+	recvKey := "bb9020b8965f4df047e07f955f3c4b88418984aadc5cdb35096b9ea8fa5c3442"
+
+	if hex.EncodeToString(initiator.sendCipher.key[:]) != sendKey {
+		t.Errorf("Send key mismatch: %x", initiator.sendCipher.key)
+	}
+
+	if hex.EncodeToString(initiator.recvCipher.key[:]) != recvKey {
+		t.Errorf("Recv key mismatch: %x", initiator.recvCipher.key)
+	}
+
+	if responder.recvCipher.key != initiator.sendCipher.key {
+		t.Errorf("Responder recv key does not match initiator send key")
+	}
+
+	if !responder.RemoteStatic().IsEqual(initiator.localStatic.PubKey()) {
+		t.Errorf("Responder learned the wrong static key")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	initiator, responder := handshakePair(t, Prologue)
+
+	actOne, _ := initiator.GenActOne()
+	responder.RecvActOne(actOne)
+	actTwo, _ := responder.GenActTwo()
+	initiator.RecvActTwo(actTwo)
+	actThree, _ := initiator.GenActThree()
+
+	if err := responder.RecvActThree(actThree); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	firstKey := initiator.sendCipher.key
+	msg := []byte("hello")
+
+	// Each message uses two nonces: one for the length, one for the body.
+	for i := 0; i < RotationInterval; i++ {
+		if err := initiator.WriteMessage(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := responder.ReadMessage(&buf)
+
+		if err != nil {
+			t.Fatalf("ReadMessage %d failed: %s", i, err)
+		}
+
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("Message %d mismatch: %x", i, got)
+		}
+
+		if i == RotationInterval/2-2 && initiator.sendCipher.key != firstKey {
+			t.Errorf("Key rotated too early")
+		}
+	}
+
+	if initiator.sendCipher.key == firstKey {
+		t.Errorf("Key was not rotated")
+	}
+
+	if initiator.sendCipher.key != responder.recvCipher.key {
+		t.Errorf("Rotated keys differ")
+	}
+}
+
+func connPair(t *testing.T) (*Conn, *Conn) {
+	clientKey, _ := secp256k1.GeneratePrivateKey()
+	serverKey, _ := secp256k1.GeneratePrivateKey()
+
+	a, b := net.Pipe()
+
+	type result struct {
+		conn *Conn
+		err  error
+	}
+
+	done := make(chan result)
+
+	go func() {
+		conn, err := Server(b, serverKey)
+		done <- result{conn, err}
+	}()
+
+	client, err := Client(a, clientKey, serverKey.PubKey())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := <-done
+
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+
+	if !res.conn.RemotePub().IsEqual(clientKey.PubKey()) {
+		t.Errorf("Server learned the wrong client key")
+	}
+
+	if !client.RemotePub().IsEqual(serverKey.PubKey()) {
+		t.Errorf("Client has the wrong server key")
+	}
+
+	return client, res.conn
+}
+
+func TestConn(t *testing.T) {
+	client, server := connPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte{0xab}, 100000)
+
+	go func() {
+		client.Write(payload)
+		client.WriteMessage([]byte("ping"))
+	}()
+
+	got := make([]byte, len(payload))
+
+	if _, err := io.ReadFull(server, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, payload) {
+		t.Errorf("Payload mismatch")
+	}
+
+	msg, err := server.ReadMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg) != "ping" {
+		t.Errorf("Message mismatch: %q", msg)
+	}
+
+	go server.WriteMessage([]byte("pong"))
+
+	if msg, err = client.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg) != "pong" {
+		t.Errorf("Message mismatch: %q", msg)
+	}
+}
+
+func TestHandshakeWrongKey(t *testing.T) {
+	clientKey, _ := secp256k1.GeneratePrivateKey()
+	serverKey, _ := secp256k1.GeneratePrivateKey()
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+
+	a, b := net.Pipe()
+	done := make(chan error)
+
+	go func() {
+		_, err := Server(b, serverKey)
+		done <- err
+	}()
+
+	if _, err := Client(a, clientKey, otherKey.PubKey()); err == nil {
+		t.Errorf("Expected client handshake to fail")
+	}
+
+	if err := <-done; err == nil {
+		t.Errorf("Expected server handshake to fail")
+	}
+}
+
+func TestListenerBadClients(t *testing.T) {
+	serverKey, _ := secp256k1.GeneratePrivateKey()
+	clientKey, _ := secp256k1.GeneratePrivateKey()
+
+	l, err := Listen("127.0.0.1:0", serverKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	// A client that never speaks and one that sends garbage must not
+	// block or break the accept loop.
+	stalled, err := net.Dial("tcp", l.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer stalled.Close()
+
+	garbage, err := net.Dial("tcp", l.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garbage.Write(bytes.Repeat([]byte{0xff}, ActOneSize))
+	garbage.Close()
+
+	done := make(chan error, 1)
+
+	go func() {
+		client, err := Dial(l.Addr().String(), clientKey, serverKey.PubKey())
+
+		if err == nil {
+			err = client.WriteMessage([]byte("hello"))
+			client.Close()
+		}
+
+		done <- err
+	}()
+
+	var conns []net.Conn
+
+	for i := 0; i < 3; i++ {
+		conn, err := l.Accept()
+
+		if err != nil {
+			t.Fatalf("Accept failed: %s", err)
+		}
+
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	if _, err := conns[1].(*Conn).ReadMessage(); err == nil {
+		t.Errorf("Expected handshake error from the garbage client")
+	}
+
+	msg, err := conns[2].(*Conn).ReadMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg) != "hello" {
+		t.Errorf("Message mismatch: %q", msg)
+	}
+
+	if !conns[2].(*Conn).RemotePub().IsEqual(clientKey.PubKey()) {
+		t.Errorf("Server learned the wrong client key")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,91 @@
+// Command hsd-fixtures writes proof test vectors built from random urkel
+// trees.
+//
+// Usage:
+//
+//	hsd-fixtures [-seed <n>] [-leaves <n>] [-count <n>] [-hasher <blake2b|sha256>] [-out <file>]
+//
+// The vectors use the schema of proof/testdata/proofs.json and cover every
+// proof type along with wrong key, tampered node and wrong depth failures.
+// Output goes to stdout unless -out is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nodech/go-hsd-utils/fixture"
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 64
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("hsd-fixtures", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	seed := fs.Int64("seed", 1, "random seed")
+	leaves := fs.Int("leaves", fixture.DefaultLeaves, "number of keys in the tree")
+	count := fs.Int("count", fixture.DefaultCount, "vectors per proof type and case")
+	hasherName := fs.String("hasher", "blake2b", "tree hash: blake2b or sha256")
+	out := fs.String("out", "", "output file, stdout if empty")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var hasher proof.Hasher
+
+	switch *hasherName {
+	case "blake2b":
+		hasher = proof.Blake2b
+	case "sha256":
+		hasher = proof.SHA256
+	default:
+		fmt.Fprintf(stderr, "unknown hasher %q\n", *hasherName)
+		return exitUsage
+	}
+
+	vectors, err := fixture.Generate(fixture.Options{
+		Seed:   *seed,
+		Leaves: *leaves,
+		Count:  *count,
+		Hasher: hasher,
+	})
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitError
+	}
+
+	w := stdout
+
+	if *out != "" {
+		f, err := os.Create(*out)
+
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return exitError
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	if err := fixture.Write(w, vectors); err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitError
+	}
+
+	return exitOk
+}
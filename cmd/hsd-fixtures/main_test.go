@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/fixture"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"-seed", "5", "-leaves", "16", "-count", "1"}, &stdout, &stderr); code != exitOk {
+		t.Fatalf("Expected exit 0, got %d: %s", code, stderr.String())
+	}
+
+	vectors, err := fixture.Read(&stdout)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vectors) == 0 {
+		t.Fatal("No vectors written")
+	}
+
+	out := filepath.Join(t.TempDir(), "vectors.json")
+
+	if code := run([]string{"-hasher", "sha256", "-out", out}, &stdout, &stderr); code != exitOk {
+		t.Fatalf("Expected exit 0, got %d: %s", code, stderr.String())
+	}
+
+	if _, err := os.Stat(out); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := run([]string{"-hasher", "md5"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected usage exit, got %d", code)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+const (
+	formatAuto   = "auto"
+	formatHex    = "hex"
+	formatRaw    = "raw"
+	formatJSON   = "json"
+	formatBase64 = "base64"
+)
+
+// input is a decoded proof, together with the getnameproof envelope when
+// the JSON input carried one.
+type input struct {
+	proof     *proof.Proof
+	nameProof *proof.NameProof
+}
+
+// readInput loads data from the -proof value or from a file ("-" for
+// stdin).
+func readInput(value string, path string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case value != "" && path != "":
+		return nil, errors.New("use either -proof or -in, not both")
+	case value != "":
+		return []byte(value), nil
+	case path == "-":
+		return io.ReadAll(stdin)
+	case path != "":
+		return os.ReadFile(path)
+	}
+
+	return nil, errors.New("no proof given, use -proof or -in")
+}
+
+func detectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return formatJSON
+	}
+
+	if isHex(trimmed) {
+		return formatHex
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && len(trimmed) > 0 {
+		return formatBase64
+	}
+
+	return formatRaw
+}
+
+func isHex(data []byte) bool {
+	if len(data) == 0 || len(data)%2 != 0 {
+		return false
+	}
+
+	for _, ch := range data {
+		switch {
+		case ch >= '0' && ch <= '9':
+		case ch >= 'a' && ch <= 'f':
+		case ch >= 'A' && ch <= 'F':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeInput(data []byte, format string) (*input, error) {
+	if format == formatAuto {
+		format = detectFormat(data)
+	}
+
+	text := strings.TrimSpace(string(data))
+
+	switch format {
+	case formatHex:
+		raw, err := hex.DecodeString(text)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeRaw(raw)
+	case formatBase64:
+		raw, err := base64.StdEncoding.DecodeString(text)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeRaw(raw)
+	case formatRaw:
+		return decodeRaw(data)
+	case formatJSON:
+		return decodeJSON(data)
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func decodeRaw(raw []byte) (*input, error) {
+	p, err := proof.NewFromBytes(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &input{proof: p}, nil
+}
+
+func decodeJSON(data []byte) (*input, error) {
+	var probe map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	if _, ok := probe["proof"]; ok {
+		np, err := proof.NewNameProofFromJSON(data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &input{proof: np.Proof, nameProof: np}, nil
+	}
+
+	p, err := proof.NewFromJSON(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &input{proof: p}, nil
+}
+
+func parseHash(s string) (proof.UrkelHash, error) {
+	var hash proof.UrkelHash
+
+	raw, err := hex.DecodeString(s)
+
+	if err != nil {
+		return hash, err
+	}
+
+	if len(raw) != proof.UrkelHashSize {
+		return hash, errors.New("invalid hash length")
+	}
+
+	copy(hash[:], raw)
+
+	return hash, nil
+}
@@ -0,0 +1,274 @@
+// Command hsd-proof verifies, decodes and converts urkel proofs.
+//
+// Usage:
+//
+//	hsd-proof verify  -root <hex> (-key <hex> | -name <name>) (-proof <data> | -in <file>)
+//	hsd-proof decode  (-proof <data> | -in <file>)
+//	hsd-proof convert -to <hex|raw|json|base64> (-proof <data> | -in <file>)
+//
+// Input format is detected automatically unless -format is given. The
+// verify exit code is the urkel code of the result (0 for PROOF_OK); usage
+// and input errors exit with 64 and 65.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+const (
+	exitOk    = 0
+	exitUsage = 64
+	exitInput = 65
+)
+
+const usage = `Usage: hsd-proof <command> [flags]
+
+Commands:
+  verify   verify a proof against a root and a key or name
+  decode   print the contents of a proof
+  convert  convert a proof between hex, raw, json and base64
+
+Run "hsd-proof <command> -h" for command flags.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "verify":
+		return runVerify(args[1:], stdin, stdout, stderr)
+	case "decode":
+		return runDecode(args[1:], stdin, stdout, stderr)
+	case "convert":
+		return runConvert(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOk
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+
+	return exitUsage
+}
+
+type inputFlags struct {
+	proof  string
+	in     string
+	format string
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	f := &inputFlags{}
+
+	fs.StringVar(&f.proof, "proof", "", "proof as hex, base64 or JSON")
+	fs.StringVar(&f.in, "in", "", "read the proof from a file, - for stdin")
+	fs.StringVar(&f.format, "format", formatAuto, "input format: auto, hex, raw, json or base64")
+
+	return f
+}
+
+func (f *inputFlags) load(stdin io.Reader) (*input, error) {
+	data, err := readInput(f.proof, f.in, stdin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeInput(data, f.format)
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func runVerify(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("verify", stderr)
+	in := addInputFlags(fs)
+	rootHex := fs.String("root", "", "tree root as hex")
+	keyHex := fs.String("key", "", "key as hex")
+	name := fs.String("name", "", "name to derive the key from")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	input, err := in.load(stdin)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitInput
+	}
+
+	// A getnameproof result provides defaults for root and name.
+	if np := input.nameProof; np != nil {
+		if *rootHex == "" {
+			*rootHex = np.Root
+		}
+
+		if *keyHex == "" && *name == "" {
+			*name = np.Name
+		}
+	}
+
+	if *rootHex == "" || (*keyHex == "") == (*name == "") {
+		fmt.Fprintln(stderr, "error: verify needs -root and exactly one of -key or -name")
+		return exitUsage
+	}
+
+	root, err := parseHash(*rootHex)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: invalid root: %s\n", err)
+		return exitInput
+	}
+
+	var key proof.UrkelHash
+
+	if *name != "" {
+		key, err = proof.HashName(*name)
+	} else {
+		key, err = parseHash(*keyHex)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: invalid key: %s\n", err)
+		return exitInput
+	}
+
+	code, value := input.proof.Verify(root, key)
+
+	fmt.Fprintln(stdout, code)
+
+	if code == proof.ProofOk && input.proof.Type() == proof.ProofTypeExists {
+		fmt.Fprintf(stdout, "value: %s\n", hex.EncodeToString(value))
+	}
+
+	return int(code)
+}
+
+func runDecode(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("decode", stderr)
+	in := addInputFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	input, err := in.load(stdin)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitInput
+	}
+
+	printProof(stdout, input.proof)
+
+	return exitOk
+}
+
+func printProof(w io.Writer, p *proof.Proof) {
+	var raw bytes.Buffer
+
+	p.Serialize(&raw)
+
+	fmt.Fprintf(w, "type:  %s\n", p.Type())
+	fmt.Fprintf(w, "depth: %d\n", p.Depth())
+	fmt.Fprintf(w, "size:  %d bytes\n", raw.Len())
+	fmt.Fprintf(w, "nodes: %d\n", len(p.Nodes()))
+
+	for i, node := range p.Nodes() {
+		prefix := node.Prefix()
+		bits := prefix.String()
+
+		if bits == "" {
+			bits = "-"
+		}
+
+		hash := node.Hash()
+		fmt.Fprintf(w, "  %3d  %s  prefix %s\n", i, hex.EncodeToString(hash[:]), bits)
+	}
+
+	switch p.Type() {
+	case proof.ProofTypeShort:
+		prefix := p.Prefix()
+		left := p.Left()
+		right := p.Right()
+
+		fmt.Fprintf(w, "prefix: %s\n", prefix.String())
+		fmt.Fprintf(w, "left:   %s\n", hex.EncodeToString(left[:]))
+		fmt.Fprintf(w, "right:  %s\n", hex.EncodeToString(right[:]))
+	case proof.ProofTypeCollision:
+		key := p.Key()
+		hash := p.Hash()
+
+		fmt.Fprintf(w, "key:    %s\n", hex.EncodeToString(key[:]))
+		fmt.Fprintf(w, "hash:   %s\n", hex.EncodeToString(hash[:]))
+	case proof.ProofTypeExists:
+		fmt.Fprintf(w, "value:  %s\n", hex.EncodeToString(p.Value()))
+	}
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("convert", stderr)
+	in := addInputFlags(fs)
+	to := fs.String("to", formatJSON, "output format: hex, raw, json or base64")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	input, err := in.load(stdin)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitInput
+	}
+
+	var raw bytes.Buffer
+
+	if err = input.proof.Serialize(&raw); err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return exitInput
+	}
+
+	switch *to {
+	case formatHex:
+		fmt.Fprintln(stdout, hex.EncodeToString(raw.Bytes()))
+	case formatBase64:
+		fmt.Fprintln(stdout, base64.StdEncoding.EncodeToString(raw.Bytes()))
+	case formatRaw:
+		stdout.Write(raw.Bytes())
+	case formatJSON:
+		data, err := json.MarshalIndent(input.proof, "", "  ")
+
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return exitInput
+		}
+
+		fmt.Fprintln(stdout, string(data))
+	default:
+		fmt.Fprintf(stderr, "error: unknown output format %q\n", *to)
+		return exitUsage
+	}
+
+	return exitOk
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+const (
+	proofHex = "07c0060010b38bd3162de23fd4242511f3d96518b41fb5ceaba1b2604718e9ff4905decc96a725d93e5424af84461ac485543bd9fc84875ef8b9eccdaec714cecc03cea73bee019110c7f43813690f676307494281bb4d30e9b96334dc561ea506413827fb0180d8fcc02cfdf8f1ab87ddddf80d1ab24e2bbc73b8ccf2e48e0e5b45b0a68b2ae2cee75163e06169984dff42cbce4ce516eeda81135f4f2b41899df4a532bb718409daecd329756ed5e608f784903e4ec0570d8f30a1c9cfd7c6c3b8d997c47a57070068656c6c6f2032"
+	rootHex  = "4ec83628341d5b97a6259e069dc5d83af77446301fafcb393772e3e90829ec41"
+	keyHex   = "76726046e2db690179b6be0e81348068b15c1323f5d02ed39b24842fb1947262"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestVerify(t *testing.T) {
+	code, out, _ := runCmd(t, "", "verify", "-root", rootHex, "-key", keyHex, "-proof", proofHex)
+
+	if code != int(proof.ProofOk) {
+		t.Errorf("Expected exit 0, got %d", code)
+	}
+
+	if !strings.Contains(out, "PROOF_OK") || !strings.Contains(out, "68656c6c6f2032") {
+		t.Errorf("Unexpected output: %s", out)
+	}
+
+	badRoot := strings.Repeat("00", 32)
+	code, out, _ = runCmd(t, "", "verify", "-root", badRoot, "-key", keyHex, "-proof", proofHex)
+
+	if code != int(proof.ProofHashMismatch) || !strings.Contains(out, "PROOF_HASH_MISMATCH") {
+		t.Errorf("Expected hash mismatch exit, got %d: %s", code, out)
+	}
+
+	code, _, _ = runCmd(t, "", "verify", "-root", rootHex, "-name", "handshake", "-proof", proofHex)
+
+	if code == int(proof.ProofOk) {
+		t.Errorf("Proof should not verify for an unrelated name")
+	}
+}
+
+func TestVerifyInputs(t *testing.T) {
+	_, jsonOut, _ := runCmd(t, "", "convert", "-to", "json", "-proof", proofHex)
+
+	code, _, _ := runCmd(t, jsonOut, "verify", "-root", rootHex, "-key", keyHex, "-in", "-")
+
+	if code != exitOk {
+		t.Errorf("JSON from stdin: exit %d", code)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "proof.bin")
+	_, raw, _ := runCmd(t, "", "convert", "-to", "raw", "-proof", proofHex)
+
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, _ = runCmd(t, "", "verify", "-root", rootHex, "-key", keyHex, "-in", path, "-format", "raw")
+
+	if code != exitOk {
+		t.Errorf("Raw file: exit %d", code)
+	}
+
+	code, _, _ = runCmd(t, "", "verify", "-root", rootHex, "-key", keyHex, "-proof", "zz")
+
+	if code != exitInput {
+		t.Errorf("Expected input error exit, got %d", code)
+	}
+
+	code, _, _ = runCmd(t, "", "verify", "-key", keyHex, "-proof", proofHex)
+
+	if code != exitUsage {
+		t.Errorf("Expected usage exit without root, got %d", code)
+	}
+
+	code, _, _ = runCmd(t, "", "frobnicate")
+
+	if code != exitUsage {
+		t.Errorf("Expected usage exit for unknown command, got %d", code)
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	_, b64, _ := runCmd(t, "", "convert", "-to", "base64", "-proof", proofHex)
+	_, jsonOut, _ := runCmd(t, "", "convert", "-to", "json", "-proof", strings.TrimSpace(b64))
+	_, hexOut, _ := runCmd(t, "", "convert", "-to", "hex", "-proof", jsonOut)
+
+	if strings.TrimSpace(hexOut) != proofHex {
+		t.Errorf("Round trip mismatch: %s", hexOut)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	code, out, _ := runCmd(t, "", "decode", "-proof", proofHex)
+
+	if code != exitOk {
+		t.Fatalf("decode failed: %d", code)
+	}
+
+	for _, want := range []string{"TYPE_EXISTS", "depth: 7", "nodes: 6", "prefix 1", "68656c6c6f2032"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+}
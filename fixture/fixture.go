@@ -0,0 +1,278 @@
+// Package fixture generates proof test vectors from random urkel trees.
+//
+// Vectors use the schema of proof/testdata/proofs.json. Besides wrong
+// keys, tampered nodes and wrong depths, every valid proof is followed by
+// the mutations of package mutate. Failure cases add the expected
+// verification code and the name of the case; both are omitted for valid
+// proofs, so those vectors match the original schema exactly.
+package fixture
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+
+	"github.com/nodech/go-hsd-utils/mutate"
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+// Cases a vector can be generated for.
+const (
+	CaseValid      = ""
+	CaseWrongKey   = "wrong-key"
+	CaseTamperNode = "tampered-node"
+	CaseWrongDepth = "wrong-depth"
+)
+
+const (
+	DefaultLeaves   = 64
+	DefaultCount    = 2
+	DefaultMaxValue = 64
+)
+
+// Vector is a single proof test vector.
+type Vector struct {
+	Type proof.ProofType `json:"type"`
+	Raw  string          `json:"raw"`
+	JSON *proof.Proof    `json:"json"`
+	Root string          `json:"root"`
+	Key  string          `json:"key"`
+
+	// Code is the expected result of verifying the proof against Root and
+	// Key.
+	Code proof.UrkelCode `json:"code,omitempty"`
+	Case string          `json:"case,omitempty"`
+}
+
+// Options configures Generate. Zero fields use the defaults.
+type Options struct {
+	// Seed makes the output reproducible.
+	Seed int64
+
+	// Leaves is the number of keys in the random tree.
+	Leaves int
+
+	// Count is the number of vectors of each proof type for each case.
+	Count int
+
+	// MaxValue is the largest value size inserted.
+	MaxValue int
+
+	// Hasher hashes the tree. Nil means proof.DefaultHasher.
+	Hasher proof.Hasher
+}
+
+var errExhausted = errors.New("fixture: could not find keys for every proof type")
+
+// Generate builds a random tree and returns valid vectors of every proof
+// type, each followed by its failure cases.
+func Generate(opts Options) ([]Vector, error) {
+	if opts.Leaves <= 0 {
+		opts.Leaves = DefaultLeaves
+	}
+
+	if opts.Count <= 0 {
+		opts.Count = DefaultCount
+	}
+
+	if opts.MaxValue <= 0 || opts.MaxValue > proof.UrkelValueSize {
+		opts.MaxValue = DefaultMaxValue
+	}
+
+	if opts.Hasher == nil {
+		opts.Hasher = proof.DefaultHasher()
+	}
+
+	g := &generator{
+		rng:    rand.New(rand.NewSource(opts.Seed)),
+		hasher: opts.Hasher,
+	}
+
+	// Dead ends only occur in an empty tree.
+	empty := urkel.NewWithHasher(opts.Hasher)
+
+	for i := 0; i < opts.Count; i++ {
+		if err := g.add(empty, g.randomKey()); err != nil {
+			return nil, err
+		}
+	}
+
+	tree := urkel.NewWithHasher(opts.Hasher)
+	keys := make([]proof.UrkelHash, opts.Leaves)
+
+	for i := range keys {
+		keys[i] = g.randomKey()
+		value := make([]byte, 1+g.rng.Intn(opts.MaxValue))
+		g.rng.Read(value)
+
+		if err := tree.Insert(keys[i], value); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < opts.Count; i++ {
+		if err := g.add(tree, keys[g.rng.Intn(len(keys))]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Random keys end either inside a prefix or at another leaf.
+	short, collision := 0, 0
+
+	for tries := 0; short < opts.Count || collision < opts.Count; tries++ {
+		if tries > 1000*opts.Count {
+			return nil, errExhausted
+		}
+
+		key := g.randomKey()
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case p.Type() == proof.ProofTypeShort && short < opts.Count:
+			short++
+		case p.Type() == proof.ProofTypeCollision && collision < opts.Count:
+			collision++
+		default:
+			continue
+		}
+
+		if err := g.add(tree, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.vectors, nil
+}
+
+// Write writes vectors as indented JSON, the layout of proofs.json.
+func Write(w io.Writer, vectors []Vector) error {
+	data, err := json.MarshalIndent(vectors, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// Read reads vectors written by Write, or the original proofs.json.
+func Read(r io.Reader) ([]Vector, error) {
+	var vectors []Vector
+
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, err
+	}
+
+	return vectors, nil
+}
+
+type generator struct {
+	rng     *rand.Rand
+	hasher  proof.Hasher
+	vectors []Vector
+}
+
+func (g *generator) randomKey() proof.UrkelHash {
+	var key proof.UrkelHash
+	g.rng.Read(key[:])
+	return key
+}
+
+// add appends the valid proof for key and its failure cases.
+func (g *generator) add(tree *urkel.Tree, key proof.UrkelHash) error {
+	root := tree.RootHash()
+	p, err := tree.Prove(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := g.push(CaseValid, p, root, key); err != nil {
+		return err
+	}
+
+	if err := g.push(CaseWrongKey, p, root, g.randomKey()); err != nil {
+		return err
+	}
+
+	if len(p.Nodes()) > 0 {
+		nodes := append([]*proof.ProofNode(nil), p.Nodes()...)
+		i := g.rng.Intn(len(nodes))
+		hash := nodes[i].Hash()
+		hash[g.rng.Intn(len(hash))] ^= 1 << g.rng.Intn(8)
+		nodes[i] = proof.NewProofNode(nodes[i].Prefix(), hash)
+
+		tampered := p.WithDepthAndNodes(p.Depth(), nodes)
+
+		if err := g.push(CaseTamperNode, tampered, root, key); err != nil {
+			return err
+		}
+	}
+
+	deeper := p.WithDepthAndNodes(p.Depth()+1, p.Nodes())
+
+	if err := g.push(CaseWrongDepth, deeper, root, key); err != nil {
+		return err
+	}
+
+	mutations, err := mutate.AllWith(g.hasher, p, root, key)
+
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mutations {
+		// Proofs that fail the sanity check cannot be decoded from raw.
+		if m.Code == proof.ProofInvalid {
+			continue
+		}
+
+		if err := g.push(m.Name, m.Proof, m.Root, m.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push records p with the code it verifies to. Failure cases that happen
+// to verify are dropped.
+func (g *generator) push(name string, p *proof.Proof, root, key proof.UrkelHash) error {
+	code, _ := p.VerifyWith(g.hasher, root, key)
+
+	if name == CaseValid && code != proof.ProofOk {
+		return errors.New("fixture: tree produced an invalid proof")
+	}
+
+	if name != CaseValid && code == proof.ProofOk {
+		return nil
+	}
+
+	var raw bytes.Buffer
+
+	if err := p.Serialize(&raw); err != nil {
+		return err
+	}
+
+	g.vectors = append(g.vectors, Vector{
+		Type: p.Type(),
+		Raw:  hex.EncodeToString(raw.Bytes()),
+		JSON: p,
+		Root: hex.EncodeToString(root[:]),
+		Key:  hex.EncodeToString(key[:]),
+		Code: code,
+		Case: name,
+	})
+
+	return nil
+}
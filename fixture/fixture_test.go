@@ -0,0 +1,157 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+func checkVectors(t *testing.T, vectors []Vector, hasher proof.Hasher) {
+	t.Helper()
+
+	for i, v := range vectors {
+		raw, err := hex.DecodeString(v.Raw)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := proof.NewFromBytes(raw)
+
+		if err != nil {
+			t.Fatalf("vector %d: %s", i, err)
+		}
+
+		if p.Type() != v.Type {
+			t.Fatalf("vector %d: type %s, expected %s", i, p.Type(), v.Type)
+		}
+
+		var root, key proof.UrkelHash
+
+		rootRaw, _ := hex.DecodeString(v.Root)
+		keyRaw, _ := hex.DecodeString(v.Key)
+		copy(root[:], rootRaw)
+		copy(key[:], keyRaw)
+
+		if code, _ := p.VerifyWith(hasher, root, key); code != v.Code {
+			t.Fatalf("vector %d (%s): code %s, expected %s", i, v.Case, code, v.Code)
+		}
+
+		if code, _ := v.JSON.VerifyWith(hasher, root, key); code != v.Code {
+			t.Fatalf("vector %d (%s): JSON code %s, expected %s", i, v.Case, code, v.Code)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	vectors, err := Generate(Options{Seed: 3})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := make(map[proof.ProofType]int)
+	cases := make(map[string]int)
+
+	for _, v := range vectors {
+		if v.Case == CaseValid {
+			types[v.Type]++
+		}
+
+		cases[v.Case]++
+	}
+
+	for typ := proof.ProofTypeDeadEnd; typ < proof.ProofTypeUnknown; typ++ {
+		if types[typ] != DefaultCount {
+			t.Fatalf("expected %d valid %s vectors, got %d", DefaultCount, typ, types[typ])
+		}
+	}
+
+	for _, name := range []string{CaseWrongKey, CaseTamperNode, CaseWrongDepth} {
+		if cases[name] == 0 {
+			t.Fatalf("no %s vectors", name)
+		}
+	}
+
+	checkVectors(t, vectors, proof.DefaultHasher())
+
+	again, err := Generate(Options{Seed: 3})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a, b bytes.Buffer
+
+	if err := Write(&a, vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Write(&b, again); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Fatal("same seed produced different vectors")
+	}
+
+	read, err := Read(&a)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != len(vectors) {
+		t.Fatalf("read %d vectors, expected %d", len(read), len(vectors))
+	}
+
+	checkVectors(t, read, proof.DefaultHasher())
+}
+
+func TestGenerateSHA256(t *testing.T) {
+	vectors, err := Generate(Options{Seed: 4, Hasher: proof.SHA256})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkVectors(t, vectors, proof.SHA256)
+}
+
+func TestReadProofsJSON(t *testing.T) {
+	f, err := os.Open("../proof/testdata/proofs.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	vectors, err := Read(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkVectors(t, vectors, proof.DefaultHasher())
+
+	// Valid vectors round trip to the original schema.
+	var buf bytes.Buffer
+
+	if err := Write(&buf, vectors[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again[0].Root != vectors[0].Root || again[0].Raw != vectors[0].Raw {
+		t.Fatal("round trip mismatch")
+	}
+}
@@ -1,7 +1,10 @@
 module github.com/nodech/go-hsd-utils
 
-go 1.19
+go 1.23
 
-require golang.org/x/crypto v0.16.0
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.0
+	golang.org/x/crypto v0.16.0
+)
 
 require golang.org/x/sys v0.15.0 // indirect
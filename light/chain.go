@@ -0,0 +1,355 @@
+package light
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+const (
+	medianTimeSpan = 11
+	maxFutureDrift = 2 * 60 * 60
+
+	// maxLocator bounds the hashes sent in a getheaders locator.
+	maxLocator = 64
+)
+
+var (
+	ErrOrphan = errors.New("headers do not connect to the chain")
+)
+
+var oneLsh256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// InvalidHeaderError is returned for headers that break consensus rules.
+// Peers sending them should be banned.
+type InvalidHeaderError struct {
+	Hash   wire.Hash
+	Reason string
+}
+
+func (e *InvalidHeaderError) Error() string {
+	return fmt.Sprintf("invalid header %s: %s", e.Hash, e.Reason)
+}
+
+type Entry struct {
+	Header wire.BlockHeader
+	Hash   wire.Hash
+	Height uint32
+
+	// Work is the cumulative chain work up to and including this entry.
+	Work *big.Int
+}
+
+// Chain is an in-memory header chain following the most work.
+type Chain struct {
+	mu      sync.RWMutex
+	network *Network
+	entries []*Entry
+	index   map[wire.Hash]*Entry
+
+	// now returns the current unix time, tests override it.
+	now func() int64
+}
+
+func NewChain(network *Network) *Chain {
+	genesis := &Entry{
+		Header: network.Genesis,
+		Hash:   network.Genesis.Hash(),
+		Height: 0,
+	}
+
+	genesis.Work = headerWork(network.Genesis.Bits)
+
+	return &Chain{
+		network: network,
+		entries: []*Entry{genesis},
+		index:   map[wire.Hash]*Entry{genesis.Hash: genesis},
+		now:     func() int64 { return time.Now().Unix() },
+	}
+}
+
+func (c *Chain) Tip() *Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.entries[len(c.entries)-1]
+}
+
+func (c *Chain) Height() uint32 {
+	return c.Tip().Height
+}
+
+// GetEntry returns the main chain entry at height, or nil.
+func (c *Chain) GetEntry(height uint32) *Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if int(height) >= len(c.entries) {
+		return nil
+	}
+
+	return c.entries[height]
+}
+
+// HasEntry returns true if hash is part of the main chain.
+func (c *Chain) HasEntry(hash wire.Hash) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.index[hash]
+
+	return ok
+}
+
+// Locator returns block hashes going back from the tip, dense at first and
+// exponentially sparser, always ending with the genesis hash.
+func (c *Chain) Locator() []wire.Hash {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var locator []wire.Hash
+
+	step := 1
+	height := len(c.entries) - 1
+
+	for height > 0 && len(locator) < maxLocator-1 {
+		locator = append(locator, c.entries[height].Hash)
+
+		if len(locator) >= 10 {
+			step *= 2
+		}
+
+		height -= step
+	}
+
+	return append(locator, c.entries[0].Hash)
+}
+
+// SafeRoot returns the most recent tree root that has been committed to in
+// at least confirmations headers. With zero confirmations it is the root
+// of the tip.
+func (c *Chain) SafeRoot(confirmations uint32) proof.UrkelHash {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tip := c.entries[len(c.entries)-1]
+	root := tip.Header.TreeRoot
+	first := int(tip.Height)
+
+	for first > 0 && c.entries[first-1].Header.TreeRoot == root {
+		first--
+	}
+
+	if tip.Height-uint32(first)+1 >= confirmations || first == 0 {
+		return proof.UrkelHash(root)
+	}
+
+	return proof.UrkelHash(c.entries[first-1].Header.TreeRoot)
+}
+
+// AddHeaders validates headers and connects them. Headers forking off the
+// main chain replace it if they carry more work. It returns the number of
+// new headers accepted into the main chain.
+func (c *Chain) AddHeaders(headers []*wire.BlockHeader) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Skip headers we already have.
+	for len(headers) > 0 {
+		if _, ok := c.index[headers[0].Hash()]; !ok {
+			break
+		}
+
+		headers = headers[1:]
+	}
+
+	if len(headers) == 0 {
+		return 0, nil
+	}
+
+	fork, ok := c.index[headers[0].PrevBlock]
+
+	if !ok {
+		return 0, ErrOrphan
+	}
+
+	branch := make([]*Entry, 0, len(headers))
+	prev := fork
+
+	for _, header := range headers {
+		entry := &Entry{
+			Header: *header,
+			Hash:   header.Hash(),
+			Height: prev.Height + 1,
+		}
+
+		if header.PrevBlock != prev.Hash {
+			return 0, &InvalidHeaderError{entry.Hash, "bad-prevblk"}
+		}
+
+		if err := c.verify(entry, branch, fork); err != nil {
+			return 0, err
+		}
+
+		entry.Work = new(big.Int).Add(prev.Work, headerWork(header.Bits))
+		branch = append(branch, entry)
+		prev = entry
+	}
+
+	tip := c.entries[len(c.entries)-1]
+
+	if prev.Work.Cmp(tip.Work) <= 0 {
+		return 0, nil
+	}
+
+	for _, entry := range c.entries[fork.Height+1:] {
+		delete(c.index, entry.Hash)
+	}
+
+	c.entries = c.entries[:fork.Height+1]
+
+	for _, entry := range branch {
+		c.entries = append(c.entries, entry)
+		c.index[entry.Hash] = entry
+	}
+
+	return len(branch), nil
+}
+
+func (c *Chain) verify(entry *Entry, branch []*Entry, fork *Entry) error {
+	header := &entry.Header
+	target := wire.CompactToBig(header.Bits)
+
+	if target.Sign() <= 0 || target.Cmp(wire.CompactToBig(c.network.PowLimit)) > 0 {
+		return &InvalidHeaderError{entry.Hash, "bad-diffbits"}
+	}
+
+	if header.Bits != c.target(entry, branch, fork) {
+		return &InvalidHeaderError{entry.Hash, "bad-diffbits"}
+	}
+
+	if !header.VerifyPOW() {
+		return &InvalidHeaderError{entry.Hash, "high-hash"}
+	}
+
+	if int64(header.Time) > c.now()+maxFutureDrift {
+		return &InvalidHeaderError{entry.Hash, "time-too-new"}
+	}
+
+	if header.Time <= c.medianTime(branch, fork) {
+		return &InvalidHeaderError{entry.Hash, "time-too-old"}
+	}
+
+	return nil
+}
+
+// target returns the bits expected for the next entry on a branch forking
+// at fork, following hsd's per-block retarget.
+func (c *Chain) target(entry *Entry, branch []*Entry, fork *Entry) uint32 {
+	network := c.network
+	prev := c.ancestor(branch, fork, entry.Height-1)
+
+	if network.NoRetargeting {
+		return network.PowLimit
+	}
+
+	if network.TargetReset && int64(entry.Header.Time) > int64(prev.Header.Time)+network.TargetSpacing*2 {
+		return network.PowLimit
+	}
+
+	if prev.Height < network.TargetWindow+2 {
+		return network.PowLimit
+	}
+
+	last := c.suitable(branch, fork, prev)
+	first := c.suitable(branch, fork, c.ancestor(branch, fork, prev.Height-network.TargetWindow))
+
+	timespan := int64(last.Header.Time) - int64(first.Header.Time)
+	timespan = max(timespan, network.MinActual)
+	timespan = min(timespan, network.MaxActual)
+
+	work := new(big.Int).Sub(last.Work, first.Work)
+	work.Mul(work, big.NewInt(network.TargetSpacing))
+	work.Div(work, big.NewInt(timespan))
+
+	if work.Sign() == 0 {
+		return network.PowLimit
+	}
+
+	target := new(big.Int).Div(oneLsh256, work)
+	target.Sub(target, big.NewInt(1))
+
+	if target.Cmp(wire.CompactToBig(network.PowLimit)) > 0 {
+		return network.PowLimit
+	}
+
+	return wire.BigToCompact(target)
+}
+
+// suitable returns the entry with the median time of entry and its two
+// predecessors.
+func (c *Chain) suitable(branch []*Entry, fork *Entry, entry *Entry) *Entry {
+	z := entry
+	y := c.ancestor(branch, fork, z.Height-1)
+	x := c.ancestor(branch, fork, y.Height-1)
+
+	if x.Header.Time > z.Header.Time {
+		x, z = z, x
+	}
+
+	if x.Header.Time > y.Header.Time {
+		x, y = y, x
+	}
+
+	if y.Header.Time > z.Header.Time {
+		y = z
+	}
+
+	return y
+}
+
+// ancestor returns the entry at height on a branch forking at fork.
+func (c *Chain) ancestor(branch []*Entry, fork *Entry, height uint32) *Entry {
+	if height <= fork.Height {
+		return c.entries[height]
+	}
+
+	return branch[height-fork.Height-1]
+}
+
+// medianTime returns the median time of the last headers preceding the
+// next entry on a branch forking at fork.
+func (c *Chain) medianTime(branch []*Entry, fork *Entry) uint64 {
+	times := make([]uint64, 0, medianTimeSpan)
+
+	for i := len(branch) - 1; i >= 0 && len(times) < medianTimeSpan; i-- {
+		times = append(times, branch[i].Header.Time)
+	}
+
+	for h := int(fork.Height); h >= 0 && len(times) < medianTimeSpan; h-- {
+		times = append(times, c.entries[h].Header.Time)
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	return times[len(times)/2]
+}
+
+// headerWork returns the expected number of hashes for the target.
+func headerWork(bits uint32) *big.Int {
+	target := wire.CompactToBig(bits)
+
+	if target.Sign() <= 0 {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Div(oneLsh256, target.Add(target, big.NewInt(1)))
+}
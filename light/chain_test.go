@@ -0,0 +1,177 @@
+package light
+
+import (
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+func TestChainReorg(t *testing.T) {
+	chain := NewChain(testNetwork)
+	main := mineChain(testNetwork.Genesis, 20, proof.UrkelHash{1})
+
+	if n, err := chain.AddHeaders(main); err != nil || n != 20 {
+		t.Fatalf("AddHeaders failed: %d %v", n, err)
+	}
+
+	// Re-adding known headers is a no-op.
+	if n, err := chain.AddHeaders(main[10:]); err != nil || n != 0 {
+		t.Fatalf("Expected no-op, got %d %v", n, err)
+	}
+
+	// A shorter fork from height 10 does not replace the chain.
+	fork := *main[9]
+	short := mineChain(fork, 5, proof.UrkelHash{2})
+
+	if n, err := chain.AddHeaders(short); err != nil || n != 0 {
+		t.Fatalf("Short fork accepted: %d %v", n, err)
+	}
+
+	if chain.Tip().Hash != main[19].Hash() {
+		t.Errorf("Tip changed after short fork")
+	}
+
+	long := mineChain(fork, 15, proof.UrkelHash{3})
+
+	if n, err := chain.AddHeaders(long); err != nil || n != 15 {
+		t.Fatalf("Long fork rejected: %d %v", n, err)
+	}
+
+	if chain.Height() != 25 || chain.Tip().Hash != long[14].Hash() {
+		t.Errorf("Reorg failed, height %d", chain.Height())
+	}
+
+	if chain.HasEntry(main[15].Hash()) {
+		t.Errorf("Stale entry still indexed")
+	}
+
+	if chain.GetEntry(11).Hash != long[0].Hash() {
+		t.Errorf("Entry at fork height mismatch")
+	}
+
+	orphan := mineChain(wire.BlockHeader{Time: 1580745080}, 1, proof.UrkelHash{})
+
+	if _, err := chain.AddHeaders(orphan); err != ErrOrphan {
+		t.Errorf("Expected ErrOrphan, got %v", err)
+	}
+}
+
+func TestChainInvalid(t *testing.T) {
+	chain := NewChain(testNetwork)
+	headers := mineChain(testNetwork.Genesis, 3, proof.UrkelHash{})
+
+	old := *headers[2]
+	old.Time = testNetwork.Genesis.Time
+	old = mineHeader(old)
+
+	if _, err := chain.AddHeaders([]*wire.BlockHeader{headers[0], headers[1], &old}); err == nil {
+		t.Errorf("Expected time-too-old error")
+	}
+
+	easy := *headers[0]
+	easy.Bits = 0x2100ffff
+	easy = mineHeader(easy)
+
+	if _, err := chain.AddHeaders([]*wire.BlockHeader{&easy}); err == nil {
+		t.Errorf("Expected bad-diffbits error")
+	}
+
+	chain.now = func() int64 { return int64(testNetwork.Genesis.Time) }
+
+	if _, err := chain.AddHeaders(headers[:1]); err != nil {
+		t.Errorf("Header within future drift rejected: %s", err)
+	}
+
+	future := mineChain(*headers[0], 13, proof.UrkelHash{})
+
+	if _, err := chain.AddHeaders(future); err == nil {
+		t.Errorf("Expected time-too-new error")
+	}
+}
+
+func TestChainLocator(t *testing.T) {
+	chain := NewChain(testNetwork)
+	headers := mineChain(testNetwork.Genesis, 100, proof.UrkelHash{})
+
+	if _, err := chain.AddHeaders(headers); err != nil {
+		t.Fatal(err)
+	}
+
+	locator := chain.Locator()
+
+	if locator[0] != headers[99].Hash() {
+		t.Errorf("Locator does not start at the tip")
+	}
+
+	if locator[9] != headers[90].Hash() {
+		t.Errorf("Locator is not dense at the start")
+	}
+
+	if locator[len(locator)-1] != testNetwork.Genesis.Hash() {
+		t.Errorf("Locator does not end at genesis")
+	}
+
+	if len(locator) > 20 {
+		t.Errorf("Locator too long: %d", len(locator))
+	}
+}
+
+func TestChainSafeRoot(t *testing.T) {
+	chain := NewChain(testNetwork)
+	first := mineChain(testNetwork.Genesis, 20, proof.UrkelHash{1})
+	second := mineChain(*first[19], 5, proof.UrkelHash{2})
+
+	chain.AddHeaders(first)
+	chain.AddHeaders(second)
+
+	if chain.SafeRoot(0) != (proof.UrkelHash{2}) {
+		t.Errorf("Expected tip root without confirmations")
+	}
+
+	if chain.SafeRoot(5) != (proof.UrkelHash{2}) {
+		t.Errorf("Expected tip root with enough confirmations")
+	}
+
+	if chain.SafeRoot(6) != (proof.UrkelHash{1}) {
+		t.Errorf("Expected previous root with too few confirmations")
+	}
+}
+
+func TestChainRetarget(t *testing.T) {
+	chain := NewChain(testNetwork)
+	prev := testNetwork.Genesis
+
+	// Blocks twice as fast as the target spacing, at the pow limit until
+	// the window fills up.
+	next := func(bits uint32) *wire.BlockHeader {
+		header := mineHeader(wire.BlockHeader{
+			Time:      prev.Time + 300,
+			PrevBlock: prev.Hash(),
+			Bits:      bits,
+		})
+
+		return &header
+	}
+
+	for i := 0; i < 10; i++ {
+		header := next(testNetwork.PowLimit)
+
+		if _, err := chain.AddHeaders([]*wire.BlockHeader{header}); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		prev = *header
+	}
+
+	// Twice the work over the window halves the target.
+	_, err := chain.AddHeaders([]*wire.BlockHeader{next(testNetwork.PowLimit)})
+
+	if herr, ok := err.(*InvalidHeaderError); !ok || herr.Reason != "bad-diffbits" {
+		t.Fatalf("Expected bad-diffbits, got %v", err)
+	}
+
+	if n, err := chain.AddHeaders([]*wire.BlockHeader{next(0x203fffff)}); err != nil || n != 1 {
+		t.Fatalf("Retargeted header rejected: %d %v", n, err)
+	}
+}
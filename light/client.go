@@ -0,0 +1,243 @@
+package light
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+var (
+	ErrNoPeers  = errors.New("no peers available")
+	ErrBanned   = errors.New("peer is banned")
+	ErrBadProof = errors.New("peer sent an invalid proof")
+)
+
+// Client is an SPV client. It follows the header chain of its peers and
+// resolves names with proofs against the committed tree roots.
+type Client struct {
+	network *Network
+	chain   *Chain
+
+	// Timeout bounds each request to a single peer.
+	Timeout time.Duration
+
+	// Confirmations is the number of headers that must commit to a tree
+	// root before proofs are requested against it.
+	Confirmations uint32
+
+	mu     sync.Mutex
+	peers  []*Peer
+	banned map[string]struct{}
+}
+
+func NewClient(network *Network) *Client {
+	return &Client{
+		network: network,
+		chain:   NewChain(network),
+		Timeout: 10 * time.Second,
+		banned:  make(map[string]struct{}),
+	}
+}
+
+func (c *Client) Chain() *Chain {
+	return c.chain
+}
+
+// AddPeer performs the handshake over conn and adds the peer.
+func (c *Client) AddPeer(ctx context.Context, conn net.Conn) (*Peer, error) {
+	if c.IsBanned(conn.RemoteAddr()) {
+		conn.Close()
+		return nil, ErrBanned
+	}
+
+	peer := NewPeer(conn, c.network.Magic)
+
+	if err := peer.Handshake(ctx, c.chain.Height()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.peers = append(c.peers, peer)
+	c.mu.Unlock()
+
+	return peer, nil
+}
+
+// Peers returns the connected peers.
+func (c *Client) Peers() []*Peer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peers := make([]*Peer, 0, len(c.peers))
+
+	for _, peer := range c.peers {
+		select {
+		case <-peer.Done():
+		default:
+			peers = append(peers, peer)
+		}
+	}
+
+	c.peers = peers
+
+	return append([]*Peer(nil), peers...)
+}
+
+// Ban disconnects the peer and refuses future connections from its host.
+func (c *Client) Ban(peer *Peer) {
+	c.mu.Lock()
+	c.banned[banKey(peer.Addr())] = struct{}{}
+	c.mu.Unlock()
+
+	peer.Close()
+}
+
+func (c *Client) IsBanned(addr net.Addr) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.banned[banKey(addr)]
+
+	return ok
+}
+
+// Sync downloads headers from every peer until none of them has more.
+// Peers sending invalid headers are banned.
+func (c *Client) Sync(ctx context.Context) error {
+	peers := c.Peers()
+
+	if len(peers) == 0 {
+		return ErrNoPeers
+	}
+
+	for _, peer := range peers {
+		if err := c.syncPeer(ctx, peer); err != nil {
+			var invalid *InvalidHeaderError
+
+			if errors.As(err, &invalid) {
+				c.Ban(peer)
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			peer.Close()
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) syncPeer(ctx context.Context, peer *Peer) error {
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+		headers, err := peer.GetHeaders(reqCtx, c.chain.Locator())
+		cancel()
+
+		if err != nil {
+			return err
+		}
+
+		if len(headers) == 0 {
+			return nil
+		}
+
+		if _, err = c.chain.AddHeaders(headers); err != nil {
+			return err
+		}
+
+		if len(headers) < wire.MaxHeaders {
+			return nil
+		}
+	}
+}
+
+// GetProof requests a proof for key at the current safe root. Peers are
+// asked in turn until one returns a proof that verifies; peers returning
+// bad proofs are banned.
+func (c *Client) GetProof(ctx context.Context, key proof.UrkelHash) (*proof.Proof, proof.UrkelHash, error) {
+	root := c.chain.SafeRoot(c.Confirmations)
+	result := ErrNoPeers
+
+	for _, peer := range c.Peers() {
+		reqCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+		res, err := peer.GetProof(reqCtx, root, key)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, root, ctx.Err()
+			}
+
+			result = err
+			continue
+		}
+
+		if res.Root != root || res.Key != key {
+			c.Ban(peer)
+			result = ErrBadProof
+			continue
+		}
+
+		if code, _ := res.Proof.Verify(root, key); code != proof.ProofOk {
+			c.Ban(peer)
+			result = ErrBadProof
+			continue
+		}
+
+		return res.Proof, root, nil
+	}
+
+	return nil, root, result
+}
+
+// Resolve returns the name state stored for name, or nil if the name is
+// not in the tree.
+func (c *Client) Resolve(ctx context.Context, name string) ([]byte, error) {
+	key, err := proof.HashName(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, _, err := c.GetProof(ctx, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Type() != proof.ProofTypeExists {
+		return nil, nil
+	}
+
+	return p.Value(), nil
+}
+
+// Close disconnects all peers.
+func (c *Client) Close() {
+	for _, peer := range c.Peers() {
+		peer.Close()
+	}
+}
+
+func banKey(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
@@ -0,0 +1,383 @@
+package light
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+// testNetwork retargets over a short window so tests reach it quickly.
+var testNetwork = &Network{
+	Name:          "test",
+	Magic:         wire.MagicRegtest,
+	Genesis:       mineHeader(wire.BlockHeader{Time: 1580745080, Bits: 0x207fffff}),
+	PowLimit:      0x207fffff,
+	TargetWindow:  8,
+	TargetSpacing: 600,
+	MinActual:     8 * 600 / 4,
+	MaxActual:     8 * 600 * 4,
+}
+
+func mineHeader(header wire.BlockHeader) wire.BlockHeader {
+	for !header.VerifyPOW() {
+		header.Nonce++
+	}
+
+	return header
+}
+
+// mineChain returns count headers on top of prev, all committing to root.
+func mineChain(prev wire.BlockHeader, count int, root proof.UrkelHash) []*wire.BlockHeader {
+	headers := make([]*wire.BlockHeader, 0, count)
+
+	for i := 0; i < count; i++ {
+		header := mineHeader(wire.BlockHeader{
+			Time:      prev.Time + 600,
+			PrevBlock: prev.Hash(),
+			TreeRoot:  wire.Hash(root),
+			Bits:      0x207fffff,
+		})
+
+		headers = append(headers, &header)
+		prev = header
+	}
+
+	return headers
+}
+
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// fakePeer is a full node serving headers and proofs from memory.
+type fakePeer struct {
+	conn    net.Conn
+	headers []*wire.BlockHeader
+	tree    *urkel.Tree
+
+	// lie makes the peer serve proofs from a different tree.
+	lie *urkel.Tree
+}
+
+func (f *fakePeer) serve() {
+	defer f.conn.Close()
+
+	// Pipes are unbuffered, write from a separate goroutine so that both
+	// sides can send at the same time like they would over TCP.
+	outbox := make(chan wire.Packet, 16)
+	defer close(outbox)
+
+	go func() {
+		for pkt := range outbox {
+			if err := wire.WriteMessage(f.conn, testNetwork.Magic, pkt); err != nil {
+				f.conn.Close()
+			}
+		}
+	}()
+
+	for {
+		pkt, err := wire.ReadMessage(f.conn, testNetwork.Magic)
+
+		if err != nil {
+			return
+		}
+
+		var reply []wire.Packet
+
+		switch pkt := pkt.(type) {
+		case *wire.VersionPacket:
+			reply = append(reply,
+				&wire.VersionPacket{Version: wire.ProtocolVersion, Agent: "/fake/", Height: uint32(len(f.headers))},
+				&wire.VerackPacket{},
+				&wire.PingPacket{Nonce: [8]byte{1}},
+			)
+		case *wire.GetHeadersPacket:
+			reply = append(reply, &wire.HeadersPacket{Items: f.headersAfter(pkt.Locator)})
+		case *wire.GetProofPacket:
+			tree := f.tree
+
+			if f.lie != nil {
+				tree = f.lie
+			}
+
+			p, err := tree.Prove(pkt.Key)
+
+			if err != nil {
+				return
+			}
+
+			reply = append(reply, &wire.ProofPacket{Root: pkt.Root, Key: pkt.Key, Proof: p})
+		}
+
+		for _, r := range reply {
+			outbox <- r
+		}
+	}
+}
+
+func (f *fakePeer) headersAfter(locator []wire.Hash) []*wire.BlockHeader {
+	start := 0
+
+	for _, hash := range locator {
+		if hash == testNetwork.Genesis.Hash() {
+			break
+		}
+
+		found := false
+
+		for i, header := range f.headers {
+			if header.Hash() == hash {
+				start = i + 1
+				found = true
+				break
+			}
+		}
+
+		if found {
+			break
+		}
+	}
+
+	end := start + wire.MaxHeaders
+
+	if end > len(f.headers) {
+		end = len(f.headers)
+	}
+
+	return f.headers[start:end]
+}
+
+func connectFake(t *testing.T, client *Client, fake *fakePeer, ip string) *Peer {
+	local, remote := net.Pipe()
+	fake.conn = remote
+
+	go fake.serve()
+
+	conn := addrConn{local, &net.TCPAddr{IP: net.ParseIP(ip), Port: 12038}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	peer, err := client.AddPeer(ctx, conn)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return peer
+}
+
+func testTree(t *testing.T, names map[string]string) *urkel.Tree {
+	tree := urkel.New()
+
+	for name, value := range names {
+		key, err := proof.HashName(name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = tree.Insert(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tree
+}
+
+func TestClientResolve(t *testing.T) {
+	tree := testTree(t, map[string]string{
+		"handshake": "state-1",
+		"example":   "state-2",
+		"nodech":    "state-3",
+	})
+
+	headers := mineChain(testNetwork.Genesis, 2500, tree.RootHash())
+
+	client := NewClient(testNetwork)
+	defer client.Close()
+
+	connectFake(t, client, &fakePeer{headers: headers, tree: tree}, "10.0.0.1")
+
+	ctx := context.Background()
+
+	if err := client.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Chain().Height() != 2500 {
+		t.Fatalf("Height mismatch: %d != 2500", client.Chain().Height())
+	}
+
+	if client.Chain().Tip().Hash != headers[len(headers)-1].Hash() {
+		t.Errorf("Tip mismatch")
+	}
+
+	value, err := client.Resolve(ctx, "nodech")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(value) != "state-3" {
+		t.Errorf("Value mismatch: %q", value)
+	}
+
+	value, err = client.Resolve(ctx, "missing")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != nil {
+		t.Errorf("Expected no value for missing name, got %q", value)
+	}
+
+	if _, err = client.Resolve(ctx, "-invalid"); err == nil {
+		t.Errorf("Expected invalid name error")
+	}
+}
+
+func TestClientBanBadProof(t *testing.T) {
+	names := map[string]string{"handshake": "state-1"}
+	tree := testTree(t, names)
+	lie := testTree(t, map[string]string{"handshake": "forged"})
+	headers := mineChain(testNetwork.Genesis, 10, tree.RootHash())
+
+	client := NewClient(testNetwork)
+	defer client.Close()
+
+	liar := connectFake(t, client, &fakePeer{headers: headers, tree: tree, lie: lie}, "10.0.0.1")
+	connectFake(t, client, &fakePeer{headers: headers, tree: tree}, "10.0.0.2")
+
+	ctx := context.Background()
+
+	if err := client.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := client.Resolve(ctx, "handshake")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(value) != "state-1" {
+		t.Errorf("Value mismatch: %q", value)
+	}
+
+	if !client.IsBanned(liar.Addr()) {
+		t.Errorf("Lying peer was not banned")
+	}
+
+	if len(client.Peers()) != 1 {
+		t.Errorf("Expected one remaining peer, got %d", len(client.Peers()))
+	}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	if _, err = client.AddPeer(ctx, addrConn{local, liar.Addr()}); err != ErrBanned {
+		t.Errorf("Expected ErrBanned, got %v", err)
+	}
+}
+
+func TestClientBanBadHeaders(t *testing.T) {
+	tree := testTree(t, nil)
+	headers := mineChain(testNetwork.Genesis, 10, tree.RootHash())
+
+	bad := *headers[5]
+
+	for bad.VerifyPOW() {
+		bad.Nonce++
+	}
+
+	badHeaders := append(append([]*wire.BlockHeader{}, headers[:5]...), &bad)
+
+	client := NewClient(testNetwork)
+	defer client.Close()
+
+	cheat := connectFake(t, client, &fakePeer{headers: badHeaders, tree: tree}, "10.0.0.1")
+
+	if err := client.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !client.IsBanned(cheat.Addr()) {
+		t.Errorf("Peer with invalid headers was not banned")
+	}
+
+	if client.Chain().Height() != 0 {
+		t.Errorf("Invalid batch was partially connected")
+	}
+
+	if err := client.Sync(context.Background()); err != ErrNoPeers {
+		t.Errorf("Expected ErrNoPeers, got %v", err)
+	}
+}
+
+func TestClientBanBadBits(t *testing.T) {
+	tree := testTree(t, nil)
+	headers := mineChain(testNetwork.Genesis, 10, tree.RootHash())
+
+	// A valid proof of work, but not the retarget's difficulty.
+	bad := *headers[5]
+	bad.Bits = 0x2000ffff
+	bad = mineHeader(bad)
+
+	badHeaders := append(append([]*wire.BlockHeader{}, headers[:5]...), &bad)
+
+	client := NewClient(testNetwork)
+	defer client.Close()
+
+	cheat := connectFake(t, client, &fakePeer{headers: badHeaders, tree: tree}, "10.0.0.1")
+
+	if err := client.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !client.IsBanned(cheat.Addr()) {
+		t.Errorf("Peer with wrong bits was not banned")
+	}
+
+	if client.Chain().Height() != 0 {
+		t.Errorf("Invalid batch was partially connected")
+	}
+}
+
+func TestPeerHandshakeCancel(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	// The remote never reads or writes, so the handshake blocks until the
+	// context is cancelled, which has no deadline.
+	ctx, cancel := context.WithCancel(context.Background())
+	peer := NewPeer(local, testNetwork.Magic)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- peer.Handshake(ctx, 0)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handshake ignored the cancelled context")
+	}
+}
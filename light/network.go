@@ -0,0 +1,52 @@
+package light
+
+import (
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+// Network holds the consensus parameters the light client needs.
+type Network struct {
+	Name    string
+	Magic   uint32
+	Port    int
+	Genesis wire.BlockHeader
+
+	// PowLimit is the easiest allowed target in compact form.
+	PowLimit uint32
+
+	// The target is recomputed every block from the work and time of the
+	// last TargetWindow blocks, with the timespan clamped to
+	// [MinActual, MaxActual] seconds.
+	TargetWindow  uint32
+	TargetSpacing int64
+	MinActual     int64
+	MaxActual     int64
+
+	// TargetReset allows PowLimit after TargetSpacing*2 seconds without a
+	// block. NoRetargeting always requires PowLimit.
+	TargetReset   bool
+	NoRetargeting bool
+}
+
+var MainNet = &Network{
+	Name:  "main",
+	Magic: wire.MagicMain,
+	Port:  12038,
+	Genesis: wire.BlockHeader{
+		Time: 1580745078,
+		Bits: 0x1c00ffff,
+		MerkleRoot: wire.Hash{
+			0x8e, 0x4c, 0x97, 0x56, 0xfe, 0xf2, 0xad, 0x10, 0x37, 0x5f, 0x36, 0x0e, 0x05, 0x60, 0xfc, 0xc7,
+			0x58, 0x7e, 0xb5, 0x22, 0x3d, 0xdf, 0x8c, 0xd7, 0xc7, 0xe0, 0x6e, 0x60, 0xa1, 0x14, 0x0b, 0x15,
+		},
+		WitnessRoot: wire.Hash{
+			0x1a, 0x2c, 0x60, 0xb9, 0x43, 0x92, 0x06, 0x93, 0x8f, 0x8d, 0x78, 0x23, 0x78, 0x2a, 0xbd, 0xb8,
+			0xb2, 0x11, 0xa5, 0x74, 0x31, 0xe9, 0xc9, 0xb6, 0xa6, 0x36, 0x5d, 0x8d, 0x42, 0x89, 0x33, 0x51,
+		},
+	},
+	PowLimit:      0x1c00ffff,
+	TargetWindow:  144,
+	TargetSpacing: 10 * 60,
+	MinActual:     144 * 10 * 60 / 4,
+	MaxActual:     144 * 10 * 60 * 4,
+}
@@ -0,0 +1,222 @@
+package light
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+const (
+	UserAgent = "/go-hsd-utils:0.0.0/"
+
+	inboxSize = 64
+)
+
+var (
+	ErrPeerClosed = errors.New("peer closed")
+)
+
+// Peer is a connection to a single full node.
+type Peer struct {
+	conn  net.Conn
+	magic uint32
+
+	// Version is the version packet the remote sent during the handshake.
+	Version *wire.VersionPacket
+
+	writeMu sync.Mutex
+	reqMu   sync.Mutex
+
+	inbox  chan wire.Packet
+	closed chan struct{}
+	once   sync.Once
+	err    error
+}
+
+func NewPeer(conn net.Conn, magic uint32) *Peer {
+	return &Peer{
+		conn:   conn,
+		magic:  magic,
+		inbox:  make(chan wire.Packet, inboxSize),
+		closed: make(chan struct{}),
+	}
+}
+
+func (p *Peer) Addr() net.Addr {
+	return p.conn.RemoteAddr()
+}
+
+// Handshake exchanges version and verack, then starts reading packets in
+// the background. Cancelling ctx aborts the handshake but leaves the
+// connection to the caller.
+func (p *Peer) Handshake(ctx context.Context, height uint32) error {
+	// A past deadline unblocks any pending read or write.
+	stop := context.AfterFunc(ctx, func() {
+		p.conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	err := p.handshake(height)
+
+	if !stop() {
+		return ctx.Err()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	go p.readLoop()
+
+	return nil
+}
+
+func (p *Peer) handshake(height uint32) error {
+	version := &wire.VersionPacket{
+		Version: wire.ProtocolVersion,
+		Time:    uint64(time.Now().Unix()),
+		Agent:   UserAgent,
+		Height:  height,
+		NoRelay: true,
+	}
+
+	if _, err := rand.Read(version.Nonce[:]); err != nil {
+		return err
+	}
+
+	if err := p.Send(version); err != nil {
+		return err
+	}
+
+	gotVerack := false
+
+	for p.Version == nil || !gotVerack {
+		pkt, err := wire.ReadMessage(p.conn, p.magic)
+
+		if err != nil {
+			return err
+		}
+
+		switch pkt := pkt.(type) {
+		case *wire.VersionPacket:
+			if p.Version != nil {
+				return errors.New("duplicate version")
+			}
+
+			p.Version = pkt
+
+			if err = p.Send(&wire.VerackPacket{}); err != nil {
+				return err
+			}
+		case *wire.VerackPacket:
+			gotVerack = true
+		}
+	}
+
+	return nil
+}
+
+func (p *Peer) readLoop() {
+	for {
+		pkt, err := wire.ReadMessage(p.conn, p.magic)
+
+		if err != nil {
+			p.closeWith(err)
+			return
+		}
+
+		switch pkt := pkt.(type) {
+		case *wire.PingPacket:
+			p.Send(&wire.PongPacket{Nonce: pkt.Nonce})
+		default:
+			select {
+			case p.inbox <- pkt:
+			default:
+				// Nobody is waiting for it, drop it.
+			}
+		}
+	}
+}
+
+func (p *Peer) Send(pkt wire.Packet) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	return wire.WriteMessage(p.conn, p.magic, pkt)
+}
+
+// request sends pkt and waits for the first packet of type want.
+func (p *Peer) request(ctx context.Context, pkt wire.Packet, want wire.PacketType) (wire.Packet, error) {
+	p.reqMu.Lock()
+	defer p.reqMu.Unlock()
+
+	// Drop anything left over from an earlier request.
+	for len(p.inbox) > 0 {
+		<-p.inbox
+	}
+
+	if err := p.Send(pkt); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case res := <-p.inbox:
+			if res.Type() == want {
+				return res, nil
+			}
+		case <-p.closed:
+			return nil, p.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *Peer) GetHeaders(ctx context.Context, locator []wire.Hash) ([]*wire.BlockHeader, error) {
+	res, err := p.request(ctx, &wire.GetHeadersPacket{
+		Locator: locator,
+	}, wire.PacketHeaders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*wire.HeadersPacket).Items, nil
+}
+
+func (p *Peer) GetProof(ctx context.Context, root, key proof.UrkelHash) (*wire.ProofPacket, error) {
+	res, err := p.request(ctx, &wire.GetProofPacket{
+		Root: root,
+		Key:  key,
+	}, wire.PacketProof)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*wire.ProofPacket), nil
+}
+
+func (p *Peer) Close() error {
+	p.closeWith(ErrPeerClosed)
+	return nil
+}
+
+// Done is closed once the peer disconnects.
+func (p *Peer) Done() <-chan struct{} {
+	return p.closed
+}
+
+func (p *Peer) closeWith(err error) {
+	p.once.Do(func() {
+		p.err = err
+		p.conn.Close()
+		close(p.closed)
+	})
+}
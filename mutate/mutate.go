@@ -0,0 +1,323 @@
+// Package mutate derives corrupted proofs from a valid one, one for each
+// failure code of urkel proof verification.
+//
+// Mutations are deterministic: the same proof, root and key always give
+// the same results. Every mutation is checked against proof.Verify before
+// it is returned, so a verifier that claims hsd compatibility must report
+// exactly Code for each of them.
+package mutate
+
+import (
+	"errors"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+var (
+	ErrInvalidProof  = errors.New("mutate: proof does not verify")
+	ErrNotApplicable = errors.New("mutate: mutation does not apply to this proof")
+	ErrUnknownCode   = errors.New("mutate: no mutation for code")
+)
+
+// Codes are the failure codes mutations target, in order.
+var Codes = []proof.UrkelCode{
+	proof.ProofHashMismatch,
+	proof.ProofSameKey,
+	proof.ProofSamePath,
+	proof.ProofNegDepth,
+	proof.ProofPathMismatch,
+	proof.ProofTooDeep,
+	proof.ProofInvalid,
+}
+
+// Mutation is a corrupted proof and the root and key it must be verified
+// against to produce Code.
+type Mutation struct {
+	Name  string
+	Code  proof.UrkelCode
+	Proof *proof.Proof
+	Root  proof.UrkelHash
+	Key   proof.UrkelHash
+}
+
+type mutator func(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error)
+
+var mutators = map[proof.UrkelCode]mutator{
+	proof.ProofHashMismatch: hashMismatch,
+	proof.ProofSameKey:      sameKey,
+	proof.ProofSamePath:     samePath,
+	proof.ProofNegDepth:     negDepth,
+	proof.ProofPathMismatch: pathMismatch,
+	proof.ProofTooDeep:      tooDeep,
+	proof.ProofInvalid:      invalid,
+}
+
+// For returns the mutation of p that verifies to code. p must verify
+// against root and key.
+func For(code proof.UrkelCode, p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	return ForWith(proof.DefaultHasher(), code, p, root, key)
+}
+
+// ForWith is For for trees hashed with hasher.
+func ForWith(hasher proof.Hasher, code proof.UrkelCode, p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	fn, ok := mutators[code]
+
+	if !ok {
+		return nil, ErrUnknownCode
+	}
+
+	if res, _ := p.VerifyWith(hasher, root, key); res != proof.ProofOk {
+		return nil, ErrInvalidProof
+	}
+
+	m, err := fn(p, root, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res, _ := m.Proof.VerifyWith(hasher, m.Root, m.Key); res != code {
+		return nil, ErrNotApplicable
+	}
+
+	m.Code = code
+
+	return m, nil
+}
+
+// All returns a mutation for every code in Codes that applies to p.
+func All(p *proof.Proof, root, key proof.UrkelHash) ([]*Mutation, error) {
+	return AllWith(proof.DefaultHasher(), p, root, key)
+}
+
+// AllWith is All for trees hashed with hasher.
+func AllWith(hasher proof.Hasher, p *proof.Proof, root, key proof.UrkelHash) ([]*Mutation, error) {
+	var out []*Mutation
+
+	for _, code := range Codes {
+		m, err := ForWith(hasher, code, p, root, key)
+
+		if err == ErrNotApplicable {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+// hashMismatch flips a bit of the hash closest to the root. A dead end
+// without nodes has nothing to flip, so the root is changed instead.
+func hashMismatch(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	nodes := p.Nodes()
+
+	if len(nodes) > 0 {
+		nodes = copyNodes(nodes)
+		hash := nodes[0].Hash()
+		hash[len(hash)-1] ^= 1
+		nodes[0] = proof.NewProofNode(nodes[0].Prefix(), hash)
+
+		out := p.WithDepthAndNodes(p.Depth(), nodes)
+
+		return &Mutation{Name: "flip-node-hash", Proof: out, Root: root, Key: key}, nil
+	}
+
+	var out *proof.Proof
+	var err error
+
+	name := "flip-leaf"
+
+	switch p.Type() {
+	case proof.ProofTypeShort:
+		left := p.Left()
+		left[len(left)-1] ^= 1
+		out = proof.NewShort(p.Depth(), p.Prefix(), left, p.Right())
+	case proof.ProofTypeCollision:
+		hash := p.Hash()
+		hash[len(hash)-1] ^= 1
+		out = proof.NewCollision(p.Depth(), p.Key(), hash)
+	case proof.ProofTypeExists:
+		value := append([]byte(nil), p.Value()...)
+
+		if len(value) > 0 {
+			value[len(value)-1] ^= 1
+		} else {
+			value = []byte{0}
+		}
+
+		out, err = proof.NewExists(p.Depth(), value)
+	default:
+		out = p.WithDepthAndNodes(p.Depth(), nil)
+		name = "flip-root"
+		root[len(root)-1] ^= 1
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mutation{Name: name, Proof: out, Root: root, Key: key}, nil
+}
+
+// sameKey replaces the leaf with a collision on the key being proven.
+func sameKey(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	out := proof.NewCollision(p.Depth(), key, p.Hash())
+	pushNodes(out, p.Nodes())
+
+	return &Mutation{Name: "collide-key", Proof: out, Root: root, Key: key}, nil
+}
+
+// samePath replaces the leaf with a short proof whose prefix the key
+// follows.
+func samePath(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	if p.Depth() >= proof.UrkelKeyBits {
+		return nil, ErrNotApplicable
+	}
+
+	prefix, err := proof.NewBitsFromKey(key, p.Depth(), 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := proof.NewShort(p.Depth(), *prefix, p.Left(), p.Right())
+	pushNodes(out, p.Nodes())
+
+	return &Mutation{Name: "short-on-path", Proof: out, Root: root, Key: key}, nil
+}
+
+// negDepth grows the prefix of the node closest to the leaf past the
+// depth that is left.
+func negDepth(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	nodes := copyNodes(p.Nodes())
+
+	if len(nodes) == 0 {
+		nodes = append(nodes, proof.NewProofNode(proof.Bits{}, proof.UrkelHash{}))
+	}
+
+	prefix, err := proof.NewBitsFromKey(key, 0, p.Depth())
+
+	if err != nil {
+		return nil, err
+	}
+
+	last := len(nodes) - 1
+	nodes[last] = proof.NewProofNode(*prefix, nodes[last].Hash())
+
+	out := p.WithDepthAndNodes(p.Depth(), nodes)
+
+	return &Mutation{Name: "long-prefix", Proof: out, Root: root, Key: key}, nil
+}
+
+// pathMismatch flips a bit in the first node prefix. Without prefixes, a
+// root node with a one bit prefix off the key's path is added instead.
+func pathMismatch(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	nodes := copyNodes(p.Nodes())
+
+	for i, node := range nodes {
+		prefix := node.Prefix()
+
+		if prefix.Size() == 0 {
+			continue
+		}
+
+		nodes[i] = proof.NewProofNode(flipFirst(prefix), node.Hash())
+
+		out := p.WithDepthAndNodes(p.Depth(), nodes)
+
+		return &Mutation{Name: "flip-prefix", Proof: out, Root: root, Key: key}, nil
+	}
+
+	if p.Depth()+2 > proof.UrkelKeyBits {
+		return nil, ErrNotApplicable
+	}
+
+	prefix, err := proof.NewBitsFromKey(key, 0, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodes = append([]*proof.ProofNode{proof.NewProofNode(flipFirst(*prefix), proof.UrkelHash{})}, nodes...)
+	depth := p.Depth() + 2
+
+	// Moving the leaf down could put a short proof's prefix on the key's
+	// path, so it is replaced with one bit off the path.
+	if p.Type() == proof.ProofTypeShort {
+		if depth >= proof.UrkelKeyBits {
+			return nil, ErrNotApplicable
+		}
+
+		if prefix, err = proof.NewBitsFromKey(key, depth, 1); err != nil {
+			return nil, err
+		}
+
+		out := proof.NewShort(depth, flipFirst(*prefix), p.Left(), p.Right())
+		pushNodes(out, nodes)
+
+		return &Mutation{Name: "off-path-root", Proof: out, Root: root, Key: key}, nil
+	}
+
+	out := p.WithDepthAndNodes(depth, nodes)
+
+	return &Mutation{Name: "off-path-root", Proof: out, Root: root, Key: key}, nil
+}
+
+// tooDeep drops the root node, so the path ends below the root.
+func tooDeep(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	nodes := p.Nodes()
+
+	if len(nodes) > 0 {
+		out := p.WithDepthAndNodes(p.Depth(), nodes[1:])
+
+		return &Mutation{Name: "drop-root-node", Proof: out, Root: root, Key: key}, nil
+	}
+
+	if p.Depth() >= proof.UrkelKeyBits {
+		return nil, ErrNotApplicable
+	}
+
+	out := p.WithDepthAndNodes(p.Depth()+1, nil)
+
+	return &Mutation{Name: "grow-depth", Proof: out, Root: root, Key: key}, nil
+}
+
+// invalid sets a depth past the key size, which fails the sanity check.
+// Such a proof serializes, but hsd refuses to decode it.
+func invalid(p *proof.Proof, root, key proof.UrkelHash) (*Mutation, error) {
+	out := p.WithDepthAndNodes(proof.UrkelKeyBits+1, p.Nodes())
+
+	return &Mutation{Name: "depth-overflow", Proof: out, Root: root, Key: key}, nil
+}
+
+// flipFirst returns a copy of prefix with its first bit flipped.
+func flipFirst(prefix proof.Bits) proof.Bits {
+	out, _ := proof.NewBitsFromSize(prefix.Size())
+
+	for i := 0; i < prefix.Size(); i++ {
+		bit := prefix.GetBit(i)
+
+		if i == 0 {
+			bit ^= 1
+		}
+
+		out.SetBit(i, bit)
+	}
+
+	return *out
+}
+
+func pushNodes(p *proof.Proof, nodes []*proof.ProofNode) {
+	for _, node := range nodes {
+		p.Push(node.Prefix(), node.Hash())
+	}
+}
+
+func copyNodes(nodes []*proof.ProofNode) []*proof.ProofNode {
+	return append([]*proof.ProofNode(nil), nodes...)
+}
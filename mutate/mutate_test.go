@@ -0,0 +1,175 @@
+package mutate
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+func randomKey(rng *rand.Rand) proof.UrkelHash {
+	var key proof.UrkelHash
+	rng.Read(key[:])
+	return key
+}
+
+func buildTree(t *testing.T, hasher proof.Hasher, seed int64, count int) (*urkel.Tree, []proof.UrkelHash) {
+	rng := rand.New(rand.NewSource(seed))
+	tree := urkel.NewWithHasher(hasher)
+	keys := make([]proof.UrkelHash, count)
+
+	for i := range keys {
+		keys[i] = randomKey(rng)
+		value := make([]byte, 1+rng.Intn(32))
+		rng.Read(value)
+
+		if err := tree.Insert(keys[i], value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tree, keys
+}
+
+func checkAll(t *testing.T, hasher proof.Hasher, tree *urkel.Tree, key proof.UrkelHash) {
+	t.Helper()
+
+	root := tree.RootHash()
+	p, err := tree.Prove(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutations, err := AllWith(hasher, p, root, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mutations) != len(Codes) {
+		t.Fatalf("%s: expected %d mutations, got %d", p.Type(), len(Codes), len(mutations))
+	}
+
+	for i, m := range mutations {
+		if m.Code != Codes[i] {
+			t.Fatalf("expected %s, got %s", Codes[i], m.Code)
+		}
+
+		if code, _ := m.Proof.VerifyWith(hasher, m.Root, m.Key); code != m.Code {
+			t.Fatalf("%s: verify returned %s, expected %s", m.Name, code, m.Code)
+		}
+
+		// The original proof is left untouched.
+		if code, _ := p.VerifyWith(hasher, root, key); code != proof.ProofOk {
+			t.Fatalf("%s: mutated the original proof", m.Name)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	tree, keys := buildTree(t, proof.DefaultHasher(), 1, 100)
+	rng := rand.New(rand.NewSource(2))
+	seen := make(map[proof.ProofType]bool)
+
+	for _, key := range keys[:20] {
+		checkAll(t, proof.DefaultHasher(), tree, key)
+		seen[proof.ProofTypeExists] = true
+	}
+
+	for i := 0; i < 50; i++ {
+		key := randomKey(rng)
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkAll(t, proof.DefaultHasher(), tree, key)
+		seen[p.Type()] = true
+	}
+
+	if !seen[proof.ProofTypeShort] || !seen[proof.ProofTypeCollision] {
+		t.Fatal("random keys did not reach short and collision proofs")
+	}
+}
+
+func TestSmallTrees(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+
+	// Dead end and proofs without nodes.
+	for _, count := range []int{0, 1, 2} {
+		tree, keys := buildTree(t, proof.DefaultHasher(), int64(count), count)
+
+		checkAll(t, proof.DefaultHasher(), tree, randomKey(rng))
+
+		for _, key := range keys {
+			checkAll(t, proof.DefaultHasher(), tree, key)
+		}
+	}
+}
+
+func TestHasher(t *testing.T) {
+	tree, keys := buildTree(t, proof.SHA256, 4, 50)
+
+	for _, key := range keys[:10] {
+		checkAll(t, proof.SHA256, tree, key)
+	}
+
+	p, err := tree.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := All(p, tree.RootHash(), keys[0]); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	tree, keys := buildTree(t, proof.DefaultHasher(), 5, 50)
+	p, err := tree.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := All(p, tree.RootHash(), keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := All(p, tree.RootHash(), keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range a {
+		var x, y bytes.Buffer
+
+		if err := a[i].Proof.Serialize(&x); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b[i].Proof.Serialize(&y); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(x.Bytes(), y.Bytes()) || a[i].Key != b[i].Key || a[i].Root != b[i].Root {
+			t.Fatalf("%s differs between runs", a[i].Name)
+		}
+	}
+}
+
+func TestUnknownCode(t *testing.T) {
+	p := proof.NewDeadEnd(0)
+
+	if _, err := For(proof.ProofOk, p, proof.UrkelHash{}, proof.UrkelHash{}); err != ErrUnknownCode {
+		t.Fatalf("expected ErrUnknownCode, got %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package proof
+
+import (
+	"errors"
+)
+
+var (
+	ErrSiblingRequired = errors.New("sibling proof required")
+	ErrSiblingMismatch = errors.New("sibling proof does not match")
+)
+
+// ApplyInsert returns the root after setting key to value in the tree the
+// proof was created for. The proof must be for key and already verified.
+func (p *Proof) ApplyInsert(key UrkelHash, value []byte) (UrkelHash, error) {
+	return p.ApplyInsertWith(DefaultHasher(), key, value)
+}
+
+// ApplyInsertWith is ApplyInsert for trees hashed with hasher.
+func (p *Proof) ApplyInsertWith(hasher Hasher, key UrkelHash, value []byte) (UrkelHash, error) {
+	var root UrkelHash
+
+	if !p.IsSane() {
+		return root, errors.New("invalid proof")
+	}
+
+	if len(value) > UrkelValueSize {
+		return root, errors.New("value too long")
+	}
+
+	vhash, err := hashData(hasher, value)
+
+	if err != nil {
+		return root, err
+	}
+
+	leaf, err := hashLeaf(hasher, key, vhash)
+
+	if err != nil {
+		return root, err
+	}
+
+	next := leaf
+
+	switch p.ptype {
+	case ProofTypeDeadEnd, ProofTypeExists:
+		// The new leaf takes the place of the empty subtree or the old
+		// leaf.
+	case ProofTypeCollision:
+		if p.key == key {
+			return root, errors.New("collision with the same key")
+		}
+
+		other, err := hashLeaf(hasher, p.key, p.hash)
+
+		if err != nil {
+			return root, err
+		}
+
+		bits := 0
+
+		for p.depth+bits < UrkelKeyBits && hasBit(p.key[:], p.depth+bits) == hasBit(key[:], p.depth+bits) {
+			bits++
+		}
+
+		var prefix Bits
+
+		if err := prefix.FromKey(key, p.depth, bits); err != nil {
+			return root, err
+		}
+
+		if next, err = hashBranch(hasher, prefix, key, p.depth+bits, leaf, other); err != nil {
+			return root, err
+		}
+	case ProofTypeShort:
+		bits := p.prefix.Count(key, p.depth)
+
+		if bits == p.prefix.size {
+			return root, errors.New("key follows the short prefix")
+		}
+
+		front, err := p.prefix.Slice(0, bits)
+
+		if err != nil {
+			return root, err
+		}
+
+		back, err := p.prefix.Slice(bits+1, p.prefix.size)
+
+		if err != nil {
+			return root, err
+		}
+
+		old, err := hashInternal(hasher, back, p.left, p.right)
+
+		if err != nil {
+			return root, err
+		}
+
+		if next, err = hashBranch(hasher, front, key, p.depth+bits, leaf, old); err != nil {
+			return root, err
+		}
+	default:
+		return root, errors.New("invalid proof type")
+	}
+
+	return fold(hasher, key, next, p.nodes, p.depth)
+}
+
+// ApplyRemove returns the root after removing key from the tree the proof
+// was created for. The proof must be for key and already verified.
+//
+// Removing a leaf collapses its parent into the sibling subtree. If the
+// sibling is an internal node its skip prefix is not part of the proof, so
+// a verified proof for SiblingKey(key) against the same root has to be
+// passed. It may be nil when the key is absent or is the only leaf.
+func (p *Proof) ApplyRemove(key UrkelHash, sibling *Proof) (UrkelHash, error) {
+	return p.ApplyRemoveWith(DefaultHasher(), key, sibling)
+}
+
+// ApplyRemoveWith is ApplyRemove for trees hashed with hasher.
+func (p *Proof) ApplyRemoveWith(hasher Hasher, key UrkelHash, sibling *Proof) (UrkelHash, error) {
+	var root UrkelHash
+
+	if !p.IsSane() {
+		return root, errors.New("invalid proof")
+	}
+
+	if p.ptype != ProofTypeExists {
+		// Nothing to remove, the root stays the same.
+		leaf, err := p.leafHash(hasher, key)
+
+		if err != nil {
+			return root, err
+		}
+
+		return fold(hasher, key, leaf, p.nodes, p.depth)
+	}
+
+	count := len(p.nodes)
+
+	if count == 0 {
+		return root, nil
+	}
+
+	if sibling == nil {
+		return root, ErrSiblingRequired
+	}
+
+	parent := p.nodes[count-1]
+	branch := p.depth - 1
+	start := branch - parent.prefix.size
+	bit := getBit(key[:], branch) ^ 1
+
+	skey, ok := p.SiblingKey(key)
+
+	if !ok || len(sibling.nodes) < count {
+		return root, ErrSiblingMismatch
+	}
+
+	for i := 0; i < count-1; i++ {
+		if sibling.nodes[i].hash != p.nodes[i].hash {
+			return root, ErrSiblingMismatch
+		}
+	}
+
+	side, err := sibling.subtree(hasher, skey, count)
+
+	if err != nil {
+		return root, err
+	}
+
+	if side.hash != parent.hash {
+		return root, ErrSiblingMismatch
+	}
+
+	next := side.hash
+
+	// An internal sibling absorbs the parent's prefix and the branch bit.
+	if side.internal {
+		branch := Bits{size: 1}
+		branch.SetBit(0, bit)
+
+		prefix, err := parent.prefix.Join(branch)
+
+		if err != nil {
+			return root, err
+		}
+
+		if prefix, err = prefix.Join(side.prefix); err != nil {
+			return root, err
+		}
+
+		if next, err = hashInternal(hasher, prefix, side.left, side.right); err != nil {
+			return root, err
+		}
+	}
+
+	return fold(hasher, key, next, p.nodes[:count-1], start)
+}
+
+// SiblingKey returns a key in the subtree next to key's leaf: key with the
+// bit of the last branch flipped. It is false if the proof has no nodes.
+func (p *Proof) SiblingKey(key UrkelHash) (UrkelHash, bool) {
+	if len(p.nodes) == 0 || p.depth == 0 {
+		return key, false
+	}
+
+	index := p.depth - 1
+	key[index>>3] ^= 1 << (7 - (index & 7))
+
+	return key, true
+}
+
+// subtreeNode describes the subtree a proof reaches after index nodes.
+type subtreeNode struct {
+	hash     UrkelHash
+	internal bool
+	prefix   Bits
+	left     UrkelHash
+	right    UrkelHash
+}
+
+func (p *Proof) subtree(hasher Hasher, key UrkelHash, index int) (*subtreeNode, error) {
+	leaf, err := p.leafHash(hasher, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if index == len(p.nodes) {
+		node := &subtreeNode{hash: leaf}
+
+		switch p.ptype {
+		case ProofTypeShort:
+			node.internal = true
+			node.prefix = p.prefix
+			node.left = p.left
+			node.right = p.right
+		case ProofTypeDeadEnd:
+			return nil, ErrSiblingMismatch
+		}
+
+		return node, nil
+	}
+
+	// Hash up to the child of the node at index.
+	next := leaf
+	depth := p.depth
+
+	for i := len(p.nodes) - 1; i > index; i-- {
+		if next, depth, err = foldNode(hasher, key, next, p.nodes[i], depth); err != nil {
+			return nil, err
+		}
+	}
+
+	node := p.nodes[index]
+
+	if depth < node.prefix.size+1 {
+		return nil, errors.New("negative depth")
+	}
+
+	result := &subtreeNode{
+		internal: true,
+		prefix:   node.prefix,
+	}
+
+	if hasBit(key[:], depth-1) {
+		result.left = node.hash
+		result.right = next
+	} else {
+		result.left = next
+		result.right = node.hash
+	}
+
+	if result.hash, err = hashInternal(hasher, node.prefix, result.left, result.right); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// leafHash returns the hash of the subtree the proof ends at.
+func (p *Proof) leafHash(hasher Hasher, key UrkelHash) (UrkelHash, error) {
+	switch p.ptype {
+	case ProofTypeDeadEnd:
+		return UrkelHash{}, nil
+	case ProofTypeShort:
+		return hashInternal(hasher, p.prefix, p.left, p.right)
+	case ProofTypeCollision:
+		return hashLeaf(hasher, p.key, p.hash)
+	case ProofTypeExists:
+		return hashValue(hasher, key, p.value, p.valueSize)
+	}
+
+	return UrkelHash{}, errors.New("invalid proof type")
+}
+
+// fold hashes a subtree at depth up through nodes to the root.
+func fold(hasher Hasher, key UrkelHash, next UrkelHash, nodes []*ProofNode, depth int) (UrkelHash, error) {
+	var err error
+
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if next, depth, err = foldNode(hasher, key, next, nodes[i], depth); err != nil {
+			return UrkelHash{}, err
+		}
+	}
+
+	if depth != 0 {
+		return UrkelHash{}, errors.New("proof too deep")
+	}
+
+	return next, nil
+}
+
+func foldNode(hasher Hasher, key UrkelHash, next UrkelHash, node *ProofNode, depth int) (UrkelHash, int, error) {
+	var err error
+
+	if depth < node.prefix.size+1 {
+		return next, depth, errors.New("negative depth")
+	}
+
+	depth -= 1
+
+	if hasBit(key[:], depth) {
+		next, err = hashInternal(hasher, node.prefix, node.hash, next)
+	} else {
+		next, err = hashInternal(hasher, node.prefix, next, node.hash)
+	}
+
+	return next, depth - node.prefix.size, err
+}
+
+// hashBranch hashes an internal node whose children are split on the bit
+// of key at depth, with ours on key's side.
+func hashBranch(hasher Hasher, prefix Bits, key UrkelHash, depth int, ours UrkelHash, other UrkelHash) (UrkelHash, error) {
+	if hasBit(key[:], depth) {
+		return hashInternal(hasher, prefix, other, ours)
+	}
+
+	return hashInternal(hasher, prefix, ours, other)
+}
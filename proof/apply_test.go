@@ -0,0 +1,189 @@
+package proof_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+func TestApplyInsert(t *testing.T) {
+	keys, values := randomItems(7, 200)
+	tree := buildTree(t, keys[:100], values[:100])
+	rng := rand.New(rand.NewSource(8))
+
+	// Fresh keys exercise dead ends, shorts and collisions, existing keys
+	// exercise value replacement.
+	for i := 0; i < 200; i++ {
+		key := keys[i]
+		value := make([]byte, 1+rng.Intn(32))
+		rng.Read(value)
+
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root, err := p.ApplyInsert(key, value)
+
+		if err != nil {
+			t.Fatalf("key %d (%d): %v", i, p.Type(), err)
+		}
+
+		if err := tree.Insert(key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		if root != tree.RootHash() {
+			t.Fatalf("key %d (%d): root mismatch", i, p.Type())
+		}
+	}
+
+	// Inserting into the empty tree.
+	empty := urkel.New()
+	p, err := empty.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := p.ApplyInsert(keys[0], values[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty.Insert(keys[0], values[0])
+
+	if root != empty.RootHash() {
+		t.Fatal("root mismatch on empty tree")
+	}
+}
+
+func TestApplyRemove(t *testing.T) {
+	keys, values := randomItems(9, 150)
+	tree := buildTree(t, keys[:100], values[:100])
+
+	for i := 0; i < 150; i++ {
+		key := keys[i]
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sibling *proof.Proof
+
+		if skey, ok := p.SiblingKey(key); ok && p.Type() == proof.ProofTypeExists {
+			if _, err := p.ApplyRemove(key, nil); err != proof.ErrSiblingRequired {
+				t.Fatalf("expected ErrSiblingRequired, got %v", err)
+			}
+
+			if sibling, err = tree.Prove(skey); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		root, err := p.ApplyRemove(key, sibling)
+
+		if err != nil {
+			t.Fatalf("key %d (%d): %v", i, p.Type(), err)
+		}
+
+		if err := tree.Remove(key); err != nil {
+			t.Fatal(err)
+		}
+
+		if root != tree.RootHash() {
+			t.Fatalf("key %d (%d): root mismatch", i, p.Type())
+		}
+	}
+
+	if tree.RootHash() != (proof.UrkelHash{}) {
+		t.Fatal("tree not empty")
+	}
+}
+
+func TestApplyRemoveSiblingMismatch(t *testing.T) {
+	keys, values := randomItems(10, 50)
+	tree := buildTree(t, keys, values)
+
+	p, err := tree.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := tree.Prove(keys[1])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.SiblingKey(keys[0]); !ok {
+		t.Fatal("expected sibling key")
+	}
+
+	if _, err := p.ApplyRemove(keys[0], other); err == nil {
+		t.Fatal("expected error for unrelated sibling proof")
+	}
+}
+
+func TestApplyWithHasher(t *testing.T) {
+	keys, values := randomItems(17, 80)
+	tree := urkel.NewWithHasher(proof.SHA256)
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root, err := p.ApplyInsertWith(proof.SHA256, key, values[i])
+
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		if root != tree.RootHash() {
+			t.Fatalf("key %d: insert root mismatch", i)
+		}
+	}
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sibling *proof.Proof
+
+		if skey, ok := p.SiblingKey(key); ok {
+			if sibling, err = tree.Prove(skey); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		root, err := p.ApplyRemoveWith(proof.SHA256, key, sibling)
+
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+
+		if err := tree.Remove(key); err != nil {
+			t.Fatal(err)
+		}
+
+		if root != tree.RootHash() {
+			t.Fatalf("key %d: remove root mismatch", i)
+		}
+	}
+}
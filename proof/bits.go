@@ -24,7 +24,7 @@ func (b *Bits) DataByteSize() int {
 
 func (b *Bits) countFrom(index int, key UrkelHash, depth int) int {
 	x := b.size - index
-	y := UrkelKeySize - depth
+	y := UrkelKeyBits - depth
 	blen := x
 
 	if y < x {
@@ -62,6 +62,109 @@ func (b *Bits) GetBit(pos int) int {
 	return getBit(b.data[:], pos)
 }
 
+// Slice returns bits [start, end) of b. It fails if the range is not
+// within b.
+func (b *Bits) Slice(start, end int) (Bits, error) {
+	if start < 0 || end < start || end > b.size {
+		return Bits{}, errors.New("bits slice out of range")
+	}
+
+	bits := Bits{size: end - start}
+
+	for i := start; i < end; i++ {
+		bits.SetBit(i-start, b.GetBit(i))
+	}
+
+	return bits, nil
+}
+
+// Join returns b followed by other. It fails if the result is longer than
+// a key.
+func (b *Bits) Join(other Bits) (Bits, error) {
+	if b.size+other.size > UrkelKeyBits {
+		return Bits{}, errors.New("joined bits too long")
+	}
+
+	bits := Bits{size: b.size + other.size}
+
+	for i := 0; i < b.size; i++ {
+		bits.SetBit(i, b.GetBit(i))
+	}
+
+	for i := 0; i < other.size; i++ {
+		bits.SetBit(b.size+i, other.GetBit(i))
+	}
+
+	return bits, nil
+}
+
+// Split returns bits [0, index) and [index, size) of b. It fails if index
+// is not within b.
+func (b *Bits) Split(index int) (Bits, Bits, error) {
+	if index < 0 || index > b.size {
+		return Bits{}, Bits{}, errors.New("bits split out of range")
+	}
+
+	front, _ := b.Slice(0, index)
+	back, _ := b.Slice(index, b.size)
+
+	return front, back, nil
+}
+
+// Equal reports whether b and other have the same size and bits.
+func (b *Bits) Equal(other Bits) bool {
+	return b.size == other.size && b.CommonPrefixLen(other) == b.size
+}
+
+// CommonPrefixLen returns the number of leading bits b and other share.
+func (b *Bits) CommonPrefixLen(other Bits) int {
+	size := b.size
+
+	if other.size < size {
+		size = other.size
+	}
+
+	for i := 0; i < size; i++ {
+		if b.GetBit(i) != other.GetBit(i) {
+			return i
+		}
+	}
+
+	return size
+}
+
+// Compare orders bits like keys: by the first differing bit, with a
+// prefix before the longer bits it starts. It returns -1, 0 or 1.
+func (b *Bits) Compare(other Bits) int {
+	common := b.CommonPrefixLen(other)
+
+	switch {
+	case common < b.size && common < other.size:
+		return b.GetBit(common) - other.GetBit(common)
+	case b.size < other.size:
+		return -1
+	case b.size > other.size:
+		return 1
+	}
+
+	return 0
+}
+
+// FromKey sets b to length bits of key starting at bit start.
+func (b *Bits) FromKey(key UrkelHash, start, length int) error {
+	if start < 0 || length < 0 || start+length > UrkelKeyBits {
+		return errors.New("bits out of key range")
+	}
+
+	*b = Bits{size: length}
+
+	for i := 0; i < length; i++ {
+		b.SetBit(i, getBit(key[:], start+i))
+	}
+
+	return nil
+}
+
 func (b *Bits) SerializeSize() int {
 	size := 0
 
@@ -181,6 +284,13 @@ func NewBitsFromReader(r io.Reader, size int) (*Bits, error) {
 	return bits, err
 }
 
+func NewBitsFromKey(key UrkelHash, start, length int) (*Bits, error) {
+	bits := &Bits{}
+	err := bits.FromKey(key, start, length)
+
+	return bits, err
+}
+
 func NewBitsFromString(str string) (*Bits, error) {
 	bits := &Bits{}
 	err := bits.FromString(str)
@@ -194,3 +194,199 @@ func TestHas(t *testing.T) {
 		}
 	}
 }
+
+// TestCountDeep covers prefixes at depths past bit 32, which were cut
+// short when the count was bounded by the key size in bytes.
+func TestCountDeep(t *testing.T) {
+	key := testKey()
+
+	for _, tc := range []struct {
+		depth int
+		size  int
+		count int
+	}{
+		{0, 40, 40},
+		{32, 8, 8},
+		{100, 16, 16},
+		{200, 56, 56},
+		{250, 6, 6},
+		{255, 1, 1},
+	} {
+		bits, err := NewBitsFromKey(key, tc.depth, tc.size)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count := bits.Count(key, tc.depth); count != tc.count {
+			t.Fatalf("depth %d: expected count %d, got %d", tc.depth, tc.count, count)
+		}
+
+		if !bits.Has(key, tc.depth) {
+			t.Fatalf("depth %d: bits do not match key", tc.depth)
+		}
+	}
+
+	// The count stops at the end of the key.
+	tail, _ := NewBitsFromKey(key, 248, 8)
+	head, _ := NewBitsFromKey(key, 0, 8)
+	bits, err := tail.Join(*head)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := bits.Count(key, 248); count != 8 {
+		t.Fatalf("expected count 8 at the end of the key, got %d", count)
+	}
+}
+
+func testKey() UrkelHash {
+	var key UrkelHash
+
+	for i := range key {
+		key[i] = byte(i*37 + 11)
+	}
+
+	return key
+}
+
+func TestBitsFromKey(t *testing.T) {
+	key := testKey()
+
+	for _, size := range []int{0, 1, 255, 256} {
+		bits, err := NewBitsFromKey(key, 0, size)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bits.Size() != size {
+			t.Fatalf("expected size %d, got %d", size, bits.Size())
+		}
+
+		if !bits.Has(key, 0) {
+			t.Fatalf("size %d: bits do not match key", size)
+		}
+	}
+
+	bits, err := NewBitsFromKey(key, 255, 1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bits.GetBit(0) != getBit(key[:], 255) {
+		t.Fatal("last key bit mismatch")
+	}
+
+	if _, err := NewBitsFromKey(key, 255, 2); err == nil {
+		t.Fatal("expected error past the end of the key")
+	}
+
+	if _, err := NewBitsFromKey(key, 0, 257); err == nil {
+		t.Fatal("expected error for 257 bits")
+	}
+}
+
+func TestBitsSliceJoinSplit(t *testing.T) {
+	key := testKey()
+	full, _ := NewBitsFromKey(key, 0, 256)
+
+	for _, index := range []int{0, 1, 8, 255, 256} {
+		front, back, err := full.Split(index)
+
+		if err != nil {
+			t.Fatalf("split at %d: %s", index, err)
+		}
+
+		if front.Size() != index || back.Size() != 256-index {
+			t.Fatalf("split at %d: sizes %d and %d", index, front.Size(), back.Size())
+		}
+
+		joined, err := front.Join(back)
+
+		if err != nil {
+			t.Fatalf("split at %d: %s", index, err)
+		}
+
+		if !joined.Equal(*full) {
+			t.Fatalf("split at %d: join does not restore the bits", index)
+		}
+
+		expect, _ := NewBitsFromKey(key, index, 256-index)
+
+		if !back.Equal(*expect) {
+			t.Fatalf("split at %d: back half mismatch", index)
+		}
+	}
+
+	empty, err := full.Slice(256, 256)
+
+	if err != nil || empty.Size() != 0 || !empty.Equal(Bits{}) {
+		t.Fatal("empty slice mismatch")
+	}
+
+	last, err := full.Slice(255, 256)
+
+	if err != nil || last.Size() != 1 || last.GetBit(0) != full.GetBit(255) {
+		t.Fatal("last bit slice mismatch")
+	}
+
+	if _, err := full.Join(last); err == nil {
+		t.Fatal("expected error joining past 256 bits")
+	}
+}
+
+func TestBitsRangeErrors(t *testing.T) {
+	full, _ := NewBitsFromKey(testKey(), 0, 255)
+
+	for _, r := range [][2]int{{-1, 1}, {2, 1}, {0, 256}} {
+		if _, err := full.Slice(r[0], r[1]); err == nil {
+			t.Fatalf("expected error for slice %v", r)
+		}
+	}
+
+	for _, index := range []int{-1, 256} {
+		if _, _, err := full.Split(index); err == nil {
+			t.Fatalf("expected error for split at %d", index)
+		}
+	}
+}
+
+func TestBitsCompare(t *testing.T) {
+	key := testKey()
+	full, _ := NewBitsFromKey(key, 0, 256)
+	almost, _ := NewBitsFromKey(key, 0, 255)
+
+	other := key
+	other[31] ^= 0x01
+	flipped, _ := NewBitsFromKey(other, 0, 256)
+
+	tests := []struct {
+		a, b   Bits
+		common int
+		cmp    int
+	}{
+		{Bits{}, Bits{}, 0, 0},
+		{Bits{}, *full, 0, -1},
+		{*full, *full, 256, 0},
+		{*almost, *full, 255, -1},
+		{*full, *almost, 255, 1},
+		{*full, *flipped, 255, full.GetBit(255) - flipped.GetBit(255)},
+	}
+
+	for i, test := range tests {
+		if got := test.a.CommonPrefixLen(test.b); got != test.common {
+			t.Errorf("test %d: common prefix %d, expected %d", i, got, test.common)
+		}
+
+		if got := test.a.Compare(test.b); got != test.cmp {
+			t.Errorf("test %d: compare %d, expected %d", i, got, test.cmp)
+		}
+
+		if got := test.a.Equal(test.b); got != (test.cmp == 0) {
+			t.Errorf("test %d: equal %v", i, got)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// cborMaxNesting bounds the depth of skipped values.
+const cborMaxNesting = 16
+
+var errCBORTruncated = errors.New("cbor: truncated data")
+
+// MarshalCBOR encodes the proof as a CBOR map with the keys of its JSON
+// form. Bit prefixes are [size, data] arrays and nodes are [prefix, hash]
+// arrays. The encoding is deterministic (RFC 8949 section 4.2.1): lengths
+// are definite, integers use their shortest form and map keys are sorted.
+func (p *Proof) MarshalCBOR() ([]byte, error) {
+	if !p.IsSane() {
+		return nil, errors.New("cbor: invalid proof")
+	}
+
+	nodes := appendCBORHead(nil, cborArray, uint64(len(p.nodes)))
+
+	for _, node := range p.nodes {
+		nodes = appendCBORHead(nodes, cborArray, 2)
+		nodes = appendCBORBits(nodes, &node.prefix)
+		nodes = appendCBORBytes(nodes, node.hash[:])
+	}
+
+	fields := map[string][]byte{
+		"type":  appendCBORHead(nil, cborUint, uint64(p.ptype)),
+		"depth": appendCBORHead(nil, cborUint, uint64(p.depth)),
+		"nodes": nodes,
+	}
+
+	switch p.ptype {
+	case ProofTypeShort:
+		fields["prefix"] = appendCBORBits(nil, &p.prefix)
+		fields["left"] = appendCBORBytes(nil, p.left[:])
+		fields["right"] = appendCBORBytes(nil, p.right[:])
+	case ProofTypeCollision:
+		fields["key"] = appendCBORBytes(nil, p.key[:])
+		fields["hash"] = appendCBORBytes(nil, p.hash[:])
+	case ProofTypeExists:
+		fields["value"] = appendCBORBytes(nil, p.value[:p.valueSize])
+	}
+
+	type entry struct {
+		key   []byte
+		value []byte
+	}
+
+	entries := make([]entry, 0, len(fields))
+
+	for k, v := range fields {
+		key := appendCBORHead(nil, cborText, uint64(len(k)))
+		entries = append(entries, entry{append(key, k...), v})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	buf := appendCBORHead(nil, cborMap, uint64(len(entries)))
+
+	for _, e := range entries {
+		buf = append(buf, e.key...)
+		buf = append(buf, e.value...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalCBOR decodes a proof encoded by MarshalCBOR. Any definite
+// length encoding is accepted; unknown keys are skipped and keys that do
+// not belong to the proof type are ignored.
+func (p *Proof) UnmarshalCBOR(data []byte) error {
+	r := cborReader{data: data}
+
+	count, err := r.expect(cborMap)
+
+	if err != nil {
+		return err
+	}
+
+	var out Proof
+	var prefix, left, right, key, hash, value []byte
+	var hasType, hasDepth, hasNodes bool
+
+	seen := make(map[string]bool)
+
+	for i := uint64(0); i < count; i++ {
+		name, err := r.text()
+
+		if err != nil {
+			return err
+		}
+
+		if seen[name] {
+			return errors.New("cbor: duplicate key")
+		}
+
+		seen[name] = true
+
+		switch name {
+		case "type":
+			var v uint64
+
+			if v, err = r.expect(cborUint); err == nil {
+				out.ptype = ProofType(v)
+				hasType = true
+			}
+		case "depth":
+			var v uint64
+
+			if v, err = r.expect(cborUint); err == nil {
+				if v > UrkelKeyBits {
+					return errors.New("cbor: invalid depth")
+				}
+
+				out.depth = int(v)
+				hasDepth = true
+			}
+		case "nodes":
+			out.nodes, err = r.nodes()
+			hasNodes = true
+		case "prefix":
+			prefix, err = r.raw()
+		case "left":
+			left, err = r.bytes()
+		case "right":
+			right, err = r.bytes()
+		case "key":
+			key, err = r.bytes()
+		case "hash":
+			hash, err = r.bytes()
+		case "value":
+			value, err = r.bytes()
+		default:
+			err = r.skip(0)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(r.data) != 0 {
+		return errors.New("cbor: trailing data")
+	}
+
+	if !hasType || !hasDepth || !hasNodes {
+		return errors.New("cbor: missing proof field")
+	}
+
+	switch out.ptype {
+	case ProofTypeDeadEnd:
+		// Nothing.
+	case ProofTypeShort:
+		if prefix == nil {
+			return errors.New("cbor: missing prefix")
+		}
+
+		pr := cborReader{data: prefix}
+
+		if out.prefix, err = pr.bits(); err != nil {
+			return err
+		}
+
+		if out.prefix.size == 0 {
+			return errors.New("cbor: invalid prefix size")
+		}
+
+		if err = copyHash(&out.left, left); err != nil {
+			return err
+		}
+
+		err = copyHash(&out.right, right)
+	case ProofTypeCollision:
+		if err = copyHash(&out.key, key); err != nil {
+			return err
+		}
+
+		err = copyHash(&out.hash, hash)
+	case ProofTypeExists:
+		if value == nil {
+			return errors.New("cbor: missing value")
+		}
+
+		if len(value) > UrkelValueSize {
+			return errors.New("cbor: value too long")
+		}
+
+		out.valueSize = uint16(copy(out.value[:], value))
+	default:
+		return errors.New("cbor: invalid proof type")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*p = out
+
+	return nil
+}
+
+func NewFromCBOR(b []byte) (*Proof, error) {
+	p := New()
+	err := p.UnmarshalCBOR(b)
+	return p, err
+}
+
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	m := major << 5
+
+	switch {
+	case n < 24:
+		return append(buf, m|byte(n))
+	case n <= 0xff:
+		return append(buf, m|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, m|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, m|26), uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64(append(buf, m|27), n)
+}
+
+func appendCBORBytes(buf []byte, data []byte) []byte {
+	buf = appendCBORHead(buf, cborBytes, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendCBORBits(buf []byte, b *Bits) []byte {
+	buf = appendCBORHead(buf, cborArray, 2)
+	buf = appendCBORHead(buf, cborUint, uint64(b.size))
+	return appendCBORBytes(buf, b.data[:b.DataByteSize()])
+}
+
+type cborReader struct {
+	data []byte
+}
+
+// head reads the initial byte and argument of the next item.
+func (r *cborReader) head() (byte, uint64, error) {
+	if len(r.data) == 0 {
+		return 0, 0, errCBORTruncated
+	}
+
+	major := r.data[0] >> 5
+	info := r.data[0] & 0x1f
+	r.data = r.data[1:]
+
+	var size int
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == 31:
+		return 0, 0, errors.New("cbor: indefinite length not supported")
+	default:
+		return 0, 0, errors.New("cbor: invalid additional info")
+	}
+
+	if len(r.data) < size {
+		return 0, 0, errCBORTruncated
+	}
+
+	var n uint64
+
+	for _, b := range r.data[:size] {
+		n = n<<8 | uint64(b)
+	}
+
+	r.data = r.data[size:]
+
+	return major, n, nil
+}
+
+func (r *cborReader) expect(major byte) (uint64, error) {
+	m, n, err := r.head()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if m != major {
+		return 0, errors.New("cbor: unexpected type")
+	}
+
+	return n, nil
+}
+
+func (r *cborReader) payload(major byte) ([]byte, error) {
+	n, err := r.expect(major)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(r.data)) {
+		return nil, errCBORTruncated
+	}
+
+	data := r.data[:n]
+	r.data = r.data[n:]
+
+	return data, nil
+}
+
+func (r *cborReader) bytes() ([]byte, error) {
+	return r.payload(cborBytes)
+}
+
+func (r *cborReader) text() (string, error) {
+	data, err := r.payload(cborText)
+	return string(data), err
+}
+
+// raw returns the encoding of the next item without decoding it.
+func (r *cborReader) raw() ([]byte, error) {
+	start := r.data
+
+	if err := r.skip(0); err != nil {
+		return nil, err
+	}
+
+	return start[:len(start)-len(r.data)], nil
+}
+
+func (r *cborReader) bits() (Bits, error) {
+	var b Bits
+
+	if n, err := r.expect(cborArray); err != nil {
+		return b, err
+	} else if n != 2 {
+		return b, errors.New("cbor: invalid bitfield")
+	}
+
+	size, err := r.expect(cborUint)
+
+	if err != nil {
+		return b, err
+	}
+
+	if size > UrkelKeyBits {
+		return b, errors.New("cbor: bitfield size too large")
+	}
+
+	data, err := r.bytes()
+
+	if err != nil {
+		return b, err
+	}
+
+	if len(data) != int(size+7)/8 {
+		return b, errors.New("cbor: bitfield data size mismatch")
+	}
+
+	b.size = int(size)
+	copy(b.data[:], data)
+
+	return b, nil
+}
+
+func (r *cborReader) nodes() ([]*ProofNode, error) {
+	count, err := r.expect(cborArray)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count > UrkelKeyBits {
+		return nil, errors.New("cbor: proof too large")
+	}
+
+	nodes := make([]*ProofNode, 0, count)
+
+	for i := uint64(0); i < count; i++ {
+		if n, err := r.expect(cborArray); err != nil {
+			return nil, err
+		} else if n != 2 {
+			return nil, errors.New("cbor: invalid proof node")
+		}
+
+		prefix, err := r.bits()
+
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := r.bytes()
+
+		if err != nil {
+			return nil, err
+		}
+
+		node := NewProofNode(prefix, UrkelHash{})
+
+		if err := copyHash(&node.hash, hash); err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// skip moves past the next item, including everything nested in it.
+func (r *cborReader) skip(depth int) error {
+	if depth > cborMaxNesting {
+		return errors.New("cbor: nesting too deep")
+	}
+
+	major, n, err := r.head()
+
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case cborUint, cborNegInt, cborSimple:
+		return nil
+	case cborBytes, cborText:
+		if n > uint64(len(r.data)) {
+			return errCBORTruncated
+		}
+
+		r.data = r.data[n:]
+
+		return nil
+	case cborTag:
+		return r.skip(depth + 1)
+	case cborMap:
+		if n > uint64(len(r.data)) {
+			return errCBORTruncated
+		}
+
+		n *= 2
+	}
+
+	if n > uint64(len(r.data)) {
+		return errCBORTruncated
+	}
+
+	for i := uint64(0); i < n; i++ {
+		if err := r.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
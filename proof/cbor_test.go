@@ -0,0 +1,133 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCBORRoundTrip(t *testing.T) {
+	for i, proof := range fixtureProofs(t) {
+		data, err := proof.MarshalCBOR()
+
+		if err != nil {
+			t.Fatalf("%d: MarshalCBOR failed: %s", i, err)
+		}
+
+		decoded, err := NewFromCBOR(data)
+
+		if err != nil {
+			t.Fatalf("%d: NewFromCBOR failed: %s", i, err)
+		}
+
+		if !bytes.Equal(serialized(t, decoded), serialized(t, proof)) {
+			t.Fatalf("%d: round trip mismatch", i)
+		}
+
+		again, err := decoded.MarshalCBOR()
+
+		if err != nil {
+			t.Fatalf("%d: MarshalCBOR failed: %s", i, err)
+		}
+
+		if !bytes.Equal(again, data) {
+			t.Fatalf("%d: encoding is not deterministic", i)
+		}
+	}
+}
+
+func TestCBOREncoding(t *testing.T) {
+	deadEnd, err := NewDeadEnd(0).MarshalCBOR()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {"type": 0, "depth": 0, "nodes": []} with keys in length-first order.
+	expect := "a3" + "6474797065" + "00" + "656465707468" + "00" + "656e6f646573" + "80"
+
+	if hex.EncodeToString(deadEnd) != expect {
+		t.Fatalf("encoding mismatch:\n%x\n%s", deadEnd, expect)
+	}
+
+	var hash UrkelHash
+	hash[0] = 0xcc
+
+	prefix, _ := NewBitsFromString("1")
+	exists, _ := NewExists(200, []byte("hi"))
+	exists.Push(*prefix, hash)
+
+	data, err := exists.MarshalCBOR()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect = "a4" + "6474797065" + "03" +
+		"656465707468" + "18c8" +
+		"656e6f646573" + "81" + "82" + "82" + "01" + "4180" + "5820" + hex.EncodeToString(hash[:]) +
+		"6576616c7565" + "426869"
+
+	if hex.EncodeToString(data) != expect {
+		t.Fatalf("encoding mismatch:\n%x\n%s", data, expect)
+	}
+}
+
+func TestCBORDecode(t *testing.T) {
+	// Unknown keys are skipped, whatever they hold.
+	unknown := "a4" + "6474797065" + "00" + "656465707468" + "00" + "656e6f646573" + "80" +
+		"63657874" + "a1" + "01" + "c2" + "83" + "f93c00" + "20" + "6161"
+
+	data, _ := hex.DecodeString(unknown)
+	proof, err := NewFromCBOR(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proof.Type() != ProofTypeDeadEnd || proof.Depth() != 0 {
+		t.Fatal("decoded proof mismatch")
+	}
+
+	// Non-shortest integers are accepted.
+	data, _ = hex.DecodeString("a3" + "6474797065" + "1800" + "656465707468" + "1a00000007" + "656e6f646573" + "80")
+
+	if proof, err = NewFromCBOR(data); err != nil || proof.Depth() != 7 {
+		t.Fatalf("long integers: %v", err)
+	}
+}
+
+func TestCBORInvalid(t *testing.T) {
+	base := "6474797065" + "00" + "656465707468" + "00" + "656e6f646573" + "80"
+	hash := "5820" + hex.EncodeToString(make([]byte, 32))
+
+	cases := map[string]string{
+		"not a map":    "80",
+		"truncated":    "a3" + base[:20],
+		"trailing":     "a3" + base + "00",
+		"missing type": "a2" + "656465707468" + "00" + "656e6f646573" + "80",
+		"duplicate":    "a4" + base + "656465707468" + "00",
+		"indefinite":   "bf" + base + "ff",
+		"bad type":     "a3" + "6474797065" + "04" + "656465707468" + "00" + "656e6f646573" + "80",
+		"bad depth":    "a3" + "6474797065" + "00" + "656465707468" + "190101" + "656e6f646573" + "80",
+		"bad node":     "a3" + "6474797065" + "00" + "656465707468" + "00" + "656e6f646573" + "81" + "81" + hash,
+		"node hash":    "a3" + "6474797065" + "00" + "656465707468" + "01" + "656e6f646573" + "81" + "82" + "820040" + "4100",
+		"bits size":    "a3" + "6474797065" + "00" + "656465707468" + "01" + "656e6f646573" + "81" + "82" + "820340" + hash,
+		"no prefix":    "a5" + "6474797065" + "01" + "656465707468" + "00" + "656e6f646573" + "80" + "646c656674" + hash + "657269676874" + hash,
+		"no value":     "a3" + "6474797065" + "03" + "656465707468" + "00" + "656e6f646573" + "80",
+		"key length":   "a5" + "6474797065" + "02" + "656465707468" + "00" + "656e6f646573" + "80" + "636b6579" + "4100" + "6468617368" + hash,
+		"deep nesting": "a4" + base + "6161" + "818181818181818181818181818181818181818100",
+	}
+
+	for name, h := range cases {
+		data, err := hex.DecodeString(h)
+
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if _, err := NewFromCBOR(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
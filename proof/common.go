@@ -2,8 +2,6 @@ package proof
 
 import (
 	"errors"
-
-	"golang.org/x/crypto/blake2b"
 )
 
 const (
@@ -39,7 +37,14 @@ const (
 	ProofNegDepth
 	ProofPathMismatch
 	ProofTooDeep
+
+	// ProofInvalid is returned for proofs that are malformed. urkel calls
+	// it PROOF_UNKNOWN_ERROR.
 	ProofInvalid
+
+	// ProofBadValue is not an hsd code: the proof verified but a
+	// ValueValidator rejected its value.
+	ProofBadValue
 )
 
 var SkipPrefix = [1]byte{0x02}
@@ -63,6 +68,31 @@ func (pt ProofType) String() string {
 	return "TYPE_UNKNOWN"
 }
 
+func (c UrkelCode) String() string {
+	switch c {
+	case ProofOk:
+		return "PROOF_OK"
+	case ProofHashMismatch:
+		return "PROOF_HASH_MISMATCH"
+	case ProofSameKey:
+		return "PROOF_SAME_KEY"
+	case ProofSamePath:
+		return "PROOF_SAME_PATH"
+	case ProofNegDepth:
+		return "PROOF_NEG_DEPTH"
+	case ProofPathMismatch:
+		return "PROOF_PATH_MISMATCH"
+	case ProofTooDeep:
+		return "PROOF_TOO_DEEP"
+	case ProofInvalid:
+		return "PROOF_UNKNOWN_ERROR"
+	case ProofBadValue:
+		return "PROOF_BAD_VALUE"
+	}
+
+	return "PROOF_UNKNOWN"
+}
+
 func StringToProofType(s string) ProofType {
 	switch s {
 	case "TYPE_DEADEND":
@@ -80,74 +110,82 @@ func StringToProofType(s string) ProofType {
 	return ProofTypeUnknown
 }
 
-func hashInternal(prefix Bits, left UrkelHash, right UrkelHash) (UrkelHash, error) {
-	var hash UrkelHash
-
-	h, err := blake2b.New256(nil)
+func hashInternal(hasher Hasher, prefix Bits, left UrkelHash, right UrkelHash) (UrkelHash, error) {
+	var err error
 
-	if err != nil {
-		return hash, err
-	}
+	h := hasher.New()
 
 	if prefix.size == 0 {
 		if err = writeBytesFull(h, InternalPrefix[:]); err != nil {
-			return hash, err
+			return UrkelHash{}, err
 		}
 	} else {
 		if err = writeBytesFull(h, SkipPrefix[:]); err != nil {
-			return hash, err
+			return UrkelHash{}, err
 		}
 
 		if err = writeUint16(h, uint16(prefix.size)); err != nil {
-			return hash, err
+			return UrkelHash{}, err
 		}
 
 		if err = writeBytes(h, prefix.data[:], prefix.DataByteSize()); err != nil {
-			return hash, err
+			return UrkelHash{}, err
 		}
 	}
 
 	if err = writeBytesFull(h, left[:]); err != nil {
-		return hash, err
+		return UrkelHash{}, err
 	}
 
 	if err = writeBytesFull(h, right[:]); err != nil {
-		return hash, err
+		return UrkelHash{}, err
 	}
 
-	sum := h.Sum(nil)
+	return sumHash(h.Sum(nil))
+}
 
-	if len(sum) != UrkelHashSize {
-		return hash, errors.New("hash size mismatch")
+func hashLeaf(hasher Hasher, key UrkelHash, valueHash UrkelHash) (UrkelHash, error) {
+	var err error
+
+	h := hasher.New()
+
+	if err = writeBytesFull(h, LeafPrefix[:]); err != nil {
+		return UrkelHash{}, err
 	}
 
-	copy(hash[:], sum)
+	if err = writeBytesFull(h, key[:]); err != nil {
+		return UrkelHash{}, err
+	}
 
-	return hash, nil
-}
+	if err = writeBytesFull(h, valueHash[:]); err != nil {
+		return UrkelHash{}, err
+	}
 
-func hashLeaf(key UrkelHash, valueHash UrkelHash) (UrkelHash, error) {
-	var hash UrkelHash
+	return sumHash(h.Sum(nil))
+}
 
-	h, err := blake2b.New256(nil)
+func hashValue(hasher Hasher, key UrkelHash, value UrkelValue, size uint16) (UrkelHash, error) {
+	vhash, err := hashData(hasher, value[:size])
 
 	if err != nil {
-		return hash, err
+		return vhash, err
 	}
 
-	if err = writeBytesFull(h, LeafPrefix[:]); err != nil {
-		return hash, err
-	}
+	return hashLeaf(hasher, key, vhash)
+}
 
-	if err = writeBytesFull(h, key[:]); err != nil {
-		return hash, err
-	}
+func hashData(hasher Hasher, data []byte) (UrkelHash, error) {
+	h := hasher.New()
 
-	if err = writeBytesFull(h, valueHash[:]); err != nil {
-		return hash, err
+	if err := writeBytesFull(h, data); err != nil {
+		return UrkelHash{}, err
 	}
 
-	sum := h.Sum(nil)
+	return sumHash(h.Sum(nil))
+}
+
+func sumHash(sum []byte) (UrkelHash, error) {
+	var hash UrkelHash
 
 	if len(sum) != UrkelHashSize {
 		return hash, errors.New("hash size mismatch")
@@ -158,26 +196,32 @@ func hashLeaf(key UrkelHash, valueHash UrkelHash) (UrkelHash, error) {
 	return hash, nil
 }
 
-func hashValue(key UrkelHash, value UrkelValue, size uint16) (UrkelHash, error) {
-	var vhash UrkelHash
-
-	h, err := blake2b.New256(nil)
-
-	if err != nil {
-		return vhash, err
-	}
+// HashInternal returns the hash of an internal node with its skip prefix.
+func HashInternal(prefix Bits, left UrkelHash, right UrkelHash) (UrkelHash, error) {
+	return hashInternal(DefaultHasher(), prefix, left, right)
+}
 
-	if err = writeBytes(h, value[:], int(size)); err != nil {
-		return vhash, err
-	}
+// HashLeaf returns the hash of a leaf from its key and value hash.
+func HashLeaf(key UrkelHash, valueHash UrkelHash) (UrkelHash, error) {
+	return hashLeaf(DefaultHasher(), key, valueHash)
+}
 
-	sum := h.Sum(nil)
+// HashData returns the hash of a leaf value.
+func HashData(data []byte) (UrkelHash, error) {
+	return hashData(DefaultHasher(), data)
+}
 
-	if len(sum) != UrkelHashSize {
-		return vhash, errors.New("hash size mismatch")
-	}
+// HashInternalWith is HashInternal with the given hasher.
+func HashInternalWith(hasher Hasher, prefix Bits, left UrkelHash, right UrkelHash) (UrkelHash, error) {
+	return hashInternal(hasher, prefix, left, right)
+}
 
-	copy(vhash[:], sum)
+// HashLeafWith is HashLeaf with the given hasher.
+func HashLeafWith(hasher Hasher, key UrkelHash, valueHash UrkelHash) (UrkelHash, error) {
+	return hashLeaf(hasher, key, valueHash)
+}
 
-	return hashLeaf(key, vhash)
+// HashDataWith is HashData with the given hasher.
+func HashDataWith(hasher Hasher, data []byte) (UrkelHash, error) {
+	return hashData(hasher, data)
 }
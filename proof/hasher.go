@@ -0,0 +1,42 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Hasher creates the digest used for node and value hashes. Digests must
+// be UrkelHashSize bytes long.
+type Hasher interface {
+	New() hash.Hash
+}
+
+type blake2bHasher struct{}
+
+func (blake2bHasher) New() hash.Hash {
+	// Without a key this cannot fail.
+	h, _ := blake2b.New256(nil)
+	return h
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) New() hash.Hash {
+	return sha256.New()
+}
+
+var (
+	// Blake2b is BLAKE2b-256, the digest hsd uses.
+	Blake2b Hasher = blake2bHasher{}
+
+	// SHA256 is SHA-256.
+	SHA256 Hasher = sha256Hasher{}
+)
+
+// DefaultHasher returns the hasher used wherever no hasher is given,
+// BLAKE2b-256.
+func DefaultHasher() Hasher {
+	return blake2bHasher{}
+}
@@ -0,0 +1,311 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// JSONNodeFormat selects how proof nodes are written.
+type JSONNodeFormat int
+
+const (
+	// JSONNodesArray writes nodes as [prefix, hash] like hsd.
+	JSONNodesArray JSONNodeFormat = iota
+
+	// JSONNodesObject writes nodes as {"prefix": ..., "hash": ...}.
+	JSONNodesObject
+)
+
+// JSONOptions configures MarshalJSONWith and UnmarshalJSONWith.
+//
+// Without Strict, decoding also accepts the forms other hsd-compatible
+// tools emit: numeric type codes, uppercase hex, nodes as objects and
+// base64 values. Values that are valid hex are always read as hex.
+// Strict accepts only hsd's exact shape.
+type JSONOptions struct {
+	Strict bool
+	Nodes  JSONNodeFormat
+}
+
+type jsonNodeObject struct {
+	Prefix string `json:"prefix"`
+	Hash   string `json:"hash"`
+}
+
+// proofJSONOutput is ProofJSON with either node format.
+type proofJSONOutput struct {
+	Ptype  string `json:"type"`
+	Depth  int    `json:"depth"`
+	Nodes  any    `json:"nodes"`
+	Prefix string `json:"prefix,omitempty"`
+	Left   string `json:"left,omitempty"`
+	Right  string `json:"right,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type proofJSONInput struct {
+	Ptype  json.RawMessage   `json:"type"`
+	Depth  int               `json:"depth"`
+	Nodes  []json.RawMessage `json:"nodes"`
+	Prefix string            `json:"prefix"`
+	Left   string            `json:"left"`
+	Right  string            `json:"right"`
+	Key    string            `json:"key"`
+	Hash   string            `json:"hash"`
+	Value  string            `json:"value"`
+}
+
+// MarshalJSONWith encodes the proof with the node format from opts. Nil
+// opts gives hsd's format.
+func (p *Proof) MarshalJSONWith(opts *JSONOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &JSONOptions{}
+	}
+
+	out := proofJSONOutput{
+		Ptype: p.ptype.String(),
+		Depth: p.depth,
+		Nodes: p.nodes,
+	}
+
+	switch opts.Nodes {
+	case JSONNodesArray:
+		// ProofNode marshals itself as an array.
+	case JSONNodesObject:
+		nodes := make([]jsonNodeObject, len(p.nodes))
+
+		for i, node := range p.nodes {
+			nodes[i] = jsonNodeObject{
+				Prefix: node.prefix.String(),
+				Hash:   hex.EncodeToString(node.hash[:]),
+			}
+		}
+
+		out.Nodes = nodes
+	default:
+		return nil, errors.New("invalid node format")
+	}
+
+	switch p.Type() {
+	case ProofTypeDeadEnd:
+		// Nothing.
+	case ProofTypeShort:
+		out.Prefix = p.prefix.String()
+		out.Left = hex.EncodeToString(p.left[:])
+		out.Right = hex.EncodeToString(p.right[:])
+	case ProofTypeCollision:
+		out.Key = hex.EncodeToString(p.key[:])
+		out.Hash = hex.EncodeToString(p.hash[:])
+	case ProofTypeExists:
+		out.Value = hex.EncodeToString(p.value[:p.valueSize])
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSONWith decodes a proof as described at JSONOptions. Nil opts
+// is lenient.
+func (p *Proof) UnmarshalJSONWith(b []byte, opts *JSONOptions) error {
+	var in proofJSONInput
+	var err error
+
+	if opts == nil {
+		opts = &JSONOptions{}
+	}
+
+	if err = json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	out := Proof{nodes: []*ProofNode{}}
+
+	if out.ptype, err = decodeJSONType(in.Ptype, opts.Strict); err != nil {
+		return err
+	}
+
+	if in.Depth < 0 || in.Depth > UrkelKeyBits {
+		return errors.New("invalid depth")
+	}
+
+	out.depth = in.Depth
+
+	for _, raw := range in.Nodes {
+		node := &ProofNode{}
+
+		if err = node.unmarshalJSON(raw, opts.Strict); err != nil {
+			return err
+		}
+
+		out.nodes = append(out.nodes, node)
+	}
+
+	switch out.ptype {
+	case ProofTypeDeadEnd:
+		// Nothing.
+	case ProofTypeShort:
+		if err = out.prefix.FromString(in.Prefix); err != nil {
+			return err
+		}
+
+		if out.left, err = decodeJSONHash(in.Left, opts.Strict); err != nil {
+			return err
+		}
+
+		if out.right, err = decodeJSONHash(in.Right, opts.Strict); err != nil {
+			return err
+		}
+	case ProofTypeCollision:
+		if out.key, err = decodeJSONHash(in.Key, opts.Strict); err != nil {
+			return err
+		}
+
+		if out.hash, err = decodeJSONHash(in.Hash, opts.Strict); err != nil {
+			return err
+		}
+	case ProofTypeExists:
+		value, err := decodeJSONValue(in.Value, opts.Strict)
+
+		if err != nil {
+			return err
+		}
+
+		if len(value) > UrkelValueSize {
+			return errors.New("value too long")
+		}
+
+		copy(out.value[:], value)
+		out.valueSize = uint16(len(value))
+	default:
+		return errors.New("invalid proof type")
+	}
+
+	*p = out
+
+	return nil
+}
+
+func NewFromJSONWith(b []byte, opts *JSONOptions) (*Proof, error) {
+	proof := New()
+	err := proof.UnmarshalJSONWith(b, opts)
+	return proof, err
+}
+
+func (pn *ProofNode) unmarshalJSON(data []byte, strict bool) error {
+	var prefix, hash string
+
+	data = bytes.TrimSpace(data)
+
+	if len(data) > 0 && data[0] == '{' {
+		if strict {
+			return errors.New("invalid proof node (object)")
+		}
+
+		var obj jsonNodeObject
+
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+
+		prefix, hash = obj.Prefix, obj.Hash
+	} else {
+		var parts []string
+
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return err
+		}
+
+		if len(parts) != 2 {
+			return errors.New("invalid proof node (length)")
+		}
+
+		prefix, hash = parts[0], parts[1]
+	}
+
+	var node ProofNode
+	var err error
+
+	if err = node.prefix.FromString(prefix); err != nil {
+		return err
+	}
+
+	if node.hash, err = decodeJSONHash(hash, strict); err != nil {
+		return errors.New("invalid proof node (hash)")
+	}
+
+	*pn = node
+
+	return nil
+}
+
+func decodeJSONType(raw json.RawMessage, strict bool) (ProofType, error) {
+	var name string
+
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return StringToProofType(name), nil
+	}
+
+	var code int
+
+	if strict || json.Unmarshal(raw, &code) != nil {
+		return ProofTypeUnknown, errors.New("invalid proof type")
+	}
+
+	if code < int(ProofTypeDeadEnd) || code >= int(ProofTypeUnknown) {
+		return ProofTypeUnknown, errors.New("invalid proof type")
+	}
+
+	return ProofType(code), nil
+}
+
+func decodeJSONHex(s string, strict bool) ([]byte, error) {
+	if strict && strings.ContainsAny(s, "ABCDEF") {
+		return nil, errors.New("uppercase hex")
+	}
+
+	return hex.DecodeString(s)
+}
+
+func decodeJSONHash(s string, strict bool) (UrkelHash, error) {
+	var hash UrkelHash
+
+	if len(s) != 2*UrkelHashSize {
+		return hash, errors.New("invalid hash length")
+	}
+
+	data, err := decodeJSONHex(s, strict)
+
+	if err != nil {
+		return hash, err
+	}
+
+	copy(hash[:], data)
+
+	return hash, nil
+}
+
+func decodeJSONValue(s string, strict bool) ([]byte, error) {
+	value, err := decodeJSONHex(s, strict)
+
+	if err == nil || strict {
+		return value, err
+	}
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if value, err := enc.DecodeString(s); err == nil {
+			return value, nil
+		}
+	}
+
+	return nil, errors.New("invalid value encoding")
+}
@@ -0,0 +1,131 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONObjectNodesRoundTrip(t *testing.T) {
+	strict := &JSONOptions{Strict: true}
+	objects := &JSONOptions{Nodes: JSONNodesObject}
+
+	for i, proof := range fixtureProofs(t) {
+		data, err := proof.MarshalJSONWith(objects)
+
+		if err != nil {
+			t.Fatalf("%d: MarshalJSONWith failed: %s", i, err)
+		}
+
+		decoded, err := NewFromJSONWith(data, nil)
+
+		if err != nil {
+			t.Fatalf("%d: NewFromJSONWith failed: %s", i, err)
+		}
+
+		if !bytes.Equal(serialized(t, decoded), serialized(t, proof)) {
+			t.Fatalf("%d: round trip mismatch", i)
+		}
+
+		if len(proof.nodes) > 0 {
+			if _, err := NewFromJSONWith(data, strict); err == nil {
+				t.Fatalf("%d: strict accepted object nodes", i)
+			}
+		}
+
+		hsd, err := proof.MarshalJSONWith(nil)
+
+		if err != nil {
+			t.Fatalf("%d: MarshalJSONWith failed: %s", i, err)
+		}
+
+		legacy, err := json.Marshal(proof)
+
+		if err != nil {
+			t.Fatalf("%d: MarshalJSON failed: %s", i, err)
+		}
+
+		if !bytes.Equal(hsd, legacy) {
+			t.Fatalf("%d: default format differs from MarshalJSON", i)
+		}
+
+		if _, err := NewFromJSONWith(hsd, strict); err != nil {
+			t.Fatalf("%d: strict rejected hsd format: %s", i, err)
+		}
+	}
+}
+
+func TestJSONLenientForms(t *testing.T) {
+	hash := strings.Repeat("ab", UrkelHashSize)
+	value := []byte{0xde, 0xad, 0xbe, 0xef, 0x01}
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"numeric-type", `{"type":3,"depth":1,"nodes":[["1","` + hash + `"]],"value":"` + hex.EncodeToString(value) + `"}`},
+		{"uppercase-hex", `{"type":"TYPE_EXISTS","depth":1,"nodes":[["1","` + strings.ToUpper(hash) + `"]],"value":"` + strings.ToUpper(hex.EncodeToString(value)) + `"}`},
+		{"object-nodes", `{"type":"TYPE_EXISTS","depth":1,"nodes":[{"prefix":"1","hash":"` + hash + `"}],"value":"` + hex.EncodeToString(value) + `"}`},
+		{"base64-value", `{"type":"TYPE_EXISTS","depth":1,"nodes":[["1","` + hash + `"]],"value":"` + base64.StdEncoding.EncodeToString(value) + `"}`},
+		{"base64url-value", `{"type":"TYPE_EXISTS","depth":1,"nodes":[["1","` + hash + `"]],"value":"` + base64.RawURLEncoding.EncodeToString(value) + `"}`},
+	}
+
+	expect, err := NewExists(1, value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, _ := NewBitsFromString("1")
+	nodeHash, _ := hex.DecodeString(hash)
+	var h UrkelHash
+	copy(h[:], nodeHash)
+	expect.Push(*prefix, h)
+
+	for _, test := range tests {
+		var p Proof
+
+		if err := json.Unmarshal([]byte(test.json), &p); err != nil {
+			t.Fatalf("%s: lenient decoding failed: %s", test.name, err)
+		}
+
+		if !bytes.Equal(serialized(t, &p), serialized(t, expect)) {
+			t.Fatalf("%s: decoded proof mismatch", test.name)
+		}
+
+		if _, err := NewFromJSONWith([]byte(test.json), &JSONOptions{Strict: true}); err == nil {
+			t.Fatalf("%s: strict decoding succeeded", test.name)
+		}
+	}
+}
+
+func TestJSONInvalidForms(t *testing.T) {
+	hash := strings.Repeat("ab", UrkelHashSize)
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"type-out-of-range", `{"type":4,"depth":0,"nodes":[]}`},
+		{"type-negative", `{"type":-1,"depth":0,"nodes":[]}`},
+		{"type-bool", `{"type":true,"depth":0,"nodes":[]}`},
+		{"node-short-hash", `{"type":"TYPE_DEADEND","depth":1,"nodes":[["1","abab"]]}`},
+		{"node-length", `{"type":"TYPE_DEADEND","depth":1,"nodes":[["1","` + hash + `","00"]]}`},
+		{"value-encoding", `{"type":"TYPE_EXISTS","depth":0,"nodes":[],"value":"!!"}`},
+		{"depth-negative", `{"type":"TYPE_DEADEND","depth":-1,"nodes":[]}`},
+		{"depth-too-large", `{"type":"TYPE_DEADEND","depth":257,"nodes":[]}`},
+	}
+
+	for _, test := range tests {
+		if _, err := NewFromJSONWith([]byte(test.json), nil); err == nil {
+			t.Fatalf("%s: decoding succeeded", test.name)
+		}
+
+		if _, err := NewFromJSONWith([]byte(test.json), &JSONOptions{Strict: true}); err == nil {
+			t.Fatalf("%s: strict decoding succeeded", test.name)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Format is the urkel tree variant a proof was created by.
+type Format int
+
+const (
+	// FormatRadix is the radix tree hsd uses, proven by Proof.
+	FormatRadix Format = iota
+
+	// FormatTrie is a base-2 merkelized trie without skip prefixes, the
+	// shape of urkel's original trie, proven by TrieProof.
+	FormatTrie
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatRadix:
+		return "radix"
+	case FormatTrie:
+		return "trie"
+	}
+
+	return "unknown"
+}
+
+func StringToFormat(s string) (Format, error) {
+	switch s {
+	case "radix":
+		return FormatRadix, nil
+	case "trie":
+		return FormatTrie, nil
+	}
+
+	return 0, errors.New("unknown proof format")
+}
+
+// FormatProof is a decoded proof of any format.
+type FormatProof interface {
+	Type() ProofType
+	Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte)
+	Serialize(w io.Writer) error
+}
+
+// DecodeProof reads a proof in the given format.
+func DecodeProof(format Format, r io.Reader) (FormatProof, error) {
+	switch format {
+	case FormatRadix:
+		return NewFromReader(r)
+	case FormatTrie:
+		return NewTrieProofFromReader(r)
+	}
+
+	return nil, errors.New("unknown proof format")
+}
+
+func DecodeProofBytes(format Format, b []byte) (FormatProof, error) {
+	return DecodeProof(format, bytes.NewReader(b))
+}
+
+// TrieProof is a proof from a base-2 trie. Every level of the trie
+// branches on one key bit and has no skip prefix, so the proof carries
+// one sibling hash per bit of depth. Nodes hash like the radix tree's
+// with empty prefixes.
+//
+// The serialization is the radix one without prefixes: the type and depth
+// (uint16), the sibling count (uint16), a bitfield of empty siblings,
+// which are left out, the non-empty siblings and the type's fields.
+//
+// This layout follows the radix format rather than urkel's sources, and
+// it has not been checked against proofs produced by urkel's trie.
+type TrieProof struct {
+	ptype ProofType
+	depth int
+
+	nodes []UrkelHash
+
+	hash      UrkelHash
+	key       UrkelHash
+	value     UrkelValue
+	valueSize uint16
+}
+
+func (p *TrieProof) Type() ProofType {
+	return p.ptype
+}
+
+func (p *TrieProof) Depth() int {
+	return p.depth
+}
+
+// Nodes returns the sibling hashes, ordered from the root down.
+func (p *TrieProof) Nodes() []UrkelHash {
+	return p.nodes
+}
+
+// Key returns the colliding key of a TYPE_COLLISION proof.
+func (p *TrieProof) Key() UrkelHash {
+	return p.key
+}
+
+// Hash returns the colliding value hash of a TYPE_COLLISION proof.
+func (p *TrieProof) Hash() UrkelHash {
+	return p.hash
+}
+
+func (p *TrieProof) Value() []byte {
+	return p.value[:p.valueSize]
+}
+
+func (p *TrieProof) IsSane() bool {
+	if p.depth > UrkelKeyBits || len(p.nodes) != p.depth {
+		return false
+	}
+
+	switch p.ptype {
+	case ProofTypeDeadEnd, ProofTypeCollision:
+		return p.valueSize == 0
+	case ProofTypeExists:
+		return p.valueSize <= UrkelValueSize
+	}
+
+	return false
+}
+
+func (p *TrieProof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
+	return p.VerifyWith(DefaultHasher(), root, key)
+}
+
+// VerifyWith is Verify for trees hashed with hasher.
+func (p *TrieProof) VerifyWith(hasher Hasher, root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
+	if !p.IsSane() {
+		return ProofInvalid, nil
+	}
+
+	var leaf UrkelHash
+	var err error
+
+	switch p.ptype {
+	case ProofTypeDeadEnd:
+		// Leaf is already zero.
+	case ProofTypeCollision:
+		if p.key == key {
+			return ProofSameKey, nil
+		}
+
+		leaf, err = hashLeaf(hasher, p.key, p.hash)
+	case ProofTypeExists:
+		leaf, err = hashValue(hasher, key, p.value, p.valueSize)
+	}
+
+	if err != nil {
+		return ProofInvalid, nil
+	}
+
+	next := leaf
+
+	for i := len(p.nodes) - 1; i >= 0; i-- {
+		if hasBit(key[:], i) {
+			next, err = hashInternal(hasher, Bits{}, p.nodes[i], next)
+		} else {
+			next, err = hashInternal(hasher, Bits{}, next, p.nodes[i])
+		}
+
+		if err != nil {
+			return ProofInvalid, nil
+		}
+	}
+
+	if next != root {
+		return ProofHashMismatch, nil
+	}
+
+	return ProofOk, p.Value()
+}
+
+func (p *TrieProof) Serialize(w io.Writer) error {
+	var err error
+	var zero UrkelHash
+
+	count := len(p.nodes)
+	field := uint16(p.ptype<<14) | uint16(p.depth)
+	bits := make([]byte, (count+7)/8)
+
+	for i, node := range p.nodes {
+		if node == zero {
+			setBit(bits, i, 1)
+		}
+	}
+
+	if err = writeUint16(w, field); err != nil {
+		return err
+	}
+
+	if err = writeUint16(w, uint16(count)); err != nil {
+		return err
+	}
+
+	if err = writeBytesFull(w, bits); err != nil {
+		return err
+	}
+
+	for _, node := range p.nodes {
+		if node == zero {
+			continue
+		}
+
+		if err = writeBytesFull(w, node[:]); err != nil {
+			return err
+		}
+	}
+
+	switch p.ptype {
+	case ProofTypeCollision:
+		if err = writeBytesFull(w, p.key[:]); err != nil {
+			return err
+		}
+
+		return writeBytesFull(w, p.hash[:])
+	case ProofTypeExists:
+		if err = writeUint16(w, p.valueSize); err != nil {
+			return err
+		}
+
+		return writeBytes(w, p.value[:], int(p.valueSize))
+	}
+
+	return nil
+}
+
+func (p *TrieProof) Deserialize(r io.Reader) error {
+	var field, count uint16
+	var err error
+
+	if field, err = readUint16(r); err != nil {
+		return err
+	}
+
+	if count, err = readUint16(r); err != nil {
+		return err
+	}
+
+	p.ptype = ProofType(field >> 14)
+	p.depth = int(field & (^(uint16(3) << uint16(14))))
+
+	if p.depth > UrkelKeyBits {
+		return errors.New("Invalid depth")
+	}
+
+	if count > UrkelKeyBits {
+		return errors.New("Proof too large")
+	}
+
+	bits := make([]byte, (int(count)+7)/8)
+
+	if err = readBytesFull(r, bits); err != nil {
+		return err
+	}
+
+	p.nodes = make([]UrkelHash, count)
+
+	for i := range p.nodes {
+		if getBit(bits, i) == 1 {
+			continue
+		}
+
+		if err = readBytesFull(r, p.nodes[i][:]); err != nil {
+			return err
+		}
+	}
+
+	switch p.ptype {
+	case ProofTypeDeadEnd:
+		// Nothing.
+	case ProofTypeCollision:
+		if err = readBytesFull(r, p.key[:]); err != nil {
+			return err
+		}
+
+		if err = readBytesFull(r, p.hash[:]); err != nil {
+			return err
+		}
+	case ProofTypeExists:
+		if p.valueSize, err = readUint16(r); err != nil {
+			return err
+		}
+
+		if p.valueSize > UrkelValueSize {
+			return errors.New("Value too large")
+		}
+
+		if err = readBytes(r, p.value[:], int(p.valueSize)); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Invalid proof type")
+	}
+
+	return nil
+}
+
+func NewTrieProofFromReader(r io.Reader) (*TrieProof, error) {
+	proof := &TrieProof{}
+
+	err := proof.Deserialize(r)
+
+	return proof, err
+}
+
+func NewTrieProofFromBytes(b []byte) (*TrieProof, error) {
+	return NewTrieProofFromReader(bytes.NewReader(b))
+}
@@ -0,0 +1,253 @@
+package proof
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+type trieItem struct {
+	key   UrkelHash
+	value []byte
+}
+
+// trieHash hashes the subtree of a base-2 trie holding items, which all
+// share the first depth bits. A single item is a leaf at the top of its
+// subtree.
+func trieHash(t *testing.T, items []trieItem, depth int) UrkelHash {
+	switch len(items) {
+	case 0:
+		return UrkelHash{}
+	case 1:
+		vhash, err := HashData(items[0].value)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf, err := hashLeaf(DefaultHasher(), items[0].key, vhash)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return leaf
+	}
+
+	left, right := splitItems(items, depth)
+
+	hash, err := hashInternal(DefaultHasher(), Bits{}, trieHash(t, left, depth+1), trieHash(t, right, depth+1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func splitItems(items []trieItem, depth int) ([]trieItem, []trieItem) {
+	var left, right []trieItem
+
+	for _, item := range items {
+		if hasBit(item.key[:], depth) {
+			right = append(right, item)
+		} else {
+			left = append(left, item)
+		}
+	}
+
+	return left, right
+}
+
+func trieProve(t *testing.T, items []trieItem, key UrkelHash) *TrieProof {
+	p := &TrieProof{}
+
+	for depth := 0; ; depth++ {
+		switch len(items) {
+		case 0:
+			p.ptype = ProofTypeDeadEnd
+			p.depth = depth
+			return p
+		case 1:
+			p.depth = depth
+
+			if items[0].key == key {
+				p.ptype = ProofTypeExists
+				p.valueSize = uint16(copy(p.value[:], items[0].value))
+				return p
+			}
+
+			vhash, err := HashData(items[0].value)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p.ptype = ProofTypeCollision
+			p.key = items[0].key
+			p.hash = vhash
+
+			return p
+		}
+
+		left, right := splitItems(items, depth)
+
+		if hasBit(key[:], depth) {
+			p.nodes = append(p.nodes, trieHash(t, left, depth+1))
+			items = right
+		} else {
+			p.nodes = append(p.nodes, trieHash(t, right, depth+1))
+			items = left
+		}
+	}
+}
+
+func TestTrieProof(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := make([]trieItem, 64)
+
+	for i := range items {
+		rng.Read(items[i].key[:])
+		items[i].value = make([]byte, 1+rng.Intn(40))
+		rng.Read(items[i].value)
+	}
+
+	// Two keys sharing 20 bits leave empty siblings along their path.
+	items[1].key = items[0].key
+	items[1].key[2] ^= 0x08
+
+	root := trieHash(t, items, 0)
+	keys := make([]UrkelHash, 0, 128)
+
+	for _, item := range items {
+		keys = append(keys, item.key)
+	}
+
+	for i := 0; i < 64; i++ {
+		var key UrkelHash
+		rng.Read(key[:])
+		keys = append(keys, key)
+	}
+
+	types := make(map[ProofType]bool)
+	zeros := false
+
+	for i, key := range keys {
+		p := trieProve(t, items, key)
+		types[p.ptype] = true
+
+		var buf bytes.Buffer
+
+		if err := p.Serialize(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := DecodeProofBytes(FormatTrie, buf.Bytes())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, node := range p.nodes {
+			if node == (UrkelHash{}) {
+				zeros = true
+			}
+		}
+
+		code, value := decoded.Verify(root, key)
+
+		if code != ProofOk {
+			t.Fatalf("key %d: %s", i, code)
+		}
+
+		if i < len(items) && !bytes.Equal(value, items[i].value) {
+			t.Fatalf("key %d: value mismatch", i)
+		}
+
+		if i >= len(items) && len(value) != 0 {
+			t.Fatalf("key %d: expected no value", i)
+		}
+
+		if code, _ := decoded.Verify(UrkelHash{1}, key); code != ProofHashMismatch {
+			t.Fatalf("key %d: expected hash mismatch, got %s", i, code)
+		}
+	}
+
+	if !types[ProofTypeExists] || !types[ProofTypeCollision] {
+		t.Fatal("expected exists and collision proofs")
+	}
+
+	if !zeros {
+		t.Fatal("expected empty siblings")
+	}
+
+	// An empty trie proves everything absent.
+	p := trieProve(t, nil, keys[0])
+
+	if code, _ := p.Verify(UrkelHash{}, keys[0]); code != ProofOk || p.ptype != ProofTypeDeadEnd {
+		t.Fatalf("empty trie: %s", code)
+	}
+}
+
+func TestTrieProofInvalid(t *testing.T) {
+	items := []trieItem{
+		{key: UrkelHash{0x00}, value: []byte{1}},
+		{key: UrkelHash{0x80}, value: []byte{2}},
+	}
+
+	root := trieHash(t, items, 0)
+
+	collision := trieProve(t, items, UrkelHash{0x40})
+
+	if code, _ := collision.Verify(root, UrkelHash{0x40}); code != ProofOk {
+		t.Fatalf("expected ok, got %s", code)
+	}
+
+	if code, _ := collision.Verify(root, collision.key); code != ProofSameKey {
+		t.Fatalf("expected same key, got %s", code)
+	}
+
+	// The other leaf off the key's path hashes to a different root.
+	if code, _ := collision.Verify(root, UrkelHash{0xc0}); code != ProofHashMismatch {
+		t.Fatalf("expected hash mismatch, got %s", code)
+	}
+
+	exists := trieProve(t, items, items[0].key)
+	exists.depth++
+
+	if code, _ := exists.Verify(root, items[0].key); code != ProofInvalid {
+		t.Fatalf("expected invalid, got %s", code)
+	}
+
+	if _, err := NewTrieProofFromBytes([]byte{0x00, 0x40, 0x00, 0x00}); err == nil {
+		t.Fatal("expected error for short proof type")
+	}
+}
+
+func TestFormatDecodeRadix(t *testing.T) {
+	p := NewDeadEnd(0)
+
+	var buf bytes.Buffer
+
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeProofBytes(FormatRadix, buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := decoded.(*Proof); !ok {
+		t.Fatal("expected a radix proof")
+	}
+
+	for _, f := range []Format{FormatRadix, FormatTrie} {
+		parsed, err := StringToFormat(f.String())
+
+		if err != nil || parsed != f {
+			t.Fatalf("format %s does not round trip", f)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Node tags of the serialized multi-key proof.
+const (
+	multiHash byte = iota
+	multiEmpty
+	multiInternal
+	multiValue
+	multiLeaf
+)
+
+// MaxMultiKeys is the maximum number of keys in one multi-key proof.
+const MaxMultiKeys = 0xffff
+
+// MultiProof proves several keys against one root. Path nodes shared by
+// the keys are stored once.
+//
+// The serialized form is the key count (uint16), the keys, and the
+// partial tree in pre-order. Each node starts with a tag: a hash for
+// subtrees no key descends into, an empty root, an internal node with its
+// prefix followed by both children, a leaf holding one of the keys (key
+// index and value), or a leaf for another key (key and value hash).
+type MultiProof struct {
+	keys []UrkelHash
+	root *witnessNode
+
+	// hasher built the proof or decoded it, and hashed its nodes.
+	hasher Hasher
+}
+
+// MultiResult is the verification result for one key of a MultiProof.
+// Value is nil for keys proven absent.
+type MultiResult struct {
+	Key   UrkelHash
+	Code  UrkelCode
+	Value []byte
+}
+
+// NewMultiProof merges single-key proofs, proofs[i] being for keys[i],
+// into a multi-key proof. All proofs must be for the same root.
+func NewMultiProof(keys []UrkelHash, proofs []*Proof) (*MultiProof, error) {
+	return NewMultiProofWith(DefaultHasher(), keys, proofs)
+}
+
+// NewMultiProofWith is NewMultiProof for trees hashed with hasher.
+func NewMultiProofWith(hasher Hasher, keys []UrkelHash, proofs []*Proof) (*MultiProof, error) {
+	if len(keys) != len(proofs) {
+		return nil, errors.New("key and proof count mismatch")
+	}
+
+	if len(keys) == 0 || len(keys) > MaxMultiKeys {
+		return nil, errors.New("invalid key count")
+	}
+
+	if !proofs[0].IsSane() {
+		return nil, errors.New("invalid proof")
+	}
+
+	leaf, err := proofs[0].leafHash(hasher, keys[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := fold(hasher, keys[0], leaf, proofs[0].nodes, proofs[0].depth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	witness := NewWitnessWith(hasher, root)
+
+	for i, key := range keys {
+		if err := witness.Add(key, proofs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	mp := &MultiProof{
+		keys:   make([]UrkelHash, len(keys)),
+		root:   witness.root,
+		hasher: hasher,
+	}
+
+	copy(mp.keys, keys)
+
+	return mp, nil
+}
+
+// Keys returns the proven keys.
+func (mp *MultiProof) Keys() []UrkelHash {
+	return mp.keys
+}
+
+// Root returns the root the proof hashes to with the hasher it was built
+// or decoded with.
+func (mp *MultiProof) Root() UrkelHash {
+	return mp.root.hash
+}
+
+// Verify checks the proof against root and returns a result per key.
+func (mp *MultiProof) Verify(root UrkelHash) []MultiResult {
+	return mp.VerifyWith(DefaultHasher(), root)
+}
+
+// VerifyWith is Verify for trees hashed with hasher. The nodes are hashed
+// again with hasher, whichever hasher decoded the proof.
+func (mp *MultiProof) VerifyWith(hasher Hasher, root UrkelHash) []MultiResult {
+	results := make([]MultiResult, len(mp.keys))
+	witness := &Witness{root: mp.root, hasher: hasher}
+	hash, err := hashWitness(hasher, mp.root)
+
+	for i, key := range mp.keys {
+		results[i].Key = key
+
+		if err != nil {
+			results[i].Code = ProofInvalid
+			continue
+		}
+
+		if hash != root {
+			results[i].Code = ProofHashMismatch
+			continue
+		}
+
+		value, err := witness.Get(key)
+
+		if err != nil {
+			results[i].Code = ProofInvalid
+			continue
+		}
+
+		results[i].Code = ProofOk
+		results[i].Value = value
+	}
+
+	return results
+}
+
+// Proofs splits the multi-key proof into single-key proofs, one per key.
+func (mp *MultiProof) Proofs() ([]*Proof, error) {
+	witness := &Witness{root: mp.root, hasher: mp.hasher}
+	proofs := make([]*Proof, len(mp.keys))
+
+	for i, key := range mp.keys {
+		p, err := witness.Prove(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		proofs[i] = p
+	}
+
+	return proofs, nil
+}
+
+func (mp *MultiProof) Serialize(w io.Writer) error {
+	if len(mp.keys) > MaxMultiKeys {
+		return errors.New("too many keys")
+	}
+
+	if err := writeUint16(w, uint16(len(mp.keys))); err != nil {
+		return err
+	}
+
+	index := make(map[UrkelHash]int, len(mp.keys))
+
+	for i, key := range mp.keys {
+		if err := writeBytesFull(w, key[:]); err != nil {
+			return err
+		}
+
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+
+	return writeMultiNode(w, mp.root, index)
+}
+
+func (mp *MultiProof) Deserialize(r io.Reader) error {
+	count, err := readUint16(r)
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return errors.New("invalid key count")
+	}
+
+	keys := make([]UrkelHash, count)
+
+	for i := range keys {
+		if err := readBytesFull(r, keys[i][:]); err != nil {
+			return err
+		}
+	}
+
+	if mp.hasher == nil {
+		mp.hasher = DefaultHasher()
+	}
+
+	root, err := readMultiNode(mp.hasher, r, keys, 0)
+
+	if err != nil {
+		return err
+	}
+
+	mp.keys = keys
+	mp.root = root
+
+	return nil
+}
+
+func writeMultiNode(w io.Writer, n *witnessNode, index map[UrkelHash]int) error {
+	var err error
+
+	switch n.kind {
+	case witnessHash:
+		if err = writeByte(w, multiHash); err != nil {
+			return err
+		}
+
+		return writeBytesFull(w, n.hash[:])
+	case witnessEmpty:
+		return writeByte(w, multiEmpty)
+	case witnessInternal:
+		if err = writeByte(w, multiInternal); err != nil {
+			return err
+		}
+
+		if err = n.prefix.Serialize(w); err != nil {
+			return err
+		}
+
+		if err = writeMultiNode(w, n.left, index); err != nil {
+			return err
+		}
+
+		return writeMultiNode(w, n.right, index)
+	case witnessLeaf:
+		if i, ok := index[n.key]; ok && n.value != nil {
+			if err = writeByte(w, multiValue); err != nil {
+				return err
+			}
+
+			if err = writeUint16(w, uint16(i)); err != nil {
+				return err
+			}
+
+			if err = writeUint16(w, uint16(len(n.value))); err != nil {
+				return err
+			}
+
+			return writeBytesFull(w, n.value)
+		}
+
+		if err = writeByte(w, multiLeaf); err != nil {
+			return err
+		}
+
+		if err = writeBytesFull(w, n.key[:]); err != nil {
+			return err
+		}
+
+		return writeBytesFull(w, n.vhash[:])
+	}
+
+	return errors.New("invalid node")
+}
+
+func readMultiNode(hasher Hasher, r io.Reader, keys []UrkelHash, depth int) (*witnessNode, error) {
+	tag, err := readByte(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n := &witnessNode{}
+
+	switch tag {
+	case multiHash:
+		n.kind = witnessHash
+
+		if err = readBytesFull(r, n.hash[:]); err != nil {
+			return nil, err
+		}
+	case multiEmpty:
+		// Only the root of an empty tree is empty.
+		if depth != 0 {
+			return nil, errors.New("unexpected empty node")
+		}
+
+		n.kind = witnessEmpty
+	case multiInternal:
+		n.kind = witnessInternal
+
+		if err = n.prefix.Deserialize(r); err != nil {
+			return nil, err
+		}
+
+		depth += n.prefix.size
+
+		if depth >= UrkelKeyBits {
+			return nil, errors.New("proof too deep")
+		}
+
+		if n.left, err = readMultiNode(hasher, r, keys, depth+1); err != nil {
+			return nil, err
+		}
+
+		if n.right, err = readMultiNode(hasher, r, keys, depth+1); err != nil {
+			return nil, err
+		}
+
+		if n.hash, err = hashInternal(hasher, n.prefix, n.left.hash, n.right.hash); err != nil {
+			return nil, err
+		}
+	case multiValue:
+		n.kind = witnessLeaf
+
+		i, err := readUint16(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if int(i) >= len(keys) {
+			return nil, errors.New("invalid key index")
+		}
+
+		size, err := readUint16(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if size > UrkelValueSize {
+			return nil, errors.New("value too long")
+		}
+
+		n.key = keys[i]
+		n.value = make([]byte, size)
+
+		if err = readBytesFull(r, n.value); err != nil {
+			return nil, err
+		}
+
+		if n.vhash, err = hashData(hasher, n.value); err != nil {
+			return nil, err
+		}
+
+		if n.hash, err = hashLeaf(hasher, n.key, n.vhash); err != nil {
+			return nil, err
+		}
+	case multiLeaf:
+		n.kind = witnessLeaf
+
+		if err = readBytesFull(r, n.key[:]); err != nil {
+			return nil, err
+		}
+
+		if err = readBytesFull(r, n.vhash[:]); err != nil {
+			return nil, err
+		}
+
+		if n.hash, err = hashLeaf(hasher, n.key, n.vhash); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid node tag")
+	}
+
+	return n, nil
+}
+
+func NewMultiProofFromReader(r io.Reader) (*MultiProof, error) {
+	return NewMultiProofFromReaderWith(DefaultHasher(), r)
+}
+
+func NewMultiProofFromBytes(b []byte) (*MultiProof, error) {
+	return NewMultiProofFromReader(bytes.NewReader(b))
+}
+
+// NewMultiProofFromReaderWith decodes a multi-key proof of a tree hashed
+// with hasher.
+func NewMultiProofFromReaderWith(hasher Hasher, r io.Reader) (*MultiProof, error) {
+	mp := &MultiProof{hasher: hasher}
+
+	err := mp.Deserialize(r)
+
+	return mp, err
+}
+
+func NewMultiProofFromBytesWith(hasher Hasher, b []byte) (*MultiProof, error) {
+	return NewMultiProofFromReaderWith(hasher, bytes.NewReader(b))
+}
+
+// hashWitness hashes the partial tree at n with hasher.
+func hashWitness(hasher Hasher, n *witnessNode) (UrkelHash, error) {
+	switch n.kind {
+	case witnessHash:
+		return n.hash, nil
+	case witnessEmpty:
+		return UrkelHash{}, nil
+	case witnessInternal:
+		left, err := hashWitness(hasher, n.left)
+
+		if err != nil {
+			return UrkelHash{}, err
+		}
+
+		right, err := hashWitness(hasher, n.right)
+
+		if err != nil {
+			return UrkelHash{}, err
+		}
+
+		return hashInternal(hasher, n.prefix, left, right)
+	case witnessLeaf:
+		vhash := n.vhash
+
+		if n.value != nil {
+			var err error
+
+			if vhash, err = hashData(hasher, n.value); err != nil {
+				return UrkelHash{}, err
+			}
+		}
+
+		return hashLeaf(hasher, n.key, vhash)
+	}
+
+	return UrkelHash{}, errors.New("invalid node")
+}
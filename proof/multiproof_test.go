@@ -0,0 +1,254 @@
+package proof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+func TestMultiProof(t *testing.T) {
+	keys, values := randomItems(14, 1100)
+	tree := buildTree(t, keys[:1000], values[:1000])
+	root := tree.RootHash()
+
+	// 50 present and 50 absent keys.
+	var proveKeys []proof.UrkelHash
+	var proofs []*proof.Proof
+	single := 0
+
+	for i := 950; i < 1050; i++ {
+		p, err := tree.Prove(keys[i])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proveKeys = append(proveKeys, keys[i])
+		proofs = append(proofs, p)
+		single += len(serializeProof(t, p))
+	}
+
+	mp, err := proof.NewMultiProof(proveKeys, proofs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := mp.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len()*2 > single {
+		t.Fatalf("multi proof is %d bytes, single proofs are %d", buf.Len(), single)
+	}
+
+	decoded, err := proof.NewMultiProofFromBytes(buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Root() != root {
+		t.Fatal("root mismatch")
+	}
+
+	for i, res := range decoded.Verify(root) {
+		if res.Code != proof.ProofOk {
+			t.Fatalf("key %d: %s", i, res.Code)
+		}
+
+		if res.Key != proveKeys[i] {
+			t.Fatalf("key %d: key mismatch", i)
+		}
+
+		if i < 50 && !bytes.Equal(res.Value, values[950+i]) {
+			t.Fatalf("key %d: value mismatch", i)
+		}
+
+		if i >= 50 && res.Value != nil {
+			t.Fatalf("key %d: expected absent", i)
+		}
+	}
+
+	split, err := decoded.Proofs()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range split {
+		if !bytes.Equal(serializeProof(t, p), serializeProof(t, proofs[i])) {
+			t.Fatalf("key %d: split proof mismatch", i)
+		}
+	}
+
+	var wrong proof.UrkelHash
+
+	for _, res := range decoded.Verify(wrong) {
+		if res.Code != proof.ProofHashMismatch {
+			t.Fatalf("expected hash mismatch, got %s", res.Code)
+		}
+	}
+}
+
+func TestMultiProofCorrupt(t *testing.T) {
+	keys, values := randomItems(15, 100)
+	tree := buildTree(t, keys, values)
+	root := tree.RootHash()
+
+	var proofs []*proof.Proof
+
+	for _, key := range keys[:10] {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proofs = append(proofs, p)
+	}
+
+	mp, err := proof.NewMultiProof(keys[:10], proofs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := mp.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := buf.Bytes()
+
+	// Flipping any byte past the key list must fail decoding or change
+	// the root.
+	for i := 2 + 10*proof.UrkelKeySize; i < len(raw); i++ {
+		corrupt := append([]byte(nil), raw...)
+		corrupt[i] ^= 0x01
+
+		decoded, err := proof.NewMultiProofFromBytes(corrupt)
+
+		if err != nil {
+			continue
+		}
+
+		for _, res := range decoded.Verify(root) {
+			if res.Code == proof.ProofOk {
+				t.Fatalf("byte %d: corrupted proof verified", i)
+			}
+		}
+	}
+
+	if _, err := proof.NewMultiProofFromBytes(raw[:len(raw)-1]); err == nil {
+		t.Fatal("expected error for truncated proof")
+	}
+}
+
+func TestMultiProofRootMismatch(t *testing.T) {
+	keys, values := randomItems(16, 20)
+	a := buildTree(t, keys[:10], values[:10])
+	b := buildTree(t, keys[10:], values[10:])
+
+	pa, err := a.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pb, err := b.Prove(keys[10])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = proof.NewMultiProof(keys[:1], []*proof.Proof{pa})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := proof.NewMultiProof([]proof.UrkelHash{keys[0], keys[10]}, []*proof.Proof{pa, pb}); err == nil {
+		t.Fatal("expected error for proofs against different roots")
+	}
+}
+
+func TestMultiProofWithHasher(t *testing.T) {
+	keys, values := randomItems(19, 60)
+	tree := urkel.NewWithHasher(proof.SHA256)
+
+	for i, key := range keys[:40] {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := tree.RootHash()
+	proofs := make([]*proof.Proof, len(keys))
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proofs[i] = p
+	}
+
+	if _, err := proof.NewMultiProof(keys, proofs); err == nil {
+		t.Fatal("default hasher accepted SHA-256 proofs")
+	}
+
+	mp, err := proof.NewMultiProofWith(proof.SHA256, keys, proofs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := mp.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := proof.NewMultiProofFromBytesWith(proof.SHA256, buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mp.Root() != root || decoded.Root() != root {
+		t.Fatal("root mismatch")
+	}
+
+	// Verification hashes again, whatever the proof was decoded with.
+	blake, err := proof.NewMultiProofFromBytes(buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*proof.MultiProof{mp, decoded, blake} {
+		for i, res := range p.VerifyWith(proof.SHA256, root) {
+			if res.Code != proof.ProofOk {
+				t.Fatalf("key %d: %s", i, res.Code)
+			}
+
+			if i < 40 && !bytes.Equal(res.Value, values[i]) {
+				t.Fatalf("key %d: value mismatch", i)
+			}
+		}
+
+		for _, res := range p.Verify(root) {
+			if res.Code != proof.ProofHashMismatch {
+				t.Fatalf("default hasher: expected hash mismatch, got %s", res.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package proof
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const MaxNameSize = 63
+
+// VerifyName checks that name is a valid Handshake name.
+func VerifyName(name string) bool {
+	if len(name) == 0 || len(name) > MaxNameSize {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+
+		switch {
+		case ch >= '0' && ch <= '9':
+		case ch >= 'a' && ch <= 'z':
+		case ch == '-' || ch == '_':
+			if i == 0 || i == len(name)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// HashName returns the tree key of a name.
+func HashName(name string) (UrkelHash, error) {
+	var key UrkelHash
+
+	if !VerifyName(name) {
+		return key, errors.New("invalid name")
+	}
+
+	key = sha3.Sum256([]byte(name))
+
+	return key, nil
+}
@@ -0,0 +1,41 @@
+package proof
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestVerifyName(t *testing.T) {
+	valid := []string{"a", "handshake", "nodech", "0x", "hsd-utils", "a_b", "63charsxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"}
+	invalid := []string{"", "-a", "a-", "_a", "a_", "Handshake", "a.b", "a b", "64charsxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"}
+
+	for _, name := range valid {
+		if !VerifyName(name) {
+			t.Errorf("Expected %q to be valid", name)
+		}
+	}
+
+	for _, name := range invalid {
+		if VerifyName(name) {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestHashName(t *testing.T) {
+	// SHA3-256("hello")
+	expected := "3338be694f50c5f338814986cdf0686453a888b84f424d792af4b9202398f392"
+	key, err := HashName("hello")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hex.EncodeToString(key[:]) != expected {
+		t.Errorf("Hash mismatch: %x != %s", key, expected)
+	}
+
+	if _, err = HashName("Hello"); err == nil {
+		t.Errorf("Expected invalid name error")
+	}
+}
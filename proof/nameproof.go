@@ -0,0 +1,110 @@
+package proof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNameMismatch = errors.New("name does not hash to key")
+	ErrMissingProof = errors.New("missing proof")
+)
+
+// NameProof is the result of hsd's getnameproof RPC: a proof together with
+// the block and tree root it was created at.
+type NameProof struct {
+	Hash   string `json:"hash"`
+	Height uint32 `json:"height"`
+	Root   string `json:"root"`
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Proof  *Proof `json:"proof"`
+}
+
+// VerifyError is returned when the proof itself does not verify. Err is
+// the validator's error for ProofBadValue.
+type VerifyError struct {
+	Code UrkelCode
+	Err  error
+}
+
+func (e *VerifyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("proof verification failed: %s: %s", e.Code, e.Err)
+	}
+
+	return fmt.Sprintf("proof verification failed: %s", e.Code)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify checks the name against the key and the proof against the root.
+// It returns the value for names in the tree and nil for names that are
+// proven absent.
+func (np *NameProof) Verify() ([]byte, error) {
+	return np.VerifyWithOptions(nil)
+}
+
+// VerifyWithOptions is Verify with the proof checked as by
+// Proof.VerifyWithOptions, for example with ValidateNameState.
+func (np *NameProof) VerifyWithOptions(opts *VerifyOptions) ([]byte, error) {
+	if np.Proof == nil {
+		return nil, ErrMissingProof
+	}
+
+	var root, key UrkelHash
+
+	rawRoot, err := decodeHashHex(np.Root)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid root: %w", err)
+	}
+
+	rawKey, err := decodeHashHex(np.Key)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	copy(root[:], rawRoot)
+	copy(key[:], rawKey)
+
+	nameHash, err := HashName(np.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nameHash != key {
+		return nil, ErrNameMismatch
+	}
+
+	code, value, err := np.Proof.VerifyWithOptions(root, key, opts)
+
+	if code != ProofOk {
+		return nil, &VerifyError{code, err}
+	}
+
+	if np.Proof.Type() != ProofTypeExists {
+		return nil, nil
+	}
+
+	return value, nil
+}
+
+func NewNameProofFromJSON(b []byte) (*NameProof, error) {
+	np := &NameProof{}
+
+	if err := json.Unmarshal(b, np); err != nil {
+		return nil, err
+	}
+
+	if np.Proof == nil {
+		return nil, ErrMissingProof
+	}
+
+	return np, nil
+}
@@ -0,0 +1,141 @@
+package proof
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// nameProofJSON builds a getnameproof result for name at root.
+func nameProofJSON(t *testing.T, name string, root UrkelHash, p *Proof) []byte {
+	key, err := HashName(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofJSON, err := p.MarshalJSON()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(fmt.Sprintf(`{
+		"hash": "%x",
+		"height": 100,
+		"root": "%x",
+		"name": "%s",
+		"key": "%x",
+		"proof": %s
+	}`, UrkelHash{0xaa}, root, name, key, proofJSON))
+}
+
+// singleLeafRoot returns the root of a tree holding only name.
+func singleLeafRoot(t *testing.T, name string, value []byte) UrkelHash {
+	key, _ := HashName(name)
+	vhash, err := HashData(value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := HashLeaf(key, vhash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestNameProofVerify(t *testing.T) {
+	value := []byte("name state")
+	root := singleLeafRoot(t, "handshake", value)
+	exists, err := NewExists(0, value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	np, err := NewNameProofFromJSON(nameProofJSON(t, "handshake", root, exists))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if np.Height != 100 || np.Name != "handshake" {
+		t.Errorf("Envelope mismatch: %+v", np)
+	}
+
+	got, err := np.Verify()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(value) {
+		t.Errorf("Value mismatch: %q", got)
+	}
+
+	// The same tree proves a different name absent with a collision.
+	otherKey, _ := HashName("handshake")
+	vhash, _ := HashData(value)
+	collision := NewCollision(0, otherKey, vhash)
+
+	np, err = NewNameProofFromJSON(nameProofJSON(t, "other", root, collision))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err = np.Verify(); err != nil || got != nil {
+		t.Errorf("Expected absence, got %q %v", got, err)
+	}
+}
+
+func TestNameProofErrors(t *testing.T) {
+	value := []byte("name state")
+	root := singleLeafRoot(t, "handshake", value)
+	exists, _ := NewExists(0, value)
+
+	np, err := NewNameProofFromJSON(nameProofJSON(t, "handshake", root, exists))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	np.Name = "other"
+
+	if _, err = np.Verify(); err != ErrNameMismatch {
+		t.Errorf("Expected ErrNameMismatch, got %v", err)
+	}
+
+	np.Name = "handshake"
+	np.Root = "zz"
+
+	if _, err = np.Verify(); err == nil {
+		t.Errorf("Expected invalid root error")
+	}
+
+	np.Root = hex.EncodeToString(root[:])
+	np.Key = np.Key[:62]
+
+	if _, err = np.Verify(); err == nil {
+		t.Errorf("Expected invalid key error")
+	}
+
+	key, _ := HashName("handshake")
+	np.Key = hex.EncodeToString(key[:])
+	np.Root = hex.EncodeToString(make([]byte, 32))
+
+	var verr *VerifyError
+
+	if _, err = np.Verify(); !errors.As(err, &verr) || verr.Code != ProofHashMismatch {
+		t.Errorf("Expected hash mismatch, got %v", err)
+	}
+
+	if _, err = NewNameProofFromJSON([]byte(`{"name": "handshake"}`)); err != ErrMissingProof {
+		t.Errorf("Expected ErrMissingProof, got %v", err)
+	}
+}
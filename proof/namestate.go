@@ -0,0 +1,248 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxResourceSize is the largest resource hsd accepts in a name's data.
+const MaxResourceSize = 512
+
+// NameState flags, in the order hsd writes them.
+const (
+	nameFlagTransfer = 1 << iota
+	nameFlagRevoked
+	nameFlagClaimed
+	nameFlagRenewals
+	nameFlagRegistered
+	nameFlagExpired
+	nameFlagWeak
+)
+
+// Outpoint references a transaction output.
+type Outpoint struct {
+	Hash  UrkelHash
+	Index uint32
+}
+
+// NameState is a name's state as stored in the tree, the value of an
+// existing name's proof. Data holds the name's resource.
+type NameState struct {
+	Name       string
+	Data       []byte
+	Height     uint32
+	Renewal    uint32
+	Owner      Outpoint
+	Value      uint64
+	Highest    uint64
+	Transfer   uint32
+	Revoked    uint32
+	Claimed    uint32
+	Renewals   uint32
+	Registered bool
+	Expired    bool
+	Weak       bool
+}
+
+func (ns *NameState) Serialize(w io.Writer) error {
+	if len(ns.Name) > 0xff || len(ns.Data) > 0xffff {
+		return errors.New("name state too large")
+	}
+
+	var field byte
+
+	optional := []struct {
+		flag  byte
+		value uint32
+	}{
+		{nameFlagTransfer, ns.Transfer},
+		{nameFlagRevoked, ns.Revoked},
+		{nameFlagClaimed, ns.Claimed},
+		{nameFlagRenewals, ns.Renewals},
+	}
+
+	for _, o := range optional {
+		if o.value != 0 {
+			field |= o.flag
+		}
+	}
+
+	if ns.Registered {
+		field |= nameFlagRegistered
+	}
+
+	if ns.Expired {
+		field |= nameFlagExpired
+	}
+
+	if ns.Weak {
+		field |= nameFlagWeak
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteByte(byte(len(ns.Name)))
+	buf.WriteString(ns.Name)
+	writeUint16(&buf, uint16(len(ns.Data)))
+	buf.Write(ns.Data)
+	writeUint32(&buf, ns.Height)
+	writeUint32(&buf, ns.Renewal)
+	buf.Write(ns.Owner.Hash[:])
+	writeUint32(&buf, ns.Owner.Index)
+	writeUint64(&buf, ns.Value)
+	writeUint64(&buf, ns.Highest)
+	buf.WriteByte(field)
+
+	for _, o := range optional {
+		if o.value != 0 {
+			writeUint32(&buf, o.value)
+		}
+	}
+
+	return writeBytesFull(w, buf.Bytes())
+}
+
+func (ns *NameState) Deserialize(r io.Reader) error {
+	var out NameState
+
+	size, err := readByte(r)
+
+	if err != nil {
+		return err
+	}
+
+	name := make([]byte, size)
+
+	if err = readBytesFull(r, name); err != nil {
+		return err
+	}
+
+	out.Name = string(name)
+
+	dataSize, err := readUint16(r)
+
+	if err != nil {
+		return err
+	}
+
+	out.Data = make([]byte, dataSize)
+
+	if err = readBytesFull(r, out.Data); err != nil {
+		return err
+	}
+
+	if out.Height, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if out.Renewal, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if err = readBytesFull(r, out.Owner.Hash[:]); err != nil {
+		return err
+	}
+
+	if out.Owner.Index, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if out.Value, err = readUint64(r); err != nil {
+		return err
+	}
+
+	if out.Highest, err = readUint64(r); err != nil {
+		return err
+	}
+
+	field, err := readByte(r)
+
+	if err != nil {
+		return err
+	}
+
+	for _, o := range []struct {
+		flag  byte
+		value *uint32
+	}{
+		{nameFlagTransfer, &out.Transfer},
+		{nameFlagRevoked, &out.Revoked},
+		{nameFlagClaimed, &out.Claimed},
+		{nameFlagRenewals, &out.Renewals},
+	} {
+		if field&o.flag == 0 {
+			continue
+		}
+
+		if *o.value, err = readUint32(r); err != nil {
+			return err
+		}
+	}
+
+	out.Registered = field&nameFlagRegistered != 0
+	out.Expired = field&nameFlagExpired != 0
+	out.Weak = field&nameFlagWeak != 0
+
+	*ns = out
+
+	return nil
+}
+
+// NewNameStateFromBytes decodes a name state that fills all of b.
+func NewNameStateFromBytes(b []byte) (*NameState, error) {
+	ns := &NameState{}
+	r := bytes.NewReader(b)
+
+	if err := ns.Deserialize(r); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if r.Len() != 0 {
+		return nil, errors.New("trailing name state data")
+	}
+
+	return ns, nil
+}
+
+// ValueValidator checks the value of an existing key once its proof has
+// verified.
+type ValueValidator func(key UrkelHash, value []byte) error
+
+// ValidateNameState accepts values that are a well-formed name state for
+// a valid name hashing to key, with a resource within MaxResourceSize.
+func ValidateNameState(key UrkelHash, value []byte) error {
+	ns, err := NewNameStateFromBytes(value)
+
+	if err != nil {
+		return fmt.Errorf("invalid name state: %w", err)
+	}
+
+	nameHash, err := HashName(ns.Name)
+
+	if err != nil {
+		return fmt.Errorf("invalid name state: %w", err)
+	}
+
+	if nameHash != key {
+		return ErrNameMismatch
+	}
+
+	if len(ns.Data) > MaxResourceSize {
+		return errors.New("invalid name state: resource too large")
+	}
+
+	return nil
+}
+
+// MaxValueSize returns a validator that rejects values longer than size.
+func MaxValueSize(size int) ValueValidator {
+	return func(key UrkelHash, value []byte) error {
+		if len(value) > size {
+			return fmt.Errorf("value size %d exceeds %d", len(value), size)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,191 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testNameState() *NameState {
+	return &NameState{
+		Name:       "handshake",
+		Data:       []byte{0x00, 0x01, 0x02},
+		Height:     2016,
+		Renewal:    4032,
+		Owner:      Outpoint{Hash: UrkelHash{0xab}, Index: 1},
+		Value:      1000000,
+		Highest:    2000000,
+		Transfer:   0,
+		Revoked:    0,
+		Claimed:    7,
+		Renewals:   3,
+		Registered: true,
+		Weak:       true,
+	}
+}
+
+func encodeNameState(t *testing.T, ns *NameState) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := ns.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNameStateEncoding(t *testing.T) {
+	ns := testNameState()
+	data := encodeNameState(t, ns)
+
+	expect := "09" + hex.EncodeToString([]byte("handshake")) +
+		"0300" + "000102" +
+		"e0070000" + "c00f0000" +
+		"ab" + hex.EncodeToString(make([]byte, 31)) + "01000000" +
+		"40420f0000000000" + "80841e0000000000" +
+		"5c" + "07000000" + "03000000"
+
+	if hex.EncodeToString(data) != expect {
+		t.Fatalf("encoding mismatch:\n%x\n%s", data, expect)
+	}
+
+	decoded, err := NewNameStateFromBytes(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, ns) {
+		t.Fatalf("decoded mismatch: %+v", decoded)
+	}
+
+	if _, err := NewNameStateFromBytes(data[:len(data)-1]); err == nil {
+		t.Error("Expected error for truncated name state")
+	}
+
+	if _, err := NewNameStateFromBytes(append(data, 0)); err == nil {
+		t.Error("Expected error for trailing data")
+	}
+}
+
+func TestValidateNameState(t *testing.T) {
+	ns := testNameState()
+	key, _ := HashName(ns.Name)
+	value := encodeNameState(t, ns)
+
+	if err := ValidateNameState(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := HashName("other")
+
+	if err := ValidateNameState(other, value); err != ErrNameMismatch {
+		t.Errorf("Expected ErrNameMismatch, got %v", err)
+	}
+
+	if err := ValidateNameState(key, []byte("garbage")); err == nil {
+		t.Error("Expected error for garbage value")
+	}
+
+	ns.Data = make([]byte, MaxResourceSize+1)
+
+	if err := ValidateNameState(key, encodeNameState(t, ns)); err == nil {
+		t.Error("Expected error for large resource")
+	}
+
+	ns.Name = "Bad"
+
+	if err := ValidateNameState(key, encodeNameState(t, ns)); err == nil {
+		t.Error("Expected error for invalid name")
+	}
+}
+
+func TestVerifyWithOptions(t *testing.T) {
+	good := encodeNameState(t, testNameState())
+	key, _ := HashName("handshake")
+
+	for _, tc := range []struct {
+		value []byte
+		code  UrkelCode
+	}{
+		{good, ProofOk},
+		{[]byte("garbage"), ProofBadValue},
+	} {
+		root := singleLeafRoot(t, "handshake", tc.value)
+		exists, _ := NewExists(0, tc.value)
+		opts := &VerifyOptions{ValidateValue: ValidateNameState}
+
+		// The hash check passes either way.
+		if code, _ := exists.Verify(root, key); code != ProofOk {
+			t.Fatalf("Expected PROOF_OK, got %s", code)
+		}
+
+		code, value, err := exists.VerifyWithOptions(root, key, opts)
+
+		if code != tc.code {
+			t.Fatalf("Expected %s, got %s", tc.code, code)
+		}
+
+		if (code == ProofBadValue) != (err != nil) {
+			t.Fatalf("Unexpected error for %s: %v", code, err)
+		}
+
+		if code == ProofOk && !bytes.Equal(value, tc.value) {
+			t.Fatal("Value mismatch")
+		}
+
+		if code != ProofOk && value != nil {
+			t.Fatal("Rejected value returned")
+		}
+
+		// The hash check still runs first.
+		if code, _, _ := exists.VerifyWithOptions(UrkelHash{}, key, opts); code != ProofHashMismatch {
+			t.Fatalf("Expected PROOF_HASH_MISMATCH, got %s", code)
+		}
+
+		np, err := NewNameProofFromJSON(nameProofJSON(t, "handshake", root, exists))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = np.VerifyWithOptions(opts)
+
+		var verr *VerifyError
+
+		if tc.code == ProofOk && err != nil {
+			t.Fatal(err)
+		}
+
+		if tc.code != ProofOk && (!errors.As(err, &verr) || verr.Code != ProofBadValue || verr.Err == nil) {
+			t.Fatalf("Expected bad value error, got %v", err)
+		}
+	}
+
+	// Validators do not run for absent keys.
+	called := false
+	opts := &VerifyOptions{ValidateValue: func(UrkelHash, []byte) error {
+		called = true
+		return nil
+	}}
+
+	if code, _, _ := NewDeadEnd(0).VerifyWithOptions(UrkelHash{}, key, opts); code != ProofOk || called {
+		t.Fatalf("Unexpected result %s, called %v", code, called)
+	}
+
+	value := []byte("0123456789")
+	root := singleLeafRoot(t, "handshake", value)
+	exists, _ := NewExists(0, value)
+
+	if code, _, _ := exists.VerifyWithOptions(root, key, &VerifyOptions{ValidateValue: MaxValueSize(9)}); code != ProofBadValue {
+		t.Fatalf("Expected PROOF_BAD_VALUE, got %s", code)
+	}
+
+	if code, _, _ := exists.VerifyWithOptions(root, key, &VerifyOptions{ValidateValue: MaxValueSize(10)}); code != ProofOk {
+		t.Fatalf("Expected PROOF_OK, got %s", code)
+	}
+}
@@ -50,8 +50,51 @@ func (p *Proof) Value() []byte {
 	return p.value[:p.valueSize]
 }
 
+func (p *Proof) Depth() int {
+	return p.depth
+}
+
+// Nodes returns the sibling nodes, ordered from the root down.
+func (p *Proof) Nodes() []*ProofNode {
+	return p.nodes
+}
+
+// Prefix returns the skip prefix of a TYPE_SHORT proof.
+func (p *Proof) Prefix() Bits {
+	return p.prefix
+}
+
+// Left returns the left child hash of a TYPE_SHORT proof.
+func (p *Proof) Left() UrkelHash {
+	return p.left
+}
+
+// Right returns the right child hash of a TYPE_SHORT proof.
+func (p *Proof) Right() UrkelHash {
+	return p.right
+}
+
+// Key returns the colliding key of a TYPE_COLLISION proof.
+func (p *Proof) Key() UrkelHash {
+	return p.key
+}
+
+// Hash returns the value hash of the colliding leaf of a TYPE_COLLISION
+// proof.
+func (p *Proof) Hash() UrkelHash {
+	return p.hash
+}
+
+func (pn *ProofNode) Prefix() Bits {
+	return pn.prefix
+}
+
+func (pn *ProofNode) Hash() UrkelHash {
+	return pn.hash
+}
+
 func (p *Proof) IsSane() bool {
-	if p.depth > UrkelKeyBits {
+	if p.depth < 0 || p.depth > UrkelKeyBits {
 		return false
 	}
 
@@ -113,7 +156,18 @@ func (p *Proof) IsSane() bool {
 }
 
 func (p *Proof) Push(prefix Bits, hash UrkelHash) {
-	p.nodes = append(p.nodes, newProofNode(prefix, hash))
+	p.nodes = append(p.nodes, NewProofNode(prefix, hash))
+}
+
+// WithDepthAndNodes returns a copy of p with its depth and nodes replaced.
+// The copy is not checked and need not be sane, which makes it useful for
+// building invalid proofs in tests.
+func (p *Proof) WithDepthAndNodes(depth int, nodes []*ProofNode) *Proof {
+	out := *p
+	out.depth = depth
+	out.nodes = append([]*ProofNode{}, nodes...)
+
+	return &out
 }
 
 func (p *Proof) Deserialize(r io.Reader) error {
@@ -147,7 +201,7 @@ func (p *Proof) Deserialize(r io.Reader) error {
 	}
 
 	for i := 0; i < int(count); i++ {
-		node := newProofNode(Bits{}, UrkelHash{})
+		node := NewProofNode(Bits{}, UrkelHash{})
 
 		if getBit(bits, i) == 1 {
 			if err = node.prefix.Deserialize(r); err != nil {
@@ -198,6 +252,10 @@ func (p *Proof) Deserialize(r io.Reader) error {
 			return err
 		}
 
+		if p.valueSize > UrkelValueSize {
+			return errors.New("Value too large")
+		}
+
 		if err = readBytes(r, p.value[:], int(p.valueSize)); err != nil {
 			return err
 		}
@@ -279,6 +337,34 @@ func (p *Proof) Serialize(w io.Writer) error {
 }
 
 func (p *Proof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
+	return p.VerifyWith(DefaultHasher(), root, key)
+}
+
+// VerifyWith is Verify for trees hashed with hasher.
+func (p *Proof) VerifyWith(hasher Hasher, root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
+	return p.verify(hasher, root, key, nil)
+}
+
+// TraceStep is a hash computed during verification. Steps are reported
+// from the leaf up to the root.
+type TraceStep struct {
+	// Node is the index in Nodes of the sibling hashed with the path, or
+	// -1 for the leaf.
+	Node int
+
+	// Depth is the depth of the computed hash in the tree.
+	Depth int
+
+	// Prefix, Left and Right are the inputs of an internal hash. They are
+	// zero for leaves other than TYPE_SHORT.
+	Prefix Bits
+	Left   UrkelHash
+	Right  UrkelHash
+
+	Hash UrkelHash
+}
+
+func (p *Proof) verify(hasher Hasher, root UrkelHash, key UrkelHash, trace func(TraceStep)) (UrkelCode, []byte) {
 	if p.IsSane() == false {
 		return ProofInvalid, nil
 	}
@@ -294,16 +380,16 @@ func (p *Proof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
 			return ProofSamePath, nil
 		}
 
-		leaf, err = hashInternal(p.prefix, p.left, p.right)
+		leaf, err = hashInternal(hasher, p.prefix, p.left, p.right)
 
 	case ProofTypeCollision:
 		if bytes.Compare(p.key[:], key[:]) == 0 {
 			return ProofSameKey, nil
 		}
 
-		leaf, err = hashLeaf(p.key, p.hash)
+		leaf, err = hashLeaf(hasher, p.key, p.hash)
 	case ProofTypeExists:
-		leaf, err = hashValue(key, p.value, p.valueSize)
+		leaf, err = hashValue(hasher, key, p.value, p.valueSize)
 	default:
 		return ProofInvalid, nil
 	}
@@ -312,6 +398,16 @@ func (p *Proof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
 		return ProofInvalid, nil
 	}
 
+	if trace != nil {
+		step := TraceStep{Node: -1, Depth: p.depth, Hash: leaf}
+
+		if p.ptype == ProofTypeShort {
+			step.Prefix, step.Left, step.Right = p.prefix, p.left, p.right
+		}
+
+		trace(step)
+	}
+
 	next := leaf
 	depth := p.depth
 
@@ -324,18 +420,23 @@ func (p *Proof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
 
 		depth -= 1
 
+		left, right := next, node.hash
+
 		if hasBit(key[:], depth) {
-			next, err = hashInternal(node.prefix, node.hash, next)
-		} else {
-			next, err = hashInternal(node.prefix, next, node.hash)
+			left, right = node.hash, next
 		}
 
+		next, err = hashInternal(hasher, node.prefix, left, right)
 		depth -= node.prefix.size
 
 		if err != nil {
 			return ProofInvalid, nil
 		}
 
+		if trace != nil {
+			trace(TraceStep{i, depth, node.prefix, left, right, next})
+		}
+
 		if !node.prefix.Has(key, depth) {
 			return ProofPathMismatch, nil
 		}
@@ -352,114 +453,56 @@ func (p *Proof) Verify(root UrkelHash, key UrkelHash) (UrkelCode, []byte) {
 	return ProofOk, p.value[:p.valueSize]
 }
 
-// MarshalJSON customizes the JSON serialization.
-func (p *Proof) MarshalJSON() ([]byte, error) {
-	var prefix string
-	var left string
-	var right string
-	var key string
-	var hash string
-	var value string
-
-	switch p.Type() {
-	case ProofTypeDeadEnd:
-		// Nothing.
-	case ProofTypeShort:
-		prefix = p.prefix.String()
-		left = hex.EncodeToString(p.left[:])
-		right = hex.EncodeToString(p.right[:])
-	case ProofTypeCollision:
-		key = hex.EncodeToString(p.key[:])
-		hash = hex.EncodeToString(p.hash[:])
-	case ProofTypeExists:
-		value = hex.EncodeToString(p.value[:p.valueSize])
-	}
+// VerifyOptions configures VerifyWithOptions. Zero fields use the
+// defaults.
+type VerifyOptions struct {
+	Hasher Hasher
 
-	proofJSON := ProofJSON{
-		Ptype: p.ptype.String(),
-		Depth: p.depth,
-		Nodes: p.nodes,
-
-		Prefix: prefix,
-		Left:   left,
-		Right:  right,
-		Key:    key,
-		Hash:   hash,
-		Value:  value,
-	}
+	// ValidateValue is called with the value of an existing key after
+	// the hash check. Values it rejects verify to ProofBadValue.
+	ValidateValue ValueValidator
 
-	return json.Marshal(proofJSON)
+	// Trace, if set, is called for every hash computed on the way to the
+	// root.
+	Trace func(TraceStep)
 }
 
-func (p *Proof) UnmarshalJSON(b []byte) error {
-	var proofJSON ProofJSON
-
-	if err := json.Unmarshal(b, &proofJSON); err != nil {
-		return err
+// VerifyWithOptions is Verify with a hasher and value validation. The
+// error is the validator's reason for a ProofBadValue result and nil
+// otherwise.
+func (p *Proof) VerifyWithOptions(root UrkelHash, key UrkelHash, opts *VerifyOptions) (UrkelCode, []byte, error) {
+	if opts == nil {
+		opts = &VerifyOptions{}
 	}
 
-	p.ptype = StringToProofType(proofJSON.Ptype)
-	p.depth = proofJSON.Depth
-	p.nodes = proofJSON.Nodes
-
-	switch p.ptype {
-	case ProofTypeDeadEnd:
-		// Nothing.
-	case ProofTypeShort:
-		if err := p.prefix.FromString(proofJSON.Prefix); err != nil {
-			return err
-		}
-
-		left, err := decodeHashHex(proofJSON.Left)
-
-		if err != nil {
-			return err
-		}
+	hasher := opts.Hasher
 
-		copy(p.left[:], left)
-
-		right, err := decodeHashHex(proofJSON.Right)
-
-		if err != nil {
-			return err
-		}
-
-		copy(p.right[:], right)
-	case ProofTypeCollision:
-		key, err := decodeHashHex(proofJSON.Key)
-
-		if err != nil {
-			return err
-		}
-
-		copy(p.key[:], key)
-
-		hash, err := decodeHashHex(proofJSON.Hash)
-
-		if err != nil {
-			return err
-		}
+	if hasher == nil {
+		hasher = DefaultHasher()
+	}
 
-		copy(p.hash[:], hash)
-	case ProofTypeExists:
-		value, err := hex.DecodeString(proofJSON.Value)
+	code, value := p.verify(hasher, root, key, opts.Trace)
 
-		if err != nil {
-			return err
-		}
+	if code != ProofOk || p.ptype != ProofTypeExists || opts.ValidateValue == nil {
+		return code, value, nil
+	}
 
-		if len(value) > UrkelValueSize {
-			return errors.New("value too long")
-		}
+	if err := opts.ValidateValue(key, value); err != nil {
+		return ProofBadValue, nil, err
+	}
 
-		copy(p.value[:], value)
-		p.valueSize = uint16(len(value))
+	return code, value, nil
+}
 
-	default:
-		return errors.New("invalid proof type")
-	}
+// MarshalJSON customizes the JSON serialization.
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	return p.MarshalJSONWith(nil)
+}
 
-	return nil
+// UnmarshalJSON accepts hsd's JSON and the lenient forms described at
+// JSONOptions.
+func (p *Proof) UnmarshalJSON(b []byte) error {
+	return p.UnmarshalJSONWith(b, nil)
 }
 
 func (pn *ProofNode) MarshalJSON() ([]byte, error) {
@@ -470,36 +513,12 @@ func (pn *ProofNode) MarshalJSON() ([]byte, error) {
 }
 
 func (pn *ProofNode) UnmarshalJSON(data []byte) error {
-	var parts []string
-
-	if err := json.Unmarshal(data, &parts); err != nil {
-		return err
-	}
-
-	if len(parts) != 2 {
-		return errors.New("invalid proof node (length)")
-	}
-
-	if err := pn.prefix.FromString(parts[0]); err != nil {
-		return err
-	}
-
-	hash, err := hex.DecodeString(parts[1])
-
-	if err != nil {
-		return err
-	}
-
-	if len(hash) != UrkelHashSize {
-		return errors.New("invalid proof node (hash length)")
-	}
-
-	copy(pn.hash[:], hash)
-
-	return nil
+	return pn.unmarshalJSON(data, false)
 }
 
-func newProofNode(prefix Bits, hash UrkelHash) *ProofNode {
+// NewProofNode creates a proof node with prefix and the hash of the
+// sibling subtree.
+func NewProofNode(prefix Bits, hash UrkelHash) *ProofNode {
 	return &ProofNode{
 		prefix: prefix,
 		hash:   hash,
@@ -512,6 +531,44 @@ func New() *Proof {
 	}
 }
 
+func NewDeadEnd(depth int) *Proof {
+	proof := New()
+	proof.ptype = ProofTypeDeadEnd
+	proof.depth = depth
+	return proof
+}
+
+func NewShort(depth int, prefix Bits, left UrkelHash, right UrkelHash) *Proof {
+	proof := New()
+	proof.ptype = ProofTypeShort
+	proof.depth = depth
+	proof.prefix = prefix
+	proof.left = left
+	proof.right = right
+	return proof
+}
+
+func NewCollision(depth int, key UrkelHash, hash UrkelHash) *Proof {
+	proof := New()
+	proof.ptype = ProofTypeCollision
+	proof.depth = depth
+	proof.key = key
+	proof.hash = hash
+	return proof
+}
+
+func NewExists(depth int, value []byte) (*Proof, error) {
+	if len(value) > UrkelValueSize {
+		return nil, errors.New("value too long")
+	}
+
+	proof := New()
+	proof.ptype = ProofTypeExists
+	proof.depth = depth
+	proof.valueSize = uint16(copy(proof.value[:], value))
+	return proof, nil
+}
+
 func NewFromReader(r io.Reader) (*Proof, error) {
 	proof := New()
 
@@ -26,13 +26,24 @@ type testProof struct {
 	Json      jsonProof `json:"json"`
 	Root      string
 	Key       string
+	Code      UrkelCode `json:"code"`
+	Case      string    `json:"case"`
 }
 
 var testProofs []testProof = nil
 
+// generatedProofs also holds failure cases, see Code.
+var generatedProofs []testProof = nil
+
+//go:generate go run ../cmd/hsd-fixtures -out testdata/generated.json
+
 func init() {
-	path := "testdata/proofs.json"
-	testProofs = make([]testProof, 0)
+	testProofs = readTestProofs("testdata/proofs.json")
+	generatedProofs = readTestProofs("testdata/generated.json")
+}
+
+func readTestProofs(path string) []testProof {
+	proofs := make([]testProof, 0)
 
 	// read file
 	data, err := ioutil.ReadFile(path)
@@ -41,10 +52,12 @@ func init() {
 	}
 
 	// parse json
-	err = json.Unmarshal(data, &testProofs)
+	err = json.Unmarshal(data, &proofs)
 	if err != nil {
 		panic(err)
 	}
+
+	return proofs
 }
 
 func TestProofDecode(t *testing.T) {
@@ -154,6 +167,19 @@ func TestProofReencode(t *testing.T) {
 	}
 }
 
+func TestProofDecodeLargeValue(t *testing.T) {
+	// An EXISTS proof at depth 0 with no nodes and a 45866 byte value.
+	raw, _ := hex.DecodeString("00c000002ab3ec01")
+
+	if _, err := NewFromBytes(raw); err == nil {
+		t.Fatal("decoded a value larger than UrkelValueSize")
+	}
+
+	if NewDeadEnd(0).WithDepthAndNodes(-3, nil).IsSane() {
+		t.Fatal("negative depth is sane")
+	}
+}
+
 func TestProofVerify(t *testing.T) {
 	for _, tp := range testProofs {
 		var proof *Proof
@@ -187,6 +213,142 @@ func TestProofVerify(t *testing.T) {
 	}
 }
 
+func TestVerifyTrace(t *testing.T) {
+	for i, tp := range testProofs {
+		raw, _ := hex.DecodeString(tp.Raw)
+		proof, err := NewFromBytes(raw)
+
+		if err != nil {
+			t.Fatalf("%d: NewFromBytes failed: %s", i, err)
+		}
+
+		root, _ := readHash(tp.Root)
+		key, _ := readHash(tp.Key)
+
+		var steps []TraceStep
+
+		opts := &VerifyOptions{Trace: func(step TraceStep) {
+			steps = append(steps, step)
+		}}
+
+		if code, _, _ := proof.VerifyWithOptions(root, key, opts); code != ProofOk {
+			t.Fatalf("%d: Verify failed: %s", i, code)
+		}
+
+		nodes := proof.Nodes()
+
+		if len(steps) != len(nodes)+1 {
+			t.Fatalf("%d: expected %d steps, got %d", i, len(nodes)+1, len(steps))
+		}
+
+		if steps[0].Node != -1 || steps[0].Depth != proof.Depth() {
+			t.Fatalf("%d: first step is not the leaf", i)
+		}
+
+		for j, step := range steps[1:] {
+			node := nodes[len(nodes)-1-j]
+
+			if step.Node != len(nodes)-1-j {
+				t.Fatalf("%d: step %d has node %d", i, j+1, step.Node)
+			}
+
+			if step.Left != steps[j].Hash && step.Right != steps[j].Hash {
+				t.Fatalf("%d: step %d does not hash the path", i, j+1)
+			}
+
+			if step.Left != node.Hash() && step.Right != node.Hash() {
+				t.Fatalf("%d: step %d does not hash the sibling", i, j+1)
+			}
+
+			expect, _ := HashInternal(step.Prefix, step.Left, step.Right)
+
+			if step.Hash != expect {
+				t.Fatalf("%d: step %d hash mismatch", i, j+1)
+			}
+		}
+
+		last := steps[len(steps)-1]
+
+		if last.Hash != root || last.Depth != 0 {
+			t.Fatalf("%d: last step is not the root", i)
+		}
+	}
+}
+
+func TestGeneratedVerify(t *testing.T) {
+	for i, tp := range generatedProofs {
+		raw, err := hex.DecodeString(tp.Raw)
+
+		if err != nil {
+			t.Fatalf("hex.Decode failed: %s", err)
+		}
+
+		proof, err := NewFromBytes(raw)
+
+		if err != nil {
+			t.Fatalf("NewFromBytes failed: %s", err)
+		}
+
+		var encoded bytes.Buffer
+
+		if err = proof.Serialize(&encoded); err != nil {
+			t.Fatalf("Encode failed: %s", err)
+		}
+
+		if bytes.Compare(encoded.Bytes(), raw) != 0 {
+			t.Errorf("%d: Encode mismatch: %s != %s", i, hex.EncodeToString(encoded.Bytes()), tp.Raw)
+		}
+
+		root, _ := readHash(tp.Root)
+		key, _ := readHash(tp.Key)
+
+		if code, _ := proof.Verify(root, key); code != tp.Code {
+			t.Errorf("%d (%s): Verify returned %s, expected %s", i, tp.Case, code, tp.Code)
+		}
+	}
+}
+
+func TestWithDepthAndNodes(t *testing.T) {
+	for i, tp := range testProofs {
+		raw, _ := hex.DecodeString(tp.Raw)
+		proof, err := NewFromBytes(raw)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root, _ := readHash(tp.Root)
+		key, _ := readHash(tp.Key)
+
+		same := proof.WithDepthAndNodes(proof.Depth(), proof.Nodes())
+
+		if code, _ := same.Verify(root, key); code != ProofOk {
+			t.Fatalf("%d: copy does not verify: %s", i, code)
+		}
+
+		deeper := proof.WithDepthAndNodes(proof.Depth()+1, proof.Nodes())
+
+		if code, _ := deeper.Verify(root, key); code == ProofOk {
+			t.Fatalf("%d: deeper copy verified", i)
+		}
+
+		if proof.Depth() != tp.Json.Depth {
+			t.Fatalf("%d: original proof changed", i)
+		}
+
+		if len(proof.Nodes()) == 0 {
+			continue
+		}
+
+		node := NewProofNode(proof.Nodes()[0].Prefix(), UrkelHash{1})
+		nodes := append([]*ProofNode{node}, proof.Nodes()[1:]...)
+
+		if code, _ := proof.WithDepthAndNodes(proof.Depth(), nodes).Verify(root, key); code != ProofHashMismatch {
+			t.Fatalf("%d: expected PROOF_HASH_MISMATCH, got %s", i, code)
+		}
+	}
+}
+
 func TestJSONSerialize(t *testing.T) {
 	for _, tp := range testProofs {
 		var proof *Proof
@@ -320,3 +482,18 @@ func readHash(hexstr string) (hash UrkelHash, err error) {
 	copy(hash[:], raw)
 	return
 }
+
+func TestUrkelCodeString(t *testing.T) {
+	// The names follow urkel's errors.
+	for code, name := range map[UrkelCode]string{
+		ProofOk:        "PROOF_OK",
+		ProofTooDeep:   "PROOF_TOO_DEEP",
+		ProofInvalid:   "PROOF_UNKNOWN_ERROR",
+		ProofBadValue:  "PROOF_BAD_VALUE",
+		UrkelCode(100): "PROOF_UNKNOWN",
+	} {
+		if code.String() != name {
+			t.Errorf("code %d: expected %s, got %s", code, name, code)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package proof
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Field numbers and wire types from proof.proto.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+
+	protoProofType   = 1
+	protoProofDepth  = 2
+	protoProofNodes  = 3
+	protoProofPrefix = 4
+	protoProofLeft   = 5
+	protoProofRight  = 6
+	protoProofKey    = 7
+	protoProofHash   = 8
+	protoProofValue  = 9
+
+	protoNodePrefix = 1
+	protoNodeHash   = 2
+
+	protoBitsSize = 1
+	protoBitsData = 2
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+// MarshalProto encodes the proof as the Proof message of proof.proto.
+// Fields are written in field number order and zero values are omitted,
+// so equal proofs always encode to the same bytes.
+func (p *Proof) MarshalProto() ([]byte, error) {
+	if !p.IsSane() {
+		return nil, errors.New("protobuf: invalid proof")
+	}
+
+	var buf []byte
+
+	buf = appendProtoVarint(buf, protoProofType, uint64(p.ptype))
+	buf = appendProtoVarint(buf, protoProofDepth, uint64(p.depth))
+
+	for _, node := range p.nodes {
+		var msg []byte
+
+		if node.prefix.size > 0 {
+			msg = appendProtoBytes(msg, protoNodePrefix, marshalProtoBits(&node.prefix))
+		}
+
+		msg = appendProtoBytes(msg, protoNodeHash, node.hash[:])
+		buf = appendProtoBytes(buf, protoProofNodes, msg)
+	}
+
+	switch p.ptype {
+	case ProofTypeShort:
+		buf = appendProtoBytes(buf, protoProofPrefix, marshalProtoBits(&p.prefix))
+		buf = appendProtoBytes(buf, protoProofLeft, p.left[:])
+		buf = appendProtoBytes(buf, protoProofRight, p.right[:])
+	case ProofTypeCollision:
+		buf = appendProtoBytes(buf, protoProofKey, p.key[:])
+		buf = appendProtoBytes(buf, protoProofHash, p.hash[:])
+	case ProofTypeExists:
+		buf = appendProtoBytes(buf, protoProofValue, p.value[:p.valueSize])
+	}
+
+	return buf, nil
+}
+
+// UnmarshalProto decodes a Proof message. Unknown fields are skipped and
+// fields that do not belong to the proof type are ignored.
+func (p *Proof) UnmarshalProto(data []byte) error {
+	var prefix, left, right, key, hash, value []byte
+	var ptype, depth uint64
+
+	nodes := []*ProofNode{}
+	r := protoReader{data: data}
+
+	for !r.done() {
+		num, typ, err := r.tag()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == protoProofType && typ == protoVarint:
+			ptype, err = r.varint()
+		case num == protoProofDepth && typ == protoVarint:
+			depth, err = r.varint()
+		case num == protoProofNodes && typ == protoBytes:
+			var msg []byte
+
+			if msg, err = r.bytes(); err != nil {
+				return err
+			}
+
+			if len(nodes) == UrkelKeyBits {
+				return errors.New("protobuf: proof too large")
+			}
+
+			var node *ProofNode
+
+			if node, err = unmarshalProtoNode(msg); err != nil {
+				return err
+			}
+
+			nodes = append(nodes, node)
+		case num == protoProofPrefix && typ == protoBytes:
+			prefix, err = r.bytes()
+		case num == protoProofLeft && typ == protoBytes:
+			left, err = r.bytes()
+		case num == protoProofRight && typ == protoBytes:
+			right, err = r.bytes()
+		case num == protoProofKey && typ == protoBytes:
+			key, err = r.bytes()
+		case num == protoProofHash && typ == protoBytes:
+			hash, err = r.bytes()
+		case num == protoProofValue && typ == protoBytes:
+			value, err = r.bytes()
+		default:
+			err = r.skip(typ)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if depth > UrkelKeyBits {
+		return errors.New("protobuf: invalid depth")
+	}
+
+	out := Proof{
+		ptype: ProofType(ptype),
+		depth: int(depth),
+		nodes: nodes,
+	}
+
+	var err error
+
+	switch out.ptype {
+	case ProofTypeDeadEnd:
+		// Nothing.
+	case ProofTypeShort:
+		if err = unmarshalProtoBits(&out.prefix, prefix); err != nil {
+			return err
+		}
+
+		if out.prefix.size == 0 {
+			return errors.New("protobuf: invalid prefix size")
+		}
+
+		if err = copyHash(&out.left, left); err != nil {
+			return err
+		}
+
+		err = copyHash(&out.right, right)
+	case ProofTypeCollision:
+		if err = copyHash(&out.key, key); err != nil {
+			return err
+		}
+
+		err = copyHash(&out.hash, hash)
+	case ProofTypeExists:
+		if len(value) > UrkelValueSize {
+			return errors.New("protobuf: value too long")
+		}
+
+		out.valueSize = uint16(copy(out.value[:], value))
+	default:
+		return errors.New("protobuf: invalid proof type")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*p = out
+
+	return nil
+}
+
+func NewFromProto(b []byte) (*Proof, error) {
+	p := New()
+	err := p.UnmarshalProto(b)
+	return p, err
+}
+
+func unmarshalProtoNode(data []byte) (*ProofNode, error) {
+	var hash []byte
+
+	node := NewProofNode(Bits{}, UrkelHash{})
+	r := protoReader{data: data}
+
+	for !r.done() {
+		num, typ, err := r.tag()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case num == protoNodePrefix && typ == protoBytes:
+			var msg []byte
+
+			if msg, err = r.bytes(); err == nil {
+				err = unmarshalProtoBits(&node.prefix, msg)
+			}
+		case num == protoNodeHash && typ == protoBytes:
+			hash, err = r.bytes()
+		default:
+			err = r.skip(typ)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := copyHash(&node.hash, hash); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func marshalProtoBits(b *Bits) []byte {
+	var buf []byte
+
+	buf = appendProtoVarint(buf, protoBitsSize, uint64(b.size))
+	buf = appendProtoBytes(buf, protoBitsData, b.data[:b.DataByteSize()])
+
+	return buf
+}
+
+func unmarshalProtoBits(b *Bits, data []byte) error {
+	var size uint64
+	var bits []byte
+
+	r := protoReader{data: data}
+
+	for !r.done() {
+		num, typ, err := r.tag()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == protoBitsSize && typ == protoVarint:
+			size, err = r.varint()
+		case num == protoBitsData && typ == protoBytes:
+			bits, err = r.bytes()
+		default:
+			err = r.skip(typ)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if size > UrkelKeyBits {
+		return errors.New("protobuf: bitfield size too large")
+	}
+
+	if len(bits) != int(size+7)/8 {
+		return errors.New("protobuf: bitfield data size mismatch")
+	}
+
+	*b = Bits{size: int(size)}
+	copy(b.data[:], bits)
+
+	return nil
+}
+
+func copyHash(hash *UrkelHash, data []byte) error {
+	if len(data) != UrkelHashSize {
+		return errors.New("invalid hash length")
+	}
+
+	copy(hash[:], data)
+
+	return nil
+}
+
+func appendProtoTag(buf []byte, num int, typ int) []byte {
+	return binary.AppendUvarint(buf, uint64(num<<3|typ))
+}
+
+func appendProtoVarint(buf []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+
+	buf = appendProtoTag(buf, num, protoVarint)
+	return binary.AppendUvarint(buf, v)
+}
+
+func appendProtoBytes(buf []byte, num int, data []byte) []byte {
+	if len(data) == 0 {
+		return buf
+	}
+
+	buf = appendProtoTag(buf, num, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+type protoReader struct {
+	data []byte
+}
+
+func (r *protoReader) done() bool {
+	return len(r.data) == 0
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+
+	if n <= 0 {
+		return 0, errors.New("protobuf: invalid varint")
+	}
+
+	r.data = r.data[n:]
+
+	return v, nil
+}
+
+func (r *protoReader) tag() (int, int, error) {
+	v, err := r.varint()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if v>>3 == 0 || v>>3 > 1<<29-1 {
+		return 0, 0, errors.New("protobuf: invalid field number")
+	}
+
+	return int(v >> 3), int(v & 7), nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	size, err := r.varint()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if size > uint64(len(r.data)) {
+		return nil, errProtoTruncated
+	}
+
+	data := r.data[:size]
+	r.data = r.data[size:]
+
+	return data, nil
+}
+
+func (r *protoReader) skip(typ int) error {
+	var err error
+
+	switch typ {
+	case protoVarint:
+		_, err = r.varint()
+	case protoBytes:
+		_, err = r.bytes()
+	case protoFixed64, protoFixed32:
+		size := 8
+
+		if typ == protoFixed32 {
+			size = 4
+		}
+
+		if len(r.data) < size {
+			return errProtoTruncated
+		}
+
+		r.data = r.data[size:]
+	default:
+		err = errors.New("protobuf: unsupported wire type")
+	}
+
+	return err
+}
@@ -0,0 +1,153 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// fixtureProofs returns every proof from proofs.json and generated.json.
+func fixtureProofs(t *testing.T) []*Proof {
+	t.Helper()
+
+	var proofs []*Proof
+
+	for _, set := range [][]testProof{testProofs, generatedProofs} {
+		for _, tp := range set {
+			raw, err := hex.DecodeString(tp.Raw)
+
+			if err != nil {
+				t.Fatalf("hex.Decode failed: %s", err)
+			}
+
+			proof, err := NewFromBytes(raw)
+
+			if err != nil {
+				t.Fatalf("NewFromBytes failed: %s", err)
+			}
+
+			proofs = append(proofs, proof)
+		}
+	}
+
+	return proofs
+}
+
+func serialized(t *testing.T, p *Proof) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize failed: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	for i, proof := range fixtureProofs(t) {
+		data, err := proof.MarshalProto()
+
+		if err != nil {
+			t.Fatalf("%d: MarshalProto failed: %s", i, err)
+		}
+
+		decoded, err := NewFromProto(data)
+
+		if err != nil {
+			t.Fatalf("%d: NewFromProto failed: %s", i, err)
+		}
+
+		if !bytes.Equal(serialized(t, decoded), serialized(t, proof)) {
+			t.Fatalf("%d: round trip mismatch", i)
+		}
+
+		again, err := decoded.MarshalProto()
+
+		if err != nil {
+			t.Fatalf("%d: MarshalProto failed: %s", i, err)
+		}
+
+		if !bytes.Equal(again, data) {
+			t.Fatalf("%d: encoding is not deterministic", i)
+		}
+	}
+}
+
+func TestProtoEncoding(t *testing.T) {
+	var left, right UrkelHash
+
+	left[0] = 0xaa
+	right[31] = 0xbb
+
+	prefix, _ := NewBitsFromString("101")
+	proof := NewShort(2, *prefix, left, right)
+	proof.Push(Bits{}, right)
+
+	data, err := proof.MarshalProto()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "0801" + "1002" +
+		"1a22" + "1220" + hex.EncodeToString(right[:]) +
+		"2205" + "0803" + "1201a0" +
+		"2a20" + hex.EncodeToString(left[:]) +
+		"3220" + hex.EncodeToString(right[:])
+
+	if hex.EncodeToString(data) != expect {
+		t.Fatalf("encoding mismatch:\n%x\n%s", data, expect)
+	}
+
+	// Unknown fields of every wire type are skipped.
+	extra, _ := hex.DecodeString("f80701" + "f9070000000000000000" + "fa070100" + "fd0700000000")
+	decoded, err := NewFromProto(append(extra, data...))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(serialized(t, decoded), serialized(t, proof)) {
+		t.Fatal("decoded proof mismatch")
+	}
+
+	// The empty message is a dead end at depth zero.
+	decoded, err = NewFromProto(nil)
+
+	if err != nil || decoded.Type() != ProofTypeDeadEnd || decoded.Depth() != 0 {
+		t.Fatalf("empty message: %v", err)
+	}
+}
+
+func TestProtoInvalid(t *testing.T) {
+	hash := hex.EncodeToString(make([]byte, 32))
+
+	cases := map[string]string{
+		"truncated":     "1a22" + "1220" + hash[:60],
+		"bad varint":    "08ffffffffffffffffffff01",
+		"bad type":      "0804",
+		"bad depth":     "108102",
+		"short hash":    "1a03" + "120100",
+		"no prefix":     "0801" + "2a20" + hash + "3220" + hash,
+		"prefix data":   "0801" + "2204" + "0803" + "1200" + "2a20" + hash + "3220" + hash,
+		"missing key":   "0802" + "4220" + hash,
+		"wire type":     "0b",
+		"field zero":    "0001",
+		"big value":     "0803" + "4a8008" + hex.EncodeToString(make([]byte, 1024)),
+		"collision len": "0802" + "3a1f" + hash[:62] + "4220" + hash,
+	}
+
+	for name, h := range cases {
+		data, err := hex.DecodeString(h)
+
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if _, err := NewFromProto(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package proof
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// RecordType is the type of a resource record.
+type RecordType uint8
+
+const (
+	RecordDS RecordType = iota
+	RecordNS
+	RecordGlue4
+	RecordGlue6
+	RecordSynth4
+	RecordSynth6
+	RecordTXT
+)
+
+const (
+	// maxNameSize is the largest name in wire format, as in DNS.
+	maxNameSize = 255
+
+	// maxNamePointers bounds the compression pointers followed per name.
+	maxNamePointers = 16
+)
+
+var errResourceTruncated = errors.New("resource: truncated data")
+
+func (t RecordType) String() string {
+	switch t {
+	case RecordDS:
+		return "DS"
+	case RecordNS:
+		return "NS"
+	case RecordGlue4:
+		return "GLUE4"
+	case RecordGlue6:
+		return "GLUE6"
+	case RecordSynth4:
+		return "SYNTH4"
+	case RecordSynth6:
+		return "SYNTH6"
+	case RecordTXT:
+		return "TXT"
+	}
+
+	return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+}
+
+// Record is a record of a name's resource. Only the fields of its Type are
+// set: NS for NS and glue records, Address for glue and synth records.
+type Record struct {
+	Type RecordType
+
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+
+	NS      string
+	Address net.IP
+	TXT     []string
+}
+
+// Resource is the DNS data of a name, the Data of its NameState.
+type Resource struct {
+	Records []*Record `json:"records"`
+}
+
+// MarshalJSON writes the record in the form of hsd's getnameresource.
+func (rec *Record) MarshalJSON() ([]byte, error) {
+	out := map[string]any{"type": rec.Type.String()}
+
+	switch rec.Type {
+	case RecordDS:
+		out["keyTag"] = rec.KeyTag
+		out["algorithm"] = rec.Algorithm
+		out["digestType"] = rec.DigestType
+		out["digest"] = hex.EncodeToString(rec.Digest)
+	case RecordNS:
+		out["ns"] = rec.NS
+	case RecordGlue4, RecordGlue6:
+		out["ns"] = rec.NS
+		out["address"] = rec.Address.String()
+	case RecordSynth4, RecordSynth6:
+		out["address"] = rec.Address.String()
+	case RecordTXT:
+		out["txt"] = rec.TXT
+	default:
+		return nil, errors.New("resource: unknown record type")
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the record in the form of hsd's getnameresource.
+func (rec *Record) UnmarshalJSON(data []byte) error {
+	var in struct {
+		Type       string   `json:"type"`
+		KeyTag     uint16   `json:"keyTag"`
+		Algorithm  uint8    `json:"algorithm"`
+		DigestType uint8    `json:"digestType"`
+		Digest     string   `json:"digest"`
+		NS         string   `json:"ns"`
+		Address    string   `json:"address"`
+		TXT        []string `json:"txt"`
+	}
+
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	var out Record
+
+	for out.Type = RecordDS; out.Type.String() != in.Type; out.Type++ {
+		if out.Type == RecordTXT {
+			return fmt.Errorf("resource: unknown record type %q", in.Type)
+		}
+	}
+
+	switch out.Type {
+	case RecordDS:
+		digest, err := hex.DecodeString(in.Digest)
+
+		if err != nil {
+			return err
+		}
+
+		out.KeyTag = in.KeyTag
+		out.Algorithm = in.Algorithm
+		out.DigestType = in.DigestType
+		out.Digest = digest
+	case RecordNS:
+		out.NS = in.NS
+	case RecordGlue4, RecordGlue6, RecordSynth4, RecordSynth6:
+		if out.Type == RecordGlue4 || out.Type == RecordGlue6 {
+			out.NS = in.NS
+		}
+
+		out.Address = net.ParseIP(in.Address)
+
+		if out.Type == RecordGlue4 || out.Type == RecordSynth4 {
+			out.Address = out.Address.To4()
+		}
+
+		if out.Address == nil {
+			return errors.New("resource: invalid address")
+		}
+	case RecordTXT:
+		out.TXT = in.TXT
+	}
+
+	*rec = out
+
+	return nil
+}
+
+// NewResourceFromBytes decodes a resource in hsd's format: a version byte
+// followed by records, each a type byte and its data. Names use DNS label
+// encoding with compression pointers relative to the start of b. As in
+// hsd, decoding stops at the first record of an unknown type.
+func NewResourceFromBytes(b []byte) (*Resource, error) {
+	r := resourceReader{data: b}
+
+	version, err := r.byte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 {
+		return nil, fmt.Errorf("resource: unknown version %d", version)
+	}
+
+	res := &Resource{Records: []*Record{}}
+
+	for r.off < len(r.data) {
+		typ, _ := r.byte()
+		rec := &Record{Type: RecordType(typ)}
+
+		switch rec.Type {
+		case RecordDS:
+			err = r.ds(rec)
+		case RecordNS:
+			rec.NS, err = r.name()
+		case RecordGlue4, RecordGlue6:
+			if rec.NS, err = r.name(); err == nil {
+				rec.Address, err = r.ip(rec.Type == RecordGlue6)
+			}
+		case RecordSynth4, RecordSynth6:
+			rec.Address, err = r.ip(rec.Type == RecordSynth6)
+		case RecordTXT:
+			rec.TXT, err = r.txt()
+		default:
+			return res, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		res.Records = append(res.Records, rec)
+	}
+
+	return res, nil
+}
+
+type resourceReader struct {
+	data []byte
+	off  int
+}
+
+func (r *resourceReader) bytes(n int) ([]byte, error) {
+	if n > len(r.data)-r.off {
+		return nil, errResourceTruncated
+	}
+
+	data := r.data[r.off : r.off+n]
+	r.off += n
+
+	return data, nil
+}
+
+func (r *resourceReader) byte() (byte, error) {
+	data, err := r.bytes(1)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return data[0], nil
+}
+
+func (r *resourceReader) ds(rec *Record) error {
+	head, err := r.bytes(5)
+
+	if err != nil {
+		return err
+	}
+
+	rec.KeyTag = binary.BigEndian.Uint16(head)
+	rec.Algorithm = head[2]
+	rec.DigestType = head[3]
+
+	digest, err := r.bytes(int(head[4]))
+
+	if err != nil {
+		return err
+	}
+
+	rec.Digest = append([]byte(nil), digest...)
+
+	return nil
+}
+
+func (r *resourceReader) ip(v6 bool) (net.IP, error) {
+	size := net.IPv4len
+
+	if v6 {
+		size = net.IPv6len
+	}
+
+	data, err := r.bytes(size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(net.IP(nil), data...), nil
+}
+
+func (r *resourceReader) txt() ([]string, error) {
+	count, err := r.byte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	txt := make([]string, 0, count)
+
+	for i := 0; i < int(count); i++ {
+		size, err := r.byte()
+
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := r.bytes(int(size))
+
+		if err != nil {
+			return nil, err
+		}
+
+		txt = append(txt, string(data))
+	}
+
+	return txt, nil
+}
+
+// name reads a fully qualified name, following compression pointers.
+func (r *resourceReader) name() (string, error) {
+	var name strings.Builder
+
+	off := r.off
+	end := -1
+	size := 0
+	pointers := 0
+
+	for {
+		if off >= len(r.data) {
+			return "", errResourceTruncated
+		}
+
+		c := int(r.data[off])
+		off++
+
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				if end < 0 {
+					end = off
+				}
+
+				r.off = end
+
+				if name.Len() == 0 {
+					return ".", nil
+				}
+
+				return name.String(), nil
+			}
+
+			if off+c > len(r.data) {
+				return "", errResourceTruncated
+			}
+
+			if size += c + 1; size > maxNameSize {
+				return "", errors.New("resource: name too long")
+			}
+
+			writeLabel(&name, r.data[off:off+c])
+			name.WriteByte('.')
+			off += c
+		case 0xc0:
+			if off >= len(r.data) {
+				return "", errResourceTruncated
+			}
+
+			if pointers++; pointers > maxNamePointers {
+				return "", errors.New("resource: too many compression pointers")
+			}
+
+			if end < 0 {
+				end = off + 1
+			}
+
+			off = (c&0x3f)<<8 | int(r.data[off])
+		default:
+			return "", errors.New("resource: unknown label type")
+		}
+	}
+}
+
+// writeLabel writes a label in presentation format, escaping special
+// characters.
+func writeLabel(name *strings.Builder, label []byte) {
+	for _, ch := range label {
+		switch {
+		case strings.IndexByte(`.\()"; @`, ch) >= 0:
+			name.WriteByte('\\')
+			name.WriteByte(ch)
+		case ch < 0x21 || ch > 0x7e:
+			fmt.Fprintf(name, "\\%03d", ch)
+		default:
+			name.WriteByte(ch)
+		}
+	}
+}
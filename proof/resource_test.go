@@ -0,0 +1,108 @@
+package proof
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestResourceDecode(t *testing.T) {
+	data, _ := hex.DecodeString("00" +
+		// NS ns1.example.
+		"01" + "036e7331" + "076578616d706c65" + "00" +
+		// GLUE4 ns2.example. 1.2.3.4, pointing back to "example".
+		"02" + "036e7332" + "c006" + "01020304" +
+		// SYNTH6 2001:db8::1
+		"05" + "20010db8000000000000000000000001" +
+		// DS 12345 8 2 deadbeef
+		"00" + "3039" + "08" + "02" + "04" + "deadbeef" +
+		// TXT "hello", "a b"
+		"06" + "02" + "0568656c6c6f" + "03612062" +
+		// Unknown types end the records.
+		"07" + "ff")
+
+	res, err := NewResourceFromBytes(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(res)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `{"records":[` +
+		`{"ns":"ns1.example.","type":"NS"},` +
+		`{"address":"1.2.3.4","ns":"ns2.example.","type":"GLUE4"},` +
+		`{"address":"2001:db8::1","type":"SYNTH6"},` +
+		`{"algorithm":8,"digest":"deadbeef","digestType":2,"keyTag":12345,"type":"DS"},` +
+		`{"txt":["hello","a b"],"type":"TXT"}]}`
+
+	if string(got) != expect {
+		t.Fatalf("JSON mismatch:\n%s\n%s", got, expect)
+	}
+
+	if !reflect.DeepEqual(res.Records[4].TXT, []string{"hello", "a b"}) {
+		t.Fatal("TXT mismatch")
+	}
+
+	var decoded Resource
+
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(&decoded, res) {
+		t.Fatal("JSON round trip mismatch")
+	}
+
+	if err := json.Unmarshal([]byte(`{"records":[{"type":"MX"}]}`), &decoded); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+}
+
+func TestResourceNames(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		name string
+	}{
+		{"000100", "."},
+		{"000103612e6200", `a\.b.`},
+		{"00010161017f00", `a.\127.`},
+	} {
+		data, _ := hex.DecodeString(tc.data)
+		res, err := NewResourceFromBytes(data)
+
+		if err != nil {
+			t.Fatalf("%s: %s", tc.data, err)
+		}
+
+		if len(res.Records) != 1 || res.Records[0].NS != tc.name {
+			t.Fatalf("%s: expected %q", tc.data, tc.name)
+		}
+	}
+}
+
+func TestResourceInvalid(t *testing.T) {
+	for _, tc := range []string{
+		"",
+		"01",
+		"0001",
+		"000103616263",
+		"000102",
+		"0001c002",
+		"00014000",
+		"0004010203",
+		"00000102",
+		"0006020161",
+	} {
+		data, _ := hex.DecodeString(tc)
+
+		if _, err := NewResourceFromBytes(data); err == nil {
+			t.Fatalf("%s: expected error", tc)
+		}
+	}
+}
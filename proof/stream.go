@@ -0,0 +1,429 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"iter"
+	"sort"
+)
+
+// A proof stream starts with a 5 byte header (magic, flags) followed by
+// records:
+//
+//	u32 size | [root 32 | key 32] | proof | u32 crc32c
+//
+// size covers the optional root and key and the serialized proof, and
+// the CRC is computed over the same bytes. A zero size ends the records.
+// Close then writes the index, one key and u64 record offset per record
+// sorted by key, its CRC, and a 16 byte footer: u64 index offset, u32
+// record count and the index magic. All integers are little endian.
+const (
+	streamMagic = 0x706b7275
+	indexMagic  = 0x696b7275
+
+	streamFlagHeader = 1 << 0
+
+	streamHeaderSize = 5
+	indexEntrySize   = UrkelHashSize + 8
+	indexFooterSize  = 16
+
+	// maxRecordSize is well above the largest serialized proof.
+	maxRecordSize = 1 << 16
+)
+
+var (
+	ErrStreamMagic    = errors.New("proof stream: invalid magic")
+	ErrStreamChecksum = errors.New("proof stream: checksum mismatch")
+	ErrStreamClosed   = errors.New("proof stream: writer closed")
+	ErrNoIndex        = errors.New("proof stream: no index")
+	ErrRecordNotFound = errors.New("proof stream: record not found")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ProofRecord is a proof read from a stream. Root and Key are zero unless
+// the stream was written with headers, except that records found through
+// a ProofIndex always have Key. Offset is the record's position in the
+// stream.
+type ProofRecord struct {
+	Root   UrkelHash
+	Key    UrkelHash
+	Proof  *Proof
+	Offset int64
+}
+
+type indexEntry struct {
+	key    UrkelHash
+	offset int64
+}
+
+// ProofWriter writes proofs as a stream of checksummed records. After a
+// failed write the stream is broken, and every later Write and Close
+// returns the same error.
+type ProofWriter struct {
+	w      io.Writer
+	header bool
+	offset int64
+	index  []indexEntry
+	closed bool
+	err    error
+}
+
+// NewProofWriter writes the stream header to w. If header is set, every
+// record also stores its root and key.
+func NewProofWriter(w io.Writer, header bool) (*ProofWriter, error) {
+	pw := &ProofWriter{
+		w:      w,
+		header: header,
+	}
+
+	var buf [streamHeaderSize]byte
+
+	binary.LittleEndian.PutUint32(buf[:4], streamMagic)
+
+	if header {
+		buf[4] = streamFlagHeader
+	}
+
+	if err := pw.write(buf[:]); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+// Write appends a record for the proof of key under root. The key is
+// always added to the index, even if records carry no header.
+func (pw *ProofWriter) Write(root, key UrkelHash, p *Proof) error {
+	if pw.closed {
+		return ErrStreamClosed
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+
+	var body bytes.Buffer
+
+	if pw.header {
+		body.Write(root[:])
+		body.Write(key[:])
+	}
+
+	if err := p.Serialize(&body); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 8+body.Len())
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(body.Len()))
+	buf = append(buf, body.Bytes()...)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(body.Bytes(), crcTable))
+
+	offset := pw.offset
+
+	if err := pw.write(buf); err != nil {
+		return err
+	}
+
+	pw.index = append(pw.index, indexEntry{key, offset})
+
+	return nil
+}
+
+// Close ends the records and writes the index. It does not close the
+// underlying writer.
+func (pw *ProofWriter) Close() error {
+	if pw.closed {
+		return ErrStreamClosed
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+
+	pw.closed = true
+
+	var end [4]byte
+
+	if err := pw.write(end[:]); err != nil {
+		return err
+	}
+
+	// Equal keys keep their write order, so lookups find the last one.
+	sort.SliceStable(pw.index, func(i, j int) bool {
+		return bytes.Compare(pw.index[i].key[:], pw.index[j].key[:]) < 0
+	})
+
+	start := pw.offset
+	buf := make([]byte, 0, len(pw.index)*indexEntrySize+4+indexFooterSize)
+
+	for _, e := range pw.index {
+		buf = append(buf, e.key[:]...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.offset))
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, crcTable))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(start))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(pw.index)))
+	buf = binary.LittleEndian.AppendUint32(buf, indexMagic)
+
+	return pw.write(buf)
+}
+
+func (pw *ProofWriter) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+
+	if err != nil {
+		pw.err = err
+	}
+
+	return err
+}
+
+// ProofReader reads records from a stream one at a time.
+type ProofReader struct {
+	r      io.Reader
+	header bool
+	offset int64
+	done   bool
+}
+
+// NewProofReader reads the stream header from r.
+func NewProofReader(r io.Reader) (*ProofReader, error) {
+	var buf [streamHeaderSize]byte
+
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(buf[:4]) != streamMagic {
+		return nil, ErrStreamMagic
+	}
+
+	return &ProofReader{
+		r:      r,
+		header: buf[4]&streamFlagHeader != 0,
+		offset: streamHeaderSize,
+	}, nil
+}
+
+// Next returns the next record. It returns io.EOF after the last record,
+// including for streams whose writer was never closed.
+func (pr *ProofReader) Next() (*ProofRecord, error) {
+	if pr.done {
+		return nil, io.EOF
+	}
+
+	rec, n, err := readRecord(pr.r, pr.header)
+
+	if err == io.EOF {
+		pr.done = true
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec.Offset = pr.offset
+	pr.offset += n
+
+	return rec, nil
+}
+
+// All iterates over the remaining records, stopping at the first error.
+func (pr *ProofReader) All() iter.Seq2[*ProofRecord, error] {
+	return func(yield func(*ProofRecord, error) bool) {
+		for {
+			rec, err := pr.Next()
+
+			if err == io.EOF {
+				return
+			}
+
+			if !yield(rec, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRecord reads one record and returns its size in the stream. It
+// returns io.EOF at the end marker or at a clean end of r.
+func readRecord(r io.Reader, header bool) (*ProofRecord, int64, error) {
+	var buf [4]byte
+
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, 0, err
+	}
+
+	size := binary.LittleEndian.Uint32(buf[:])
+
+	if size == 0 {
+		return nil, 0, io.EOF
+	}
+
+	if size > maxRecordSize {
+		return nil, 0, errors.New("proof stream: record too large")
+	}
+
+	body := make([]byte, size+4)
+
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+
+	sum := binary.LittleEndian.Uint32(body[size:])
+	body = body[:size]
+
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, 0, ErrStreamChecksum
+	}
+
+	rec := &ProofRecord{}
+
+	if header {
+		if len(body) < 2*UrkelHashSize {
+			return nil, 0, errors.New("proof stream: record too short")
+		}
+
+		copy(rec.Root[:], body[:UrkelHashSize])
+		copy(rec.Key[:], body[UrkelHashSize:])
+		body = body[2*UrkelHashSize:]
+	}
+
+	br := bytes.NewReader(body)
+	p, err := NewFromReader(br)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if br.Len() != 0 {
+		return nil, 0, errors.New("proof stream: trailing record data")
+	}
+
+	rec.Proof = p
+
+	return rec, int64(size) + 8, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// ProofIndex reads records of a closed stream by key.
+type ProofIndex struct {
+	r       io.ReaderAt
+	header  bool
+	entries []indexEntry
+}
+
+// OpenProofIndex loads the index of the stream of the given size in r.
+func OpenProofIndex(r io.ReaderAt, size int64) (*ProofIndex, error) {
+	var head [streamHeaderSize]byte
+
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if binary.LittleEndian.Uint32(head[:4]) != streamMagic {
+		return nil, ErrStreamMagic
+	}
+
+	if size < streamHeaderSize+4+indexFooterSize {
+		return nil, ErrNoIndex
+	}
+
+	var footer [indexFooterSize]byte
+
+	if _, err := r.ReadAt(footer[:], size-indexFooterSize); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if binary.LittleEndian.Uint32(footer[12:]) != indexMagic {
+		return nil, ErrNoIndex
+	}
+
+	start := int64(binary.LittleEndian.Uint64(footer[:8]))
+	count := int64(binary.LittleEndian.Uint32(footer[8:12]))
+
+	if start < streamHeaderSize || start+count*indexEntrySize+4 != size-indexFooterSize {
+		return nil, errors.New("proof stream: invalid index footer")
+	}
+
+	data := make([]byte, count*indexEntrySize+4)
+
+	if _, err := r.ReadAt(data, start); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	sum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	data = data[:len(data)-4]
+
+	if crc32.Checksum(data, crcTable) != sum {
+		return nil, ErrStreamChecksum
+	}
+
+	entries := make([]indexEntry, count)
+
+	for i := range entries {
+		e := data[i*indexEntrySize:]
+		copy(entries[i].key[:], e[:UrkelHashSize])
+		entries[i].offset = int64(binary.LittleEndian.Uint64(e[UrkelHashSize:]))
+
+		if entries[i].offset < streamHeaderSize || entries[i].offset >= start {
+			return nil, errors.New("proof stream: invalid index offset")
+		}
+	}
+
+	return &ProofIndex{
+		r:       r,
+		header:  head[4]&streamFlagHeader != 0,
+		entries: entries,
+	}, nil
+}
+
+// Len returns the number of indexed records.
+func (ix *ProofIndex) Len() int {
+	return len(ix.entries)
+}
+
+// Get reads the last record written for key.
+func (ix *ProofIndex) Get(key UrkelHash) (*ProofRecord, error) {
+	i := sort.Search(len(ix.entries), func(i int) bool {
+		return bytes.Compare(ix.entries[i].key[:], key[:]) > 0
+	})
+
+	if i == 0 || ix.entries[i-1].key != key {
+		return nil, ErrRecordNotFound
+	}
+
+	offset := ix.entries[i-1].offset
+	rec, _, err := readRecord(io.NewSectionReader(ix.r, offset, maxRecordSize+8), ix.header)
+
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec.Key = key
+	rec.Offset = offset
+
+	return rec, nil
+}
@@ -0,0 +1,308 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type streamItem struct {
+	root  UrkelHash
+	key   UrkelHash
+	proof *Proof
+}
+
+func streamItems(t *testing.T) []streamItem {
+	t.Helper()
+
+	var items []streamItem
+
+	for i, proof := range fixtureProofs(t) {
+		var root, key UrkelHash
+
+		root[0] = byte(i)
+		key[0] = byte(len(items) * 7)
+		key[1] = byte(i)
+
+		items = append(items, streamItem{root, key, proof})
+	}
+
+	return items
+}
+
+func writeStream(t *testing.T, items []streamItem, header bool) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := NewProofWriter(&buf, header)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if err := w.Write(item.root, item.key, item.proof); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write(UrkelHash{}, UrkelHash{}, items[0].proof); err != ErrStreamClosed {
+		t.Fatalf("expected ErrStreamClosed, got %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProofStream(t *testing.T) {
+	items := streamItems(t)
+
+	for _, header := range []bool{false, true} {
+		data := writeStream(t, items, header)
+		r, err := NewProofReader(bytes.NewReader(data))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		i := 0
+
+		for rec, err := range r.All() {
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item := items[i]
+
+			if !bytes.Equal(serialized(t, rec.Proof), serialized(t, item.proof)) {
+				t.Fatalf("record %d: proof mismatch", i)
+			}
+
+			if header && (rec.Root != item.root || rec.Key != item.key) {
+				t.Fatalf("record %d: header mismatch", i)
+			}
+
+			if !header && (rec.Root != UrkelHash{} || rec.Key != UrkelHash{}) {
+				t.Fatalf("record %d: unexpected header", i)
+			}
+
+			i++
+		}
+
+		if i != len(items) {
+			t.Fatalf("read %d records, expected %d", i, len(items))
+		}
+
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("expected EOF, got %v", err)
+		}
+
+		index, err := OpenProofIndex(bytes.NewReader(data), int64(len(data)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if index.Len() != len(items) {
+			t.Fatalf("index has %d entries, expected %d", index.Len(), len(items))
+		}
+
+		// Look up in reverse to seek backwards as well.
+		for j := len(items) - 1; j >= 0; j-- {
+			item := items[j]
+			rec, err := index.Get(item.key)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Key != item.key || !bytes.Equal(serialized(t, rec.Proof), serialized(t, item.proof)) {
+				t.Fatalf("lookup %d: record mismatch", j)
+			}
+
+			if header && rec.Root != item.root {
+				t.Fatalf("lookup %d: root mismatch", j)
+			}
+		}
+
+		var missing UrkelHash
+		missing[31] = 1
+
+		if _, err := index.Get(missing); err != ErrRecordNotFound {
+			t.Fatalf("expected ErrRecordNotFound, got %v", err)
+		}
+	}
+}
+
+func TestProofStreamDuplicateKeys(t *testing.T) {
+	items := streamItems(t)[:3]
+	items[2].key = items[0].key
+
+	data := writeStream(t, items, false)
+	index, err := OpenProofIndex(bytes.NewReader(data), int64(len(data)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := index.Get(items[0].key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(serialized(t, rec.Proof), serialized(t, items[2].proof)) {
+		t.Fatal("expected the last record for the key")
+	}
+}
+
+func TestProofStreamUnclosed(t *testing.T) {
+	items := streamItems(t)[:4]
+
+	var buf bytes.Buffer
+
+	w, err := NewProofWriter(&buf, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if err := w.Write(item.root, item.key, item.proof); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := buf.Bytes()
+	r, err := NewProofReader(bytes.NewReader(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+
+	for _, err := range r.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count++
+	}
+
+	if count != len(items) {
+		t.Fatalf("read %d records, expected %d", count, len(items))
+	}
+
+	if _, err := OpenProofIndex(bytes.NewReader(data), int64(len(data))); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex, got %v", err)
+	}
+
+	// A torn record is an error, not the end of the stream.
+	r, _ = NewProofReader(bytes.NewReader(data[:len(data)-3]))
+	var last error
+
+	for _, err := range r.All() {
+		last = err
+	}
+
+	if last != io.ErrUnexpectedEOF {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", last)
+	}
+}
+
+// failWriter accepts n bytes and then fails.
+type failWriter struct {
+	n int
+}
+
+var errFailWriter = errors.New("write failed")
+
+func (w *failWriter) Write(data []byte) (int, error) {
+	if len(data) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errFailWriter
+	}
+
+	w.n -= len(data)
+
+	return len(data), nil
+}
+
+func TestProofStreamWriteError(t *testing.T) {
+	items := streamItems(t)[:2]
+	fw := &failWriter{n: streamHeaderSize + 10}
+
+	w, err := NewProofWriter(fw, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write(items[0].root, items[0].key, items[0].proof); err != errFailWriter {
+		t.Fatalf("expected errFailWriter, got %v", err)
+	}
+
+	if len(w.index) != 0 {
+		t.Fatal("failed record was indexed")
+	}
+
+	// The writer stays broken even if the underlying writer recovers.
+	fw.n = 1 << 20
+
+	if err := w.Write(items[1].root, items[1].key, items[1].proof); err != errFailWriter {
+		t.Fatalf("expected errFailWriter, got %v", err)
+	}
+
+	if err := w.Close(); err != errFailWriter {
+		t.Fatalf("expected errFailWriter, got %v", err)
+	}
+
+	if len(w.index) != 0 || fw.n != 1<<20 {
+		t.Fatal("broken writer kept writing")
+	}
+}
+
+func TestProofStreamCorruption(t *testing.T) {
+	items := streamItems(t)[:4]
+	data := writeStream(t, items, true)
+
+	// Flip a byte of the first record's proof.
+	bad := append([]byte(nil), data...)
+	bad[streamHeaderSize+4+2*UrkelHashSize] ^= 0x01
+
+	r, _ := NewProofReader(bytes.NewReader(bad))
+
+	if _, err := r.Next(); err != ErrStreamChecksum {
+		t.Fatalf("expected ErrStreamChecksum, got %v", err)
+	}
+
+	// Flip a byte of the index.
+	bad = append([]byte(nil), data...)
+	bad[len(bad)-indexFooterSize-5] ^= 0x01
+
+	if _, err := OpenProofIndex(bytes.NewReader(bad), int64(len(bad))); err != ErrStreamChecksum {
+		t.Fatalf("expected ErrStreamChecksum, got %v", err)
+	}
+
+	bad = append([]byte(nil), data...)
+	bad[0] ^= 0x01
+
+	if _, err := NewProofReader(bytes.NewReader(bad)); err != ErrStreamMagic {
+		t.Fatalf("expected ErrStreamMagic, got %v", err)
+	}
+
+	if _, err := OpenProofIndex(bytes.NewReader(bad), int64(len(bad))); err != ErrStreamMagic {
+		t.Fatalf("expected ErrStreamMagic, got %v", err)
+	}
+
+	if _, err := NewProofReader(bytes.NewReader(nil)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
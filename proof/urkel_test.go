@@ -0,0 +1,38 @@
+package proof_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+// The external tests check proofs against trees built by urkel, which
+// imports proof and so cannot be used from the proof package itself.
+
+func randomItems(seed int64, count int) ([]proof.UrkelHash, [][]byte) {
+	rng := rand.New(rand.NewSource(seed))
+	keys := make([]proof.UrkelHash, count)
+	values := make([][]byte, count)
+
+	for i := range keys {
+		rng.Read(keys[i][:])
+		values[i] = make([]byte, 1+rng.Intn(64))
+		rng.Read(values[i])
+	}
+
+	return keys, values
+}
+
+func buildTree(t *testing.T, keys []proof.UrkelHash, values [][]byte) *urkel.Tree {
+	tree := urkel.New()
+
+	for i, key := range keys {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tree
+}
@@ -37,6 +37,22 @@ func readUint32(r io.Reader) (uint32, error) {
 	return n, err
 }
 
+func writeUint64(w io.Writer, n uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	return writeBytesFull(w, buf[:])
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+	var buf [8]byte
+
+	if err := readBytesFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
 func writeUint16(w io.Writer, n uint16) error {
 	var buf [2]byte
 	binary.LittleEndian.PutUint16(buf[:], n)
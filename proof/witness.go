@@ -0,0 +1,282 @@
+package proof
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotCovered  = errors.New("key not covered by witness")
+	ErrRootChanged = errors.New("proof does not match witness")
+)
+
+type witnessKind int
+
+const (
+	witnessHash witnessKind = iota
+	witnessEmpty
+	witnessInternal
+	witnessLeaf
+)
+
+// witnessNode is a node of the partial tree. Subtrees no proof descended
+// into are kept as witnessHash nodes with only their hash.
+type witnessNode struct {
+	kind witnessKind
+	hash UrkelHash
+
+	prefix Bits
+	left   *witnessNode
+	right  *witnessNode
+
+	key   UrkelHash
+	vhash UrkelHash
+	value []byte
+}
+
+func (n *witnessNode) child(bit int) *witnessNode {
+	if bit == 0 {
+		return n.left
+	}
+
+	return n.right
+}
+
+// Witness is a partial tree for one root, merged from verified proofs.
+// Nodes shared by several proofs are stored once.
+type Witness struct {
+	root   *witnessNode
+	hasher Hasher
+}
+
+// NewWitness returns an empty witness for root.
+func NewWitness(root UrkelHash) *Witness {
+	return NewWitnessWith(DefaultHasher(), root)
+}
+
+// NewWitnessWith returns an empty witness for root of a tree hashed with
+// hasher. Added proofs are verified with it.
+func NewWitnessWith(hasher Hasher, root UrkelHash) *Witness {
+	return &Witness{
+		root:   &witnessNode{kind: witnessHash, hash: root},
+		hasher: hasher,
+	}
+}
+
+// Root returns the root the witness is for.
+func (w *Witness) Root() UrkelHash {
+	return w.root.hash
+}
+
+// Add verifies the proof for key against the witness root and merges it
+// into the witness.
+func (w *Witness) Add(key UrkelHash, p *Proof) error {
+	if code, _ := p.VerifyWith(w.hasher, w.root.hash, key); code != ProofOk {
+		return &VerifyError{Code: code}
+	}
+
+	path, err := witnessPath(w.hasher, key, p)
+
+	if err != nil {
+		return err
+	}
+
+	root, err := mergeWitness(w.root, path)
+
+	if err != nil {
+		return err
+	}
+
+	w.root = root
+
+	return nil
+}
+
+// Get returns the value for key. It returns nil if the key is proven
+// absent and ErrNotCovered if no added proof covers it.
+func (w *Witness) Get(key UrkelHash) ([]byte, error) {
+	n := w.root
+	depth := 0
+
+	for {
+		switch n.kind {
+		case witnessEmpty:
+			return nil, nil
+		case witnessInternal:
+			if !n.prefix.Has(key, depth) {
+				return nil, nil
+			}
+
+			depth += n.prefix.size
+			n = n.child(getBit(key[:], depth))
+			depth++
+		case witnessLeaf:
+			if n.key != key {
+				return nil, nil
+			}
+
+			if n.value == nil {
+				return nil, ErrNotCovered
+			}
+
+			return n.value, nil
+		default:
+			return nil, ErrNotCovered
+		}
+	}
+}
+
+// Prove creates a proof for key from the witness.
+func (w *Witness) Prove(key UrkelHash) (*Proof, error) {
+	var nodes []*ProofNode
+	var result *Proof
+	var err error
+
+	n := w.root
+	depth := 0
+
+	for result == nil {
+		switch n.kind {
+		case witnessEmpty:
+			result = NewDeadEnd(depth)
+		case witnessInternal:
+			if !n.prefix.Has(key, depth) {
+				result = NewShort(depth, n.prefix, n.left.hash, n.right.hash)
+				break
+			}
+
+			depth += n.prefix.size
+			bit := getBit(key[:], depth)
+
+			nodes = append(nodes, NewProofNode(n.prefix, n.child(bit^1).hash))
+
+			n = n.child(bit)
+			depth++
+		case witnessLeaf:
+			if n.key != key {
+				result = NewCollision(depth, n.key, n.vhash)
+				break
+			}
+
+			if n.value == nil {
+				return nil, ErrNotCovered
+			}
+
+			if result, err = NewExists(depth, n.value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, ErrNotCovered
+		}
+	}
+
+	result.nodes = nodes
+
+	return result, nil
+}
+
+// witnessPath turns a proof into a single path of witness nodes.
+func witnessPath(hasher Hasher, key UrkelHash, p *Proof) (*witnessNode, error) {
+	var next *witnessNode
+
+	switch p.ptype {
+	case ProofTypeDeadEnd:
+		next = &witnessNode{kind: witnessEmpty}
+	case ProofTypeShort:
+		next = &witnessNode{
+			kind:   witnessInternal,
+			prefix: p.prefix,
+			left:   &witnessNode{kind: witnessHash, hash: p.left},
+			right:  &witnessNode{kind: witnessHash, hash: p.right},
+		}
+	case ProofTypeCollision:
+		next = &witnessNode{kind: witnessLeaf, key: p.key, vhash: p.hash}
+	case ProofTypeExists:
+		vhash, err := hashData(hasher, p.Value())
+
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, p.valueSize)
+		copy(value, p.Value())
+
+		next = &witnessNode{kind: witnessLeaf, key: key, vhash: vhash, value: value}
+	default:
+		return nil, errors.New("invalid proof type")
+	}
+
+	hash, err := p.leafHash(hasher, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	next.hash = hash
+	depth := p.depth
+
+	for i := len(p.nodes) - 1; i >= 0; i-- {
+		node := p.nodes[i]
+		sibling := &witnessNode{kind: witnessHash, hash: node.hash}
+		parent := &witnessNode{kind: witnessInternal, prefix: node.prefix}
+
+		if hasBit(key[:], depth-1) {
+			parent.left = sibling
+			parent.right = next
+		} else {
+			parent.left = next
+			parent.right = sibling
+		}
+
+		if parent.hash, depth, err = foldNode(hasher, key, next.hash, node, depth); err != nil {
+			return nil, err
+		}
+
+		next = parent
+	}
+
+	return next, nil
+}
+
+// mergeWitness merges two nodes with the same hash, keeping whatever
+// either of them knows about the subtree.
+func mergeWitness(a *witnessNode, b *witnessNode) (*witnessNode, error) {
+	if a.hash != b.hash {
+		return nil, ErrRootChanged
+	}
+
+	if a.kind == witnessHash {
+		return b, nil
+	}
+
+	if b.kind == witnessHash {
+		return a, nil
+	}
+
+	if a.kind != b.kind {
+		return nil, ErrRootChanged
+	}
+
+	switch a.kind {
+	case witnessInternal:
+		left, err := mergeWitness(a.left, b.left)
+
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := mergeWitness(a.right, b.right)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.left = left
+		a.right = right
+	case witnessLeaf:
+		if a.value == nil {
+			a.value = b.value
+		}
+	}
+
+	return a, nil
+}
@@ -0,0 +1,156 @@
+package proof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+func serializeProof(t *testing.T, p *proof.Proof) []byte {
+	var buf bytes.Buffer
+
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWitness(t *testing.T) {
+	keys, values := randomItems(11, 300)
+	tree := buildTree(t, keys[:200], values[:200])
+	witness := proof.NewWitness(tree.RootHash())
+
+	// Every other key is covered, half of them absent from the tree.
+	for i := 100; i < 300; i += 2 {
+		p, err := tree.Prove(keys[i])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := witness.Add(keys[i], p); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+
+	for i := 100; i < 300; i += 2 {
+		value, err := witness.Get(keys[i])
+
+		if err != nil {
+			t.Fatalf("get %d: %v", i, err)
+		}
+
+		if i < 200 && !bytes.Equal(value, values[i]) {
+			t.Fatalf("get %d: value mismatch", i)
+		}
+
+		if i >= 200 && value != nil {
+			t.Fatalf("get %d: expected absent", i)
+		}
+
+		expect, err := tree.Prove(keys[i])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := witness.Prove(keys[i])
+
+		if err != nil {
+			t.Fatalf("prove %d: %v", i, err)
+		}
+
+		if !bytes.Equal(serializeProof(t, got), serializeProof(t, expect)) {
+			t.Fatalf("prove %d: proof mismatch", i)
+		}
+	}
+
+	if _, err := witness.Get(keys[101]); err != proof.ErrNotCovered {
+		t.Fatalf("expected ErrNotCovered, got %v", err)
+	}
+
+	if _, err := witness.Prove(keys[101]); err != proof.ErrNotCovered {
+		t.Fatalf("expected ErrNotCovered, got %v", err)
+	}
+}
+
+func TestWitnessWrongRoot(t *testing.T) {
+	keys, values := randomItems(12, 20)
+	tree := buildTree(t, keys[:10], values[:10])
+	other := buildTree(t, keys[10:], values[10:])
+	witness := proof.NewWitness(tree.RootHash())
+
+	p, err := other.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := witness.Add(keys[0], p); err == nil {
+		t.Fatal("expected error for proof against another root")
+	}
+}
+
+func TestWitnessEmptyTree(t *testing.T) {
+	keys, _ := randomItems(13, 2)
+	tree := urkel.New()
+	witness := proof.NewWitness(tree.RootHash())
+
+	p, err := tree.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := witness.Add(keys[0], p); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dead end at the root covers every key.
+	value, err := witness.Get(keys[1])
+
+	if err != nil || value != nil {
+		t.Fatalf("expected absent, got %v %v", value, err)
+	}
+}
+
+func TestWitnessWithHasher(t *testing.T) {
+	keys, values := randomItems(18, 40)
+	tree := urkel.NewWithHasher(proof.SHA256)
+
+	for i, key := range keys[:20] {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	witness := proof.NewWitnessWith(proof.SHA256, tree.RootHash())
+	blake := proof.NewWitness(tree.RootHash())
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := witness.Add(key, p); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+
+		if err := blake.Add(key, p); err == nil {
+			t.Fatalf("add %d: default hasher accepted a SHA-256 proof", i)
+		}
+	}
+
+	for i, key := range keys[:20] {
+		value, err := witness.Get(key)
+
+		if err != nil || !bytes.Equal(value, values[i]) {
+			t.Fatalf("get %d: value mismatch %v", i, err)
+		}
+	}
+}
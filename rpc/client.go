@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+
+	// Username is sent with the API key, hsd ignores it.
+	Username = "x"
+
+	maxResponseSize = 64 << 20
+)
+
+// Client talks to the node HTTP server of hsd, both JSON-RPC and REST.
+type Client struct {
+	// URL is the base URL of the node, e.g. http://127.0.0.1:12037.
+	URL    string
+	APIKey string
+
+	HTTPClient *http.Client
+
+	id uint64
+}
+
+var ErrNotFound = errors.New("not found")
+
+// RPCError is an error returned by the node for a JSON-RPC call.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// HTTPError is returned for non-2xx REST responses.
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error %d: %s", e.Status, e.Message)
+}
+
+type rpcRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     uint64        `json:"id"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+	ID     uint64          `json:"id"`
+}
+
+func NewClient(url string, apiKey string) *Client {
+	return &Client{
+		URL:        strings.TrimRight(url, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Call executes a JSON-RPC method and decodes its result into result. A
+// nil result discards it.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	req := rpcRequest{
+		Method: method,
+		Params: params,
+		ID:     atomic.AddUint64(&c.id, 1),
+	}
+
+	body, err := json.Marshal(req)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := c.do(ctx, http.MethodPost, "/", body)
+
+	// hsd may answer RPC errors with a non-2xx status, those still carry
+	// the request id.
+	var httpErr *HTTPError
+
+	if err != nil && !errors.As(err, &httpErr) {
+		return err
+	}
+
+	var res rpcResponse
+
+	if jerr := json.Unmarshal(data, &res); jerr != nil || res.ID != req.ID {
+		if err != nil {
+			return err
+		}
+
+		if jerr != nil {
+			return jerr
+		}
+
+		return errors.New("rpc id mismatch")
+	}
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// Get requests a REST endpoint and decodes the JSON response into result.
+// Missing resources are reported as ErrNotFound.
+func (c *Client) Get(ctx context.Context, path string, result interface{}) error {
+	data, err := c.do(ctx, http.MethodGet, path, nil)
+
+	var httpErr *HTTPError
+
+	if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+// Post sends body as JSON to a REST endpoint and decodes the response.
+func (c *Client) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	payload, err := json.Marshal(body)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := c.do(ctx, http.MethodPost, path, payload)
+
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.APIKey != "" {
+		req.SetBasicAuth(Username, c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return data, &HTTPError{
+			Status:  res.StatusCode,
+			Message: errorMessage(data, res.Status),
+		}
+	}
+
+	return data, nil
+}
+
+// errorMessage extracts the message of an hsd error body.
+func errorMessage(data []byte, fallback string) string {
+	var body struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if json.Unmarshal(data, &body) == nil && body.Error != nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+
+	return fallback
+}
@@ -0,0 +1,300 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+const testAPIKey = "secret"
+
+const proofJSON = `{
+	"type": "TYPE_EXISTS",
+	"depth": 7,
+	"nodes": [
+		["", "b38bd3162de23fd4242511f3d96518b41fb5ceaba1b2604718e9ff4905decc96"],
+		["", "a725d93e5424af84461ac485543bd9fc84875ef8b9eccdaec714cecc03cea73b"],
+		["", "ee019110c7f43813690f676307494281bb4d30e9b96334dc561ea506413827fb"],
+		["1", "d8fcc02cfdf8f1ab87ddddf80d1ab24e2bbc73b8ccf2e48e0e5b45b0a68b2ae2"],
+		["", "cee75163e06169984dff42cbce4ce516eeda81135f4f2b41899df4a532bb7184"],
+		["", "09daecd329756ed5e608f784903e4ec0570d8f30a1c9cfd7c6c3b8d997c47a57"]
+	],
+	"value": "68656c6c6f2032"
+}`
+
+const (
+	testRoot = "4ec83628341d5b97a6259e069dc5d83af77446301fafcb393772e3e90829ec41"
+	testKey  = "76726046e2db690179b6be0e81348068b15c1323f5d02ed39b24842fb1947262"
+)
+
+func testBlock() *wire.Block {
+	return &wire.Block{
+		Header: wire.BlockHeader{Nonce: 7, Time: 1580745078, Bits: 0x1c00ffff},
+		TXs: []*wire.TX{{
+			Inputs:  []*wire.Input{{Sequence: 0xffffffff, Witness: [][]byte{{0x01}}}},
+			Outputs: []*wire.Output{{Value: 2000, Address: wire.Address{Hash: make([]byte, 20)}}},
+		}},
+	}
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	block := testBlock()
+
+	var rawBlock, rawHeader bytes.Buffer
+
+	block.Serialize(&rawBlock)
+	block.Header.Serialize(&rawHeader)
+
+	results := map[string]string{
+		"getblockchaininfo": `{"chain":"main","blocks":100,"headers":100,"bestblockhash":"` + block.Hash().String() + `","treeRoot":"` + testRoot + `","difficulty":1,"mediantime":1580745078,"verificationprogress":1,"chainwork":"00ff","pruned":false}`,
+		"getnameinfo":       `{"start":{"reserved":false,"week":20,"start":3024},"info":{"name":"handshake","nameHash":"` + testKey + `","state":"CLOSED","height":10,"renewal":10,"owner":{"hash":"` + testRoot + `","index":1},"value":1000,"highest":2000,"data":"0006","transfer":0,"revoked":0,"claimed":0,"renewals":0,"registered":true,"expired":false,"weak":false,"stats":{"renewalPeriodStart":10}}}`,
+		"getnameresource":   `{"records":[{"type":"NS","ns":"ns1.handshake."}]}`,
+		"getnameproof":      `{"hash":"` + block.Hash().String() + `","height":100,"root":"` + testRoot + `","name":"handshake","key":"` + testKey + `","proof":` + proofJSON + `}`,
+		"getblockheader":    `"` + hex.EncodeToString(rawHeader.Bytes()) + `"`,
+		"getblock":          `"` + hex.EncodeToString(rawBlock.Bytes()) + `"`,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, ok := r.BasicAuth(); !ok || pass != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"type":"Error","message":"Unauthorized."}}`))
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"version":"6.0.0","network":"main","chain":{"height":100,"tip":"` + block.Hash().String() + `","treeRoot":"` + testRoot + `","progress":1}}`))
+			return
+		}
+
+		var req rpcRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Bad rpc request: %s", err)
+			return
+		}
+
+		var res string
+
+		switch req.Method {
+		case "sendrawtransaction":
+			var tx wire.TX
+			raw, _ := hex.DecodeString(req.Params[0].(string))
+
+			if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+				t.Errorf("Bad transaction: %s", err)
+			}
+
+			res = `{"result":"` + tx.Hash().String() + `","error":null,"id":%d}`
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			res = `{"result":null,"error":null,"id":%d}`
+		default:
+			result, ok := results[req.Method]
+
+			if !ok {
+				res = `{"result":null,"error":{"message":"Method not found.","code":-32601},"id":%d}`
+				break
+			}
+
+			res = `{"result":` + result + `,"error":null,"id":%d}`
+		}
+
+		w.Write([]byte(fmtID(res, req.ID)))
+	})
+
+	mux.HandleFunc("/header/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/header/100" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"Error","message":"Not found."}}`))
+			return
+		}
+
+		w.Write([]byte(`{"hash":"` + block.Hash().String() + `","height":100,"bits":469827583,"nonce":7,"time":1580745078}`))
+	})
+
+	mux.HandleFunc("/broadcast", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			TX string `json:"tx"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"success":` + map[bool]string{true: "true", false: "false"}[body.TX != ""] + `}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func fmtID(res string, id uint64) string {
+	b, _ := json.Marshal(id)
+	return string(bytes.Replace([]byte(res), []byte("%d"), b, 1))
+}
+
+func TestClientRPC(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, testAPIKey)
+	ctx := context.Background()
+	block := testBlock()
+
+	info, err := client.GetBlockchainInfo(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Chain != "main" || info.Blocks != 100 || info.TreeRoot != testRoot {
+		t.Errorf("Unexpected blockchain info: %+v", info)
+	}
+
+	nameInfo, err := client.GetNameInfo(ctx, "handshake")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nameInfo.Info.Name != "handshake" || nameInfo.Info.Owner.Index != 1 || !nameInfo.Info.Registered {
+		t.Errorf("Unexpected name info: %+v", nameInfo.Info)
+	}
+
+	resource, err := client.GetNameResource(ctx, "handshake")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resource.Records) != 1 {
+		t.Errorf("Expected one record, got %d", len(resource.Records))
+	}
+
+	nameProof, err := client.GetNameProof(ctx, "handshake", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root, key proof.UrkelHash
+
+	hex.Decode(root[:], []byte(nameProof.Root))
+	hex.Decode(key[:], []byte(nameProof.Key))
+
+	code, value := nameProof.Proof.Verify(root, key)
+
+	if code != proof.ProofOk || string(value) != "hello 2" {
+		t.Errorf("Proof verification failed: %d %q", code, value)
+	}
+
+	header, err := client.GetBlockHeader(ctx, block.Hash())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Hash() != block.Hash() {
+		t.Errorf("Header hash mismatch")
+	}
+
+	gotBlock, err := client.GetBlock(ctx, block.Hash())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gotBlock.TXs) != 1 || gotBlock.TXs[0].Hash() != block.TXs[0].Hash() {
+		t.Errorf("Block mismatch")
+	}
+
+	txid, err := client.SendRawTransaction(ctx, block.TXs[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if txid != block.TXs[0].Hash() {
+		t.Errorf("TXID mismatch: %s", txid)
+	}
+
+	var rpcErr *RPCError
+
+	if err = client.Call(ctx, "unknown", nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("Expected method not found, got %v", err)
+	}
+}
+
+func TestClientREST(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", testAPIKey)
+	ctx := context.Background()
+
+	info, err := client.GetInfo(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Network != "main" || info.Chain.Height != 100 {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+
+	header, err := client.GetHeader(ctx, "100")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Height != 100 || header.Bits != 0x1c00ffff {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+
+	if _, err = client.GetHeader(ctx, "101"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err = client.Broadcast(ctx, testBlock().TXs[0]); err != nil {
+		t.Errorf("Broadcast failed: %s", err)
+	}
+}
+
+func TestClientAuthAndTimeout(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, "wrong")
+
+	var httpErr *HTTPError
+
+	if _, err := client.GetBlockchainInfo(context.Background()); !errors.As(err, &httpErr) || httpErr.Status != 401 {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+
+	if httpErr != nil && httpErr.Message != "Unauthorized." {
+		t.Errorf("Unexpected error message: %q", httpErr.Message)
+	}
+
+	client = NewClient(server.URL, testAPIKey)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.Call(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	client.HTTPClient.Timeout = 50 * time.Millisecond
+
+	if err := client.Call(context.Background(), "slow", nil); err == nil {
+		t.Errorf("Expected client timeout")
+	}
+}
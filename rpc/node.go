@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/url"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/wire"
+)
+
+func (c *Client) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	var info BlockchainInfo
+
+	if err := c.Call(ctx, "getblockchaininfo", &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (c *Client) GetNameInfo(ctx context.Context, name string) (*NameInfo, error) {
+	var info NameInfo
+
+	if err := c.Call(ctx, "getnameinfo", &info, name); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetNameResource returns the resource of name, or nil if it has none.
+func (c *Client) GetNameResource(ctx context.Context, name string) (*Resource, error) {
+	var resource *Resource
+
+	if err := c.Call(ctx, "getnameresource", &resource, name); err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// GetNameProof returns a proof for name at root, or at the latest root if
+// root is nil.
+func (c *Client) GetNameProof(ctx context.Context, name string, root *proof.UrkelHash) (*proof.NameProof, error) {
+	var result proof.NameProof
+	params := []interface{}{name}
+
+	if root != nil {
+		params = append(params, hex.EncodeToString(root[:]))
+	}
+
+	if err := c.Call(ctx, "getnameproof", &result, params...); err != nil {
+		return nil, err
+	}
+
+	if result.Proof == nil {
+		return nil, proof.ErrMissingProof
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetBlockHeader(ctx context.Context, hash wire.Hash) (*wire.BlockHeader, error) {
+	raw, err := c.callHex(ctx, "getblockheader", hash.String(), false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return wire.NewBlockHeaderFromBytes(raw)
+}
+
+func (c *Client) GetBlock(ctx context.Context, hash wire.Hash) (*wire.Block, error) {
+	raw, err := c.callHex(ctx, "getblock", hash.String(), false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block := &wire.Block{}
+
+	if err = block.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// SendRawTransaction broadcasts tx and returns its hash.
+func (c *Client) SendRawTransaction(ctx context.Context, tx *wire.TX) (wire.Hash, error) {
+	var buf bytes.Buffer
+	var txid string
+
+	if err := tx.Serialize(&buf); err != nil {
+		return wire.Hash{}, err
+	}
+
+	if err := c.Call(ctx, "sendrawtransaction", &txid, hex.EncodeToString(buf.Bytes())); err != nil {
+		return wire.Hash{}, err
+	}
+
+	return wire.NewHashFromString(txid)
+}
+
+func (c *Client) callHex(ctx context.Context, method string, params ...interface{}) ([]byte, error) {
+	var result string
+
+	if err := c.Call(ctx, method, &result, params...); err != nil {
+		return nil, err
+	}
+
+	return hex.DecodeString(result)
+}
+
+// GetInfo returns the node summary from the REST API.
+func (c *Client) GetInfo(ctx context.Context) (*Info, error) {
+	var info Info
+
+	if err := c.Get(ctx, "/", &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetHeader returns a chain entry from the REST API by hash or height.
+func (c *Client) GetHeader(ctx context.Context, block string) (*HeaderEntry, error) {
+	var header *HeaderEntry
+
+	if err := c.Get(ctx, "/header/"+url.PathEscape(block), &header); err != nil {
+		return nil, err
+	}
+
+	if header == nil {
+		return nil, ErrNotFound
+	}
+
+	return header, nil
+}
+
+// Broadcast submits tx through the REST API.
+func (c *Client) Broadcast(ctx context.Context, tx *wire.TX) error {
+	var buf bytes.Buffer
+
+	if err := tx.Serialize(&buf); err != nil {
+		return err
+	}
+
+	var res struct {
+		Success bool `json:"success"`
+	}
+
+	body := map[string]string{"tx": hex.EncodeToString(buf.Bytes())}
+
+	if err := c.Post(ctx, "/broadcast", body, &res); err != nil {
+		return err
+	}
+
+	if !res.Success {
+		return errors.New("broadcast failed")
+	}
+
+	return nil
+}
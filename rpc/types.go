@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"encoding/json"
+)
+
+type BlockchainInfo struct {
+	Chain                string  `json:"chain"`
+	Blocks               uint32  `json:"blocks"`
+	Headers              uint32  `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	TreeRoot             string  `json:"treeRoot"`
+	Difficulty           float64 `json:"difficulty"`
+	MedianTime           int64   `json:"mediantime"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	ChainWork            string  `json:"chainwork"`
+	Pruned               bool    `json:"pruned"`
+}
+
+type NameStart struct {
+	Reserved bool   `json:"reserved"`
+	Week     int    `json:"week"`
+	Start    uint32 `json:"start"`
+}
+
+type Outpoint struct {
+	Hash  string `json:"hash"`
+	Index uint32 `json:"index"`
+}
+
+// NameState is the JSON form of a name's state as returned by the node.
+type NameState struct {
+	Name       string                     `json:"name"`
+	NameHash   string                     `json:"nameHash"`
+	State      string                     `json:"state"`
+	Height     uint32                     `json:"height"`
+	Renewal    uint32                     `json:"renewal"`
+	Owner      Outpoint                   `json:"owner"`
+	Value      uint64                     `json:"value"`
+	Highest    uint64                     `json:"highest"`
+	Data       string                     `json:"data"`
+	Transfer   uint32                     `json:"transfer"`
+	Revoked    uint32                     `json:"revoked"`
+	Claimed    uint32                     `json:"claimed"`
+	Renewals   uint32                     `json:"renewals"`
+	Registered bool                       `json:"registered"`
+	Expired    bool                       `json:"expired"`
+	Weak       bool                       `json:"weak"`
+	Stats      map[string]json.RawMessage `json:"stats,omitempty"`
+}
+
+type NameInfo struct {
+	Start NameStart  `json:"start"`
+	Info  *NameState `json:"info"`
+}
+
+// Resource holds the DNS records of a name. Records are kept in their JSON
+// form as their shape depends on the record type.
+type Resource struct {
+	Records []json.RawMessage `json:"records"`
+}
+
+// Info is the REST node summary returned by GET /.
+type Info struct {
+	Version string `json:"version"`
+	Network string `json:"network"`
+	Chain   struct {
+		Height   uint32  `json:"height"`
+		Tip      string  `json:"tip"`
+		TreeRoot string  `json:"treeRoot"`
+		Progress float64 `json:"progress"`
+	} `json:"chain"`
+}
+
+// HeaderEntry is a chain entry as returned by the REST API.
+type HeaderEntry struct {
+	Hash         string `json:"hash"`
+	Version      uint32 `json:"version"`
+	PrevBlock    string `json:"prevBlock"`
+	MerkleRoot   string `json:"merkleRoot"`
+	WitnessRoot  string `json:"witnessRoot"`
+	TreeRoot     string `json:"treeRoot"`
+	ReservedRoot string `json:"reservedRoot"`
+	Time         uint64 `json:"time"`
+	Bits         uint32 `json:"bits"`
+	Nonce        uint32 `json:"nonce"`
+	ExtraNonce   string `json:"extraNonce"`
+	Mask         string `json:"mask"`
+	Height       uint32 `json:"height"`
+	ChainWork    string `json:"chainwork"`
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+const (
+	formatAuto   = "auto"
+	formatHex    = "hex"
+	formatRaw    = "raw"
+	formatJSON   = "json"
+	formatBase64 = "base64"
+)
+
+// request is a decoded proof together with the root, key and name to
+// verify it against. Empty strings are missing values.
+type request struct {
+	proof *proof.Proof
+	root  string
+	key   string
+	name  string
+}
+
+// requestJSON is the body of a JSON request. A getnameproof result has the
+// same shape.
+type requestJSON struct {
+	Proof  json.RawMessage `json:"proof"`
+	Format string          `json:"format"`
+	Root   string          `json:"root"`
+	Key    string          `json:"key"`
+	Name   string          `json:"name"`
+}
+
+// readRequest decodes a request body. JSON bodies are either a proof or a
+// requestJSON; any other body is the proof itself, in the format given by
+// the format query parameter. Without one, text/plain bodies are sniffed
+// for hex, base64 or JSON and other bodies are raw bytes. Query parameters
+// override root, key and name of the body.
+func readRequest(r *http.Request, body []byte) (*request, error) {
+	query := r.URL.Query()
+	format := query.Get("format")
+
+	if format == "" {
+		format = formatAuto
+	}
+
+	var req *request
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		req, err = decodeRequestJSON(body, format)
+	} else {
+		var p *proof.Proof
+
+		// Binary bodies are never sniffed: a raw proof may well start
+		// with '{' or whitespace.
+		if format == formatAuto && mediaType != "text/plain" {
+			format = formatRaw
+		}
+
+		if p, err = decodeProof(body, format); err == nil {
+			req = &request{proof: p}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoders only check the wire format; verifying an insane proof
+	// (a negative depth, say) would index out of range.
+	if !req.proof.IsSane() {
+		return nil, errors.New("proof is not sane")
+	}
+
+	for _, q := range []struct {
+		name  string
+		value *string
+	}{
+		{"root", &req.root},
+		{"key", &req.key},
+		{"name", &req.name},
+	} {
+		if v := query.Get(q.name); v != "" {
+			*q.value = v
+		}
+	}
+
+	return req, nil
+}
+
+func decodeRequestJSON(body []byte, format string) (*request, error) {
+	var probe map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, err
+	}
+
+	if _, ok := probe["proof"]; !ok {
+		p, err := proof.NewFromJSON(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &request{proof: p}, nil
+	}
+
+	var in requestJSON
+
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, err
+	}
+
+	if in.Format != "" {
+		format = in.Format
+	}
+
+	var data []byte
+	var text string
+
+	// A string holds an encoded proof, anything else is the proof's JSON.
+	if err := json.Unmarshal(in.Proof, &text); err == nil {
+		data = []byte(text)
+	} else {
+		data = in.Proof
+		format = formatJSON
+	}
+
+	p, err := decodeProof(data, format)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &request{
+		proof: p,
+		root:  in.Root,
+		key:   in.Key,
+		name:  in.Name,
+	}, nil
+}
+
+// detectFormat guesses the format of a proof sent as text, falling back
+// to raw bytes.
+func detectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return formatJSON
+	}
+
+	if isHex(trimmed) {
+		return formatHex
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && len(trimmed) > 0 {
+		return formatBase64
+	}
+
+	return formatRaw
+}
+
+func isHex(data []byte) bool {
+	if len(data) == 0 || len(data)%2 != 0 {
+		return false
+	}
+
+	for _, ch := range data {
+		switch {
+		case ch >= '0' && ch <= '9':
+		case ch >= 'a' && ch <= 'f':
+		case ch >= 'A' && ch <= 'F':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeProof(data []byte, format string) (*proof.Proof, error) {
+	if format == formatAuto {
+		format = detectFormat(data)
+	}
+
+	text := strings.TrimSpace(string(data))
+
+	switch format {
+	case formatHex:
+		raw, err := hex.DecodeString(text)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return proof.NewFromBytes(raw)
+	case formatBase64:
+		raw, err := base64.StdEncoding.DecodeString(text)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return proof.NewFromBytes(raw)
+	case formatRaw:
+		return proof.NewFromBytes(data)
+	case formatJSON:
+		return proof.NewFromJSON(data)
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func parseHash(s string) (proof.UrkelHash, error) {
+	var hash proof.UrkelHash
+
+	raw, err := hex.DecodeString(s)
+
+	if err != nil {
+		return hash, err
+	}
+
+	if len(raw) != proof.UrkelHashSize {
+		return hash, errors.New("invalid hash length")
+	}
+
+	copy(hash[:], raw)
+
+	return hash, nil
+}
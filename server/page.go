@@ -0,0 +1,64 @@
+package server
+
+// indexPage posts the form to /verify or /decode and shows the response.
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Proof explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+label { display: block; margin-top: 1em; }
+textarea, input { width: 100%; font-family: monospace; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>Proof explorer</h1>
+<form id="form">
+<label>Proof (hex, base64, JSON or a getnameproof result)
+<textarea name="proof" rows="10"></textarea></label>
+<label>Root <input name="root"></label>
+<label>Key <input name="key"></label>
+<label>Name <input name="name"></label>
+<p>
+<button type="submit" value="verify">Verify</button>
+<button type="submit" value="decode">Decode</button>
+</p>
+</form>
+<pre id="out"></pre>
+<script>
+document.getElementById("form").addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  const form = new FormData(ev.target);
+  const body = {};
+
+  for (const [k, v] of form.entries()) {
+    if (v.trim() !== "")
+      body[k] = v.trim();
+  }
+
+  // Pasted JSON is sent as JSON, so getnameproof results keep their fields.
+  if (body.proof && body.proof.startsWith("{")) {
+    try {
+      const json = JSON.parse(body.proof);
+      body.proof = json.proof || json;
+      for (const k of ["root", "key", "name"]) {
+        if (!body[k] && typeof json[k] === "string")
+          body[k] = json[k];
+      }
+    } catch (e) {}
+  }
+
+  const res = await fetch(ev.submitter.value, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify(body)
+  });
+
+  document.getElementById("out").textContent = await res.text();
+});
+</script>
+</body>
+</html>
+`
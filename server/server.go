@@ -0,0 +1,377 @@
+// Package server is an HTTP service for inspecting urkel proofs of names.
+// It verifies proofs posted as raw bytes, hex, base64 or JSON and reports
+// the result, the decoded name state and DNS records, and every hash
+// computed on the way to the root.
+//
+// Endpoints:
+//
+//	GET  /        a form to paste proofs into
+//	POST /decode  decode a proof
+//	POST /verify  verify a proof against a root and a key or name
+//
+// A JSON body (Content-Type application/json) is either a proof's JSON or
+// an object with the proof as an encoded string or JSON, and optional
+// format, root, key and name fields. A getnameproof result can be posted
+// as it is. Any other body is the proof itself. The format, root, key and
+// name query parameters apply to both and override the body.
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// DefaultMaxBodySize is well above the largest encoded proof.
+const DefaultMaxBodySize = 1 << 20
+
+// Options configures New. Zero fields use the defaults.
+type Options struct {
+	Hasher      proof.Hasher
+	MaxBodySize int64
+}
+
+// Server is an http.Handler serving the proof explorer.
+type Server struct {
+	hasher      proof.Hasher
+	maxBodySize int64
+	mux         *http.ServeMux
+}
+
+// ProofInfo describes a decoded proof. Size and Hex are empty for proofs
+// that cannot be serialized.
+type ProofInfo struct {
+	Type  string       `json:"type"`
+	Depth int          `json:"depth"`
+	Nodes int          `json:"nodes"`
+	Size  int          `json:"size,omitempty"`
+	Hex   string       `json:"hex,omitempty"`
+	JSON  *proof.Proof `json:"json"`
+}
+
+// Outpoint is proof.Outpoint in hsd's JSON form.
+type Outpoint struct {
+	Hash  string `json:"hash"`
+	Index uint32 `json:"index"`
+}
+
+// NameState is proof.NameState in the form of hsd's getnameinfo.
+type NameState struct {
+	Name       string   `json:"name"`
+	NameHash   string   `json:"nameHash"`
+	Height     uint32   `json:"height"`
+	Renewal    uint32   `json:"renewal"`
+	Owner      Outpoint `json:"owner"`
+	Value      uint64   `json:"value"`
+	Highest    uint64   `json:"highest"`
+	Data       string   `json:"data"`
+	Transfer   uint32   `json:"transfer"`
+	Revoked    uint32   `json:"revoked"`
+	Claimed    uint32   `json:"claimed"`
+	Renewals   uint32   `json:"renewals"`
+	Registered bool     `json:"registered"`
+	Expired    bool     `json:"expired"`
+	Weak       bool     `json:"weak"`
+}
+
+// DecodeResponse is the result of /decode. Value, NameState and Resource
+// are only set for TYPE_EXISTS proofs; ValueError explains why the value
+// or its resource did not decode.
+type DecodeResponse struct {
+	Proof      ProofInfo       `json:"proof"`
+	Value      string          `json:"value,omitempty"`
+	NameState  *NameState      `json:"nameState,omitempty"`
+	Resource   *proof.Resource `json:"resource,omitempty"`
+	ValueError string          `json:"valueError,omitempty"`
+}
+
+// Step is a proof.TraceStep with hex hashes. Left and Right are empty
+// for leaves without children.
+type Step struct {
+	Node   int    `json:"node"`
+	Depth  int    `json:"depth"`
+	Prefix string `json:"prefix"`
+	Left   string `json:"left,omitempty"`
+	Right  string `json:"right,omitempty"`
+	Hash   string `json:"hash"`
+}
+
+// VerifyResponse is the result of /verify. Values of TYPE_EXISTS proofs
+// are checked with proof.ValidateNameState; Error holds the reason for
+// PROOF_BAD_VALUE.
+type VerifyResponse struct {
+	DecodeResponse
+
+	Root  string `json:"root"`
+	Key   string `json:"key"`
+	Name  string `json:"name,omitempty"`
+	Code  string `json:"code"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Trace []Step `json:"trace"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(opts *Options) *Server {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	s := &Server{
+		hasher:      opts.Hasher,
+		maxBodySize: opts.MaxBodySize,
+		mux:         http.NewServeMux(),
+	}
+
+	if s.hasher == nil {
+		s.hasher = proof.DefaultHasher()
+	}
+
+	if s.maxBodySize <= 0 {
+		s.maxBodySize = DefaultMaxBodySize
+	}
+
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("POST /decode", s.handleDecode)
+	s.mux.HandleFunc("POST /verify", s.handleVerify)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, indexPage)
+}
+
+func (s *Server) handleDecode(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decode(req.proof))
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	if req.root == "" || (req.key == "" && req.name == "") {
+		writeError(w, http.StatusBadRequest, errors.New("verify needs a root and a key or name"))
+		return
+	}
+
+	root, err := parseHash(req.root)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid root: %w", err))
+		return
+	}
+
+	key, err := requestKey(req)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	p := req.proof
+	res := &VerifyResponse{
+		DecodeResponse: decode(p),
+		Root:           hex.EncodeToString(root[:]),
+		Key:            hex.EncodeToString(key[:]),
+		Name:           req.name,
+		Trace:          []Step{},
+	}
+
+	opts := &proof.VerifyOptions{
+		Hasher:        s.hasher,
+		ValidateValue: proof.ValidateNameState,
+		Trace: func(step proof.TraceStep) {
+			res.Trace = append(res.Trace, newStep(p, step))
+		},
+	}
+
+	code, _, err := p.VerifyWithOptions(root, key, opts)
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	res.Code = code.String()
+	res.OK = code == proof.ProofOk
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) readRequest(w http.ResponseWriter, r *http.Request) (*request, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+		} else {
+			writeError(w, http.StatusBadRequest, err)
+		}
+
+		return nil, false
+	}
+
+	req, err := readRequest(r, body)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid proof: %w", err))
+		return nil, false
+	}
+
+	return req, true
+}
+
+// requestKey returns the key of the request, derived from the name if
+// there is one. A request with both must have a name that hashes to key.
+func requestKey(req *request) (proof.UrkelHash, error) {
+	var key proof.UrkelHash
+	var err error
+
+	if req.key != "" {
+		if key, err = parseHash(req.key); err != nil {
+			return key, fmt.Errorf("invalid key: %w", err)
+		}
+	}
+
+	if req.name == "" {
+		return key, nil
+	}
+
+	nameHash, err := proof.HashName(req.name)
+
+	if err != nil {
+		return key, err
+	}
+
+	if req.key != "" && nameHash != key {
+		return key, proof.ErrNameMismatch
+	}
+
+	return nameHash, nil
+}
+
+func decode(p *proof.Proof) DecodeResponse {
+	res := DecodeResponse{
+		Proof: ProofInfo{
+			Type:  p.Type().String(),
+			Depth: p.Depth(),
+			Nodes: len(p.Nodes()),
+			JSON:  p,
+		},
+	}
+
+	var raw bytes.Buffer
+
+	if p.IsSane() && p.Serialize(&raw) == nil {
+		res.Proof.Size = raw.Len()
+		res.Proof.Hex = hex.EncodeToString(raw.Bytes())
+	}
+
+	if p.Type() != proof.ProofTypeExists {
+		return res
+	}
+
+	value := p.Value()
+	res.Value = hex.EncodeToString(value)
+
+	ns, err := proof.NewNameStateFromBytes(value)
+
+	if err != nil {
+		res.ValueError = fmt.Sprintf("invalid name state: %s", err)
+		return res
+	}
+
+	res.NameState = newNameState(ns)
+
+	if len(ns.Data) == 0 {
+		return res
+	}
+
+	if res.Resource, err = proof.NewResourceFromBytes(ns.Data); err != nil {
+		res.ValueError = err.Error()
+	}
+
+	return res
+}
+
+func newNameState(ns *proof.NameState) *NameState {
+	out := &NameState{
+		Name:    ns.Name,
+		Height:  ns.Height,
+		Renewal: ns.Renewal,
+		Owner: Outpoint{
+			Hash:  hex.EncodeToString(ns.Owner.Hash[:]),
+			Index: ns.Owner.Index,
+		},
+		Value:      ns.Value,
+		Highest:    ns.Highest,
+		Data:       hex.EncodeToString(ns.Data),
+		Transfer:   ns.Transfer,
+		Revoked:    ns.Revoked,
+		Claimed:    ns.Claimed,
+		Renewals:   ns.Renewals,
+		Registered: ns.Registered,
+		Expired:    ns.Expired,
+		Weak:       ns.Weak,
+	}
+
+	if nameHash, err := proof.HashName(ns.Name); err == nil {
+		out.NameHash = hex.EncodeToString(nameHash[:])
+	}
+
+	return out
+}
+
+func newStep(p *proof.Proof, step proof.TraceStep) Step {
+	out := Step{
+		Node:   step.Node,
+		Depth:  step.Depth,
+		Prefix: step.Prefix.String(),
+		Hash:   hex.EncodeToString(step.Hash[:]),
+	}
+
+	if step.Node >= 0 || p.Type() == proof.ProofTypeShort {
+		out.Left = hex.EncodeToString(step.Left[:])
+		out.Right = hex.EncodeToString(step.Right[:])
+	}
+
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &errorResponse{err.Error()})
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"github.com/nodech/go-hsd-utils/urkel"
+)
+
+type testTree struct {
+	root  proof.UrkelHash
+	key   proof.UrkelHash
+	proof *proof.Proof
+	raw   []byte
+}
+
+// newTestTree proves "handshake", which has an NS record, in a tree with
+// a few other names.
+func newTestTree(t *testing.T) *testTree {
+	t.Helper()
+
+	tree := urkel.New()
+
+	// Version 0, NS ns1.handshake.
+	resource, _ := hex.DecodeString("0001036e73310968616e647368616b6500")
+
+	for _, name := range []string{"handshake", "example", "bitcoin"} {
+		key, _ := proof.HashName(name)
+		ns := &proof.NameState{Name: name, Height: 100, Registered: true}
+
+		if name == "handshake" {
+			ns.Data = resource
+		}
+
+		var value bytes.Buffer
+
+		if err := ns.Serialize(&value); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tree.Insert(key, value.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key, _ := proof.HashName("handshake")
+	p, err := tree.Prove(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw bytes.Buffer
+
+	if err := p.Serialize(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testTree{
+		root:  tree.RootHash(),
+		key:   key,
+		proof: p,
+		raw:   raw.Bytes(),
+	}
+}
+
+func post(t *testing.T, s *Server, target, contentType string, body []byte, status int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != status {
+		t.Fatalf("%s: expected status %d, got %d: %s", target, status, rec.Code, rec.Body)
+	}
+
+	return rec
+}
+
+func postVerify(t *testing.T, s *Server, target, contentType string, body []byte) *VerifyResponse {
+	t.Helper()
+
+	rec := post(t, s, target, contentType, body, http.StatusOK)
+	res := &VerifyResponse{}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	tt := newTestTree(t)
+	s := New(nil)
+	root := hex.EncodeToString(tt.root[:])
+
+	body := marshal(t, map[string]string{
+		"proof": hex.EncodeToString(tt.raw),
+		"root":  root,
+		"name":  "handshake",
+	})
+
+	res := postVerify(t, s, "/verify", "application/json", body)
+
+	if res.Code != "PROOF_OK" || !res.OK {
+		t.Fatalf("expected PROOF_OK, got %s (%s)", res.Code, res.Error)
+	}
+
+	if res.Key != hex.EncodeToString(tt.key[:]) {
+		t.Fatalf("key mismatch: %s", res.Key)
+	}
+
+	if res.Proof.Type != "TYPE_EXISTS" || res.Proof.Hex != hex.EncodeToString(tt.raw) {
+		t.Fatalf("proof mismatch: %+v", res.Proof)
+	}
+
+	if res.NameState == nil || res.NameState.Name != "handshake" || res.NameState.Height != 100 {
+		t.Fatalf("name state mismatch: %+v", res.NameState)
+	}
+
+	if res.NameState.NameHash != res.Key {
+		t.Fatalf("name hash mismatch: %s", res.NameState.NameHash)
+	}
+
+	records := res.Resource.Records
+
+	if len(records) != 1 || records[0].Type != proof.RecordNS || records[0].NS != "ns1.handshake." {
+		t.Fatalf("resource mismatch: %+v", res.Resource)
+	}
+
+	if len(res.Trace) != len(tt.proof.Nodes())+1 {
+		t.Fatalf("expected %d trace steps, got %d", len(tt.proof.Nodes())+1, len(res.Trace))
+	}
+
+	if last := res.Trace[len(res.Trace)-1]; last.Hash != root || last.Depth != 0 {
+		t.Fatalf("trace does not end at the root: %+v", last)
+	}
+}
+
+func TestVerifyInputs(t *testing.T) {
+	tt := newTestTree(t)
+	s := New(nil)
+	root := hex.EncodeToString(tt.root[:])
+	key := hex.EncodeToString(tt.key[:])
+	proofJSON := marshal(t, tt.proof)
+
+	nameProof := marshal(t, &proof.NameProof{
+		Root:  root,
+		Name:  "handshake",
+		Key:   key,
+		Proof: tt.proof,
+	})
+
+	for _, tc := range []struct {
+		name        string
+		target      string
+		contentType string
+		body        []byte
+	}{
+		{"raw", "/verify?root=" + root + "&key=" + key, "application/octet-stream", tt.raw},
+		{"hex", "/verify?root=" + root + "&name=handshake", "text/plain", []byte(hex.EncodeToString(tt.raw) + "\n")},
+		{"json", "/verify?root=" + root + "&key=" + key, "application/json", proofJSON},
+		{"name-proof", "/verify", "application/json", nameProof},
+		{"format", "/verify?format=hex", "application/json", marshal(t, map[string]string{
+			"proof": hex.EncodeToString(tt.raw),
+			"root":  root,
+			"key":   key,
+		})},
+	} {
+		res := postVerify(t, s, tc.target, tc.contentType, tc.body)
+
+		if res.Code != "PROOF_OK" {
+			t.Fatalf("%s: expected PROOF_OK, got %s", tc.name, res.Code)
+		}
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	tt := newTestTree(t)
+	s := New(nil)
+	root := hex.EncodeToString(tt.root[:])
+
+	badRoot := tt.root
+	badRoot[0] ^= 1
+
+	res := postVerify(t, s, "/verify?name=handshake&root="+hex.EncodeToString(badRoot[:]), "text/plain", tt.raw)
+
+	if res.Code != "PROOF_HASH_MISMATCH" || res.OK {
+		t.Fatalf("expected PROOF_HASH_MISMATCH, got %s", res.Code)
+	}
+
+	// The proof is still decoded and traced.
+	if res.NameState == nil || len(res.Trace) != len(tt.proof.Nodes())+1 {
+		t.Fatal("failed proof was not decoded")
+	}
+
+	// The hash checks out, but the value is not a name state.
+	key, _ := proof.HashName("garbage")
+	tree := urkel.New()
+
+	if err := tree.Insert(key, []byte("not a name state")); err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := tree.Prove(key)
+	garbageRoot := tree.RootHash()
+
+	body := marshal(t, map[string]any{
+		"proof": p,
+		"root":  hex.EncodeToString(garbageRoot[:]),
+		"name":  "garbage",
+	})
+
+	res = postVerify(t, s, "/verify", "application/json", body)
+
+	if res.Code != "PROOF_BAD_VALUE" || res.Error == "" || res.ValueError == "" {
+		t.Fatalf("expected PROOF_BAD_VALUE, got %s", res.Code)
+	}
+
+	for _, target := range []string{
+		"/verify?key=" + hex.EncodeToString(tt.key[:]),
+		"/verify?root=" + root,
+		"/verify?root=00&name=handshake",
+		"/verify?root=" + root + "&key=00",
+		"/verify?root=" + root + "&name=bitcoin&key=" + hex.EncodeToString(tt.key[:]),
+		"/verify?root=" + root + "&name=Bad",
+	} {
+		post(t, s, target, "text/plain", tt.raw, http.StatusBadRequest)
+	}
+
+	post(t, s, "/verify?root="+root+"&name=handshake", "text/plain", []byte("zz"), http.StatusBadRequest)
+	post(t, s, "/verify?format=nope", "text/plain", tt.raw, http.StatusBadRequest)
+	post(t, s, "/verify", "application/json", []byte(`{"proof":`), http.StatusBadRequest)
+}
+
+func TestVerifyInsane(t *testing.T) {
+	tt := newTestTree(t)
+	s := New(nil)
+	target := "/verify?name=handshake&root=" + hex.EncodeToString(tt.root[:])
+
+	// The value size is past UrkelValueSize and the buffer behind it.
+	post(t, s, target, "text/plain", []byte("85c000002ab3ec01"), http.StatusBadRequest)
+	post(t, s, target, "application/octet-stream", []byte{0x85, 0xc0, 0x00, 0x00, 0x2a, 0xb3, 0xec, 0x01}, http.StatusBadRequest)
+
+	short := []byte(`{"type":"TYPE_SHORT","depth":-3,"nodes":[],` +
+		`"prefix":"0","left":"` + strings.Repeat("00", 32) + `","right":"` + strings.Repeat("00", 32) + `"}`)
+
+	post(t, s, target, "application/json", short, http.StatusBadRequest)
+	post(t, s, "/decode", "application/json", short, http.StatusBadRequest)
+}
+
+func TestDecode(t *testing.T) {
+	tt := newTestTree(t)
+	s := New(nil)
+
+	rec := post(t, s, "/decode", "application/json", marshal(t, tt.proof), http.StatusOK)
+
+	var res DecodeResponse
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Proof.Hex != hex.EncodeToString(tt.raw) || res.Proof.Size != len(tt.raw) {
+		t.Fatalf("proof mismatch: %+v", res.Proof)
+	}
+
+	if res.Proof.Nodes != len(tt.proof.Nodes()) || res.Proof.Depth != tt.proof.Depth() {
+		t.Fatalf("proof mismatch: %+v", res.Proof)
+	}
+
+	if res.Value != hex.EncodeToString(tt.proof.Value()) || res.NameState == nil || res.Resource == nil {
+		t.Fatal("value was not decoded")
+	}
+
+	rec = post(t, s, "/decode", "text/plain", []byte(hex.EncodeToString(tt.raw)), http.StatusOK)
+
+	if strings.Contains(rec.Body.String(), `"trace"`) {
+		t.Fatal("decode returned a trace")
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	s := New(nil)
+
+	// Dead ends at these depths serialize to a first byte of '{' or
+	// whitespace, which must not be sniffed or trimmed away.
+	for _, depth := range []int{123, 9, 10, 11, 12, 13, 32} {
+		var raw bytes.Buffer
+
+		if err := proof.NewDeadEnd(depth).Serialize(&raw); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, contentType := range []string{"application/octet-stream", "application/x-www-form-urlencoded", ""} {
+			rec := post(t, s, "/decode", contentType, raw.Bytes(), http.StatusOK)
+
+			var res DecodeResponse
+
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Proof.Type != "TYPE_DEADEND" || res.Proof.Depth != depth {
+				t.Fatalf("depth %d, %q: proof mismatch: %+v", depth, contentType, res.Proof)
+			}
+		}
+	}
+
+	// Encoded bodies need a format unless they are sent as text.
+	tt := newTestTree(t)
+	encoded := []byte(hex.EncodeToString(tt.raw))
+
+	post(t, s, "/decode", "application/octet-stream", encoded, http.StatusBadRequest)
+	post(t, s, "/decode?format=hex", "application/octet-stream", encoded, http.StatusOK)
+}
+
+func TestRoutes(t *testing.T) {
+	s := New(&Options{MaxBodySize: 16})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("index: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/verify", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+
+	post(t, s, "/decode", "text/plain", make([]byte, 17), http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,130 @@
+package urkel
+
+import (
+	"errors"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+var (
+	ErrBatchClosed = errors.New("batch already committed or aborted")
+	ErrConflict    = errors.New("tree changed since batch was created")
+)
+
+// Batch collects changes on top of the tree root it was created from.
+// Nothing is visible to the tree until Commit. A batch is not safe for
+// concurrent use. A batch of a stored tree keeps the data files it reads
+// from open until it is committed or aborted.
+type Batch struct {
+	tree   *Tree
+	gen    uint64
+	base   node
+	root   node
+	epoch  *epoch
+	closed bool
+}
+
+// Batch starts a batch on the current root.
+func (t *Tree) Batch() *Batch {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return &Batch{
+		tree:  t,
+		gen:   t.gen,
+		base:  t.root,
+		root:  t.root,
+		epoch: t.hold(),
+	}
+}
+
+// RootHash returns the root hash including the batch's changes.
+func (b *Batch) RootHash() proof.UrkelHash {
+	return hashOf(b.root)
+}
+
+// Get returns the value for key including the batch's changes.
+func (b *Batch) Get(key proof.UrkelHash) ([]byte, error) {
+	if b.closed {
+		return nil, ErrBatchClosed
+	}
+
+	return get(b.root, key)
+}
+
+func (b *Batch) Insert(key proof.UrkelHash, value []byte) error {
+	if b.closed {
+		return ErrBatchClosed
+	}
+
+	if len(value) > proof.UrkelValueSize {
+		return ErrValueSize
+	}
+
+	root, err := insert(b.tree.hasher, b.root, key, value, 0)
+
+	if err != nil {
+		return err
+	}
+
+	b.root = root
+
+	return nil
+}
+
+// Remove deletes key. Removing a missing key is a no-op.
+func (b *Batch) Remove(key proof.UrkelHash) error {
+	if b.closed {
+		return ErrBatchClosed
+	}
+
+	root, err := remove(b.tree.hasher, b.root, key, 0)
+
+	if err == ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	b.root = root
+
+	return nil
+}
+
+// Commit makes the batch's root the tree root and returns its hash. It
+// fails with ErrConflict if the tree root changed or the tree was
+// compacted since the batch was created.
+func (b *Batch) Commit() (proof.UrkelHash, error) {
+	if b.closed {
+		return proof.UrkelHash{}, ErrBatchClosed
+	}
+
+	b.tree.mu.Lock()
+	defer b.tree.mu.Unlock()
+
+	if b.tree.gen != b.gen || hashOf(b.tree.root) != hashOf(b.base) {
+		return proof.UrkelHash{}, ErrConflict
+	}
+
+	if err := b.tree.setRoot(b.root); err != nil {
+		return proof.UrkelHash{}, err
+	}
+
+	b.closed = true
+	b.tree.release(b.epoch)
+
+	return hashOf(b.root), nil
+}
+
+// Abort discards the batch's changes.
+func (b *Batch) Abort() {
+	if !b.closed {
+		b.tree.release(b.epoch)
+	}
+
+	b.closed = true
+	b.root = nil
+	b.base = nil
+}
@@ -0,0 +1,172 @@
+package urkel
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+func TestBatchCommit(t *testing.T) {
+	keys, values := randomItems(25, 100)
+	tree := buildTree(t, keys[:50], values[:50])
+	base := tree.RootHash()
+	batch := tree.Batch()
+
+	for i := 50; i < 100; i++ {
+		if err := batch.Insert(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := batch.Remove(keys[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := batch.Get(keys[0]); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if tree.RootHash() != base {
+		t.Fatal("batch changed the tree before commit")
+	}
+
+	root, err := batch.Commit()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := buildTree(t, keys[1:], values[1:])
+
+	if root != expect.RootHash() || tree.RootHash() != root {
+		t.Fatal("root mismatch after commit")
+	}
+
+	if err := batch.Insert(keys[0], values[0]); err != ErrBatchClosed {
+		t.Fatalf("expected ErrBatchClosed, got %v", err)
+	}
+
+	if _, err := batch.Commit(); err != ErrBatchClosed {
+		t.Fatalf("expected ErrBatchClosed, got %v", err)
+	}
+}
+
+func TestBatchAbort(t *testing.T) {
+	keys, values := randomItems(26, 20)
+	tree := buildTree(t, keys[:10], values[:10])
+	base := tree.RootHash()
+	batch := tree.Batch()
+
+	for i := 10; i < 20; i++ {
+		if err := batch.Insert(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch.Abort()
+
+	if tree.RootHash() != base {
+		t.Fatal("aborted batch changed the tree")
+	}
+
+	if _, err := batch.Commit(); err != ErrBatchClosed {
+		t.Fatalf("expected ErrBatchClosed, got %v", err)
+	}
+}
+
+func TestBatchConflict(t *testing.T) {
+	keys, values := randomItems(27, 3)
+	tree := buildTree(t, keys[:1], values[:1])
+	batch := tree.Batch()
+
+	if err := batch.Insert(keys[1], values[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.Insert(keys[2], values[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := batch.Commit(); err != ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	keys, values := randomItems(28, 200)
+	tree := buildTree(t, keys[:100], values[:100])
+	old := tree.RootHash()
+
+	snap, err := tree.Snapshot(old)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	// Readers on the snapshot run alongside a writer.
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 100; i < 200; i++ {
+			if err := tree.Insert(keys[i], values[i]); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := tree.Remove(keys[i-100]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				value, err := snap.Get(keys[i])
+
+				if i < 100 && (err != nil || !bytes.Equal(value, values[i])) {
+					t.Errorf("key %d: value mismatch", i)
+					return
+				}
+
+				if i >= 100 && err != ErrNotFound {
+					t.Errorf("key %d: expected ErrNotFound, got %v", i, err)
+					return
+				}
+
+				p, err := snap.Prove(keys[i])
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if code, _ := p.Verify(old, keys[i]); code != proof.ProofOk {
+					t.Errorf("key %d: %s", i, code)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if snap.RootHash() != old {
+		t.Fatal("snapshot root changed")
+	}
+
+	if _, err := tree.Snapshot(keys[0]); err != ErrMissingRoot {
+		t.Fatalf("expected ErrMissingRoot, got %v", err)
+	}
+}
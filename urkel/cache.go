@@ -0,0 +1,96 @@
+package urkel
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCacheSize is the number of internal nodes a stored tree caches
+// unless told otherwise.
+const DefaultCacheSize = 1 << 16
+
+// CacheStats counts node reads served from the cache and from disk.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the share of reads served from the cache.
+func (c CacheStats) HitRate() float64 {
+	total := c.Hits + c.Misses
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(c.Hits) / float64(total)
+}
+
+type cacheEntry struct {
+	ptr  ptr
+	node *internalNode
+}
+
+// nodeCache is an LRU cache of decoded internal nodes keyed by their
+// location. Records are never rewritten in place, so an entry stays valid
+// as long as the file it points into exists.
+type nodeCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[ptr]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newNodeCache(size int) *nodeCache {
+	return &nodeCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[ptr]*list.Element),
+	}
+}
+
+func (c *nodeCache) get(p ptr) *internalNode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[p]
+
+	if !ok {
+		c.misses.Add(1)
+		return nil
+	}
+
+	c.hits.Add(1)
+	c.order.MoveToFront(el)
+
+	return el.Value.(*cacheEntry).node
+}
+
+func (c *nodeCache) put(p ptr, n *internalNode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[p]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[p] = c.order.PushFront(&cacheEntry{ptr: p, node: n})
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).ptr)
+	}
+}
+
+func (c *nodeCache) stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
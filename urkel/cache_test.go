@@ -0,0 +1,110 @@
+package urkel
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestNodeCacheEviction(t *testing.T) {
+	cache := newNodeCache(2)
+	a, b, c := &internalNode{}, &internalNode{}, &internalNode{}
+
+	cache.put(ptr{pos: 1}, a)
+	cache.put(ptr{pos: 2}, b)
+
+	// Touching a makes b the least recently used.
+	if cache.get(ptr{pos: 1}) != a {
+		t.Fatal("missing entry")
+	}
+
+	cache.put(ptr{pos: 3}, c)
+
+	if cache.get(ptr{pos: 2}) != nil {
+		t.Fatal("expected eviction")
+	}
+
+	if cache.get(ptr{pos: 1}) != a || cache.get(ptr{pos: 3}) != c {
+		t.Fatal("missing entry")
+	}
+
+	stats := cache.stats()
+
+	if stats.Hits != 3 || stats.Misses != 1 || stats.HitRate() != 0.75 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStoreCacheStats(t *testing.T) {
+	dir := t.TempDir()
+	points := buildStore(t, dir, 41)
+	last := points[len(points)-1]
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for round := 0; round < 2; round++ {
+		checkState(t, tree, last.state)
+	}
+
+	if rate := tree.CacheStats().HitRate(); rate < 0.5 {
+		t.Fatalf("hit rate %f too low", rate)
+	}
+
+	tree.Close()
+
+	if tree, err = Open(dir, &Options{CacheSize: -1}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	checkState(t, tree, last.state)
+
+	if tree.CacheStats() != (CacheStats{}) {
+		t.Fatal("disabled cache counted reads")
+	}
+}
+
+func TestStoreMmap(t *testing.T) {
+	dir := t.TempDir()
+	points := buildStore(t, dir, 42)
+	last := points[len(points)-1]
+
+	tree, err := Open(dir, &Options{Mmap: true, CacheSize: -1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	checkState(t, tree, last.state)
+
+	for _, point := range points[1:] {
+		checkSnapshot(t, tree, point)
+	}
+
+	if runtime.GOOS != "windows" && len(tree.store.maps) == 0 {
+		t.Fatal("no data file was mapped")
+	}
+
+	// Writes keep going to the current file, which is never mapped.
+	keys, values := randomItems(43, 1)
+
+	if err := tree.Insert(keys[0], values[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := tree.store.maps[tree.store.index]; ok {
+		t.Fatal("current file mapped")
+	}
+
+	got, err := tree.Get(keys[0])
+
+	if err != nil || string(got) != string(values[0]) {
+		t.Fatal("value mismatch after write")
+	}
+}
@@ -0,0 +1,264 @@
+package urkel
+
+import (
+	"os"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// Compact drops every root except the current one and keepRoots, along
+// with the nodes only they reference.
+//
+// For a stored tree the nodes reachable from the kept roots are copied
+// into new data files, ending with a meta record for the current root, and
+// the old files are removed once it is on disk. A crash before that point
+// leaves the old files as they were. Snapshots taken and batches created
+// before compacting keep reading the old files, which stay open until the
+// snapshots are closed and the batches aborted. Such batches fail to
+// commit with ErrConflict.
+//
+// Data file indexes are never reused. Once the last one, 65535, is in
+// use, Compact and commits that need a new file fail with ErrFileLimit.
+func (t *Tree) Compact(keepRoots []proof.UrkelHash) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keep := make(map[proof.UrkelHash]bool, len(keepRoots))
+
+	for _, hash := range keepRoots {
+		if hash != (proof.UrkelHash{}) && hash != hashOf(t.root) {
+			keep[hash] = true
+		}
+	}
+
+	if t.store == nil {
+		hashes := make([]proof.UrkelHash, 0, len(keep)+1)
+
+		for hash := range keep {
+			if _, ok := t.roots[hash]; !ok {
+				return ErrMissingRoot
+			}
+
+			hashes = append(hashes, hash)
+		}
+
+		if t.root != nil {
+			hashes = append(hashes, t.root.hash())
+		}
+
+		t.keepRoots(hashes)
+
+		return nil
+	}
+
+	roots, err := t.store.collectRoots(keep)
+
+	if err != nil {
+		return err
+	}
+
+	root, old, err := t.store.compact(append(roots, t.root))
+
+	if err != nil {
+		return err
+	}
+
+	t.root = root
+	t.gen++
+
+	return t.store.drop(old)
+}
+
+// collectRoots finds the kept roots in the meta chain, oldest first.
+func (s *store) collectRoots(keep map[proof.UrkelHash]bool) ([]node, error) {
+	var roots []node
+
+	found := make(map[proof.UrkelHash]bool, len(keep))
+	next := s.meta
+
+	for next != nil && len(found) < len(keep) {
+		meta, err := s.readMeta(*next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if meta.root != nil && keep[meta.rootHash] && !found[meta.rootHash] {
+			found[meta.rootHash] = true
+			roots = append(roots, s.rootNode(meta))
+		}
+
+		next = meta.prev
+	}
+
+	if len(found) < len(keep) {
+		return nil, ErrMissingRoot
+	}
+
+	for i, j := 0, len(roots)-1; i < j; i, j = i+1, j-1 {
+		roots[i], roots[j] = roots[j], roots[i]
+	}
+
+	return roots, nil
+}
+
+// compact copies roots into new data files and retires the epoch of the
+// old ones. The last root becomes the current one. It returns that root
+// to keep in memory and the retired epoch for drop.
+func (s *store) compact(roots []node) (node, *epoch, error) {
+	index, pos := s.index, s.pos
+	first := index + 1
+
+	if index == 0xffff {
+		return nil, nil, ErrFileLimit
+	}
+
+	w := &writer{s: s}
+
+	if err := s.openFile(first); err != nil {
+		return nil, nil, err
+	}
+
+	copied := make(map[ptr]ptr)
+	prev := (*ptr)(nil)
+
+	var meta *metaRecord
+	var err error
+
+	for i, root := range roots {
+		meta = &metaRecord{
+			history:  i < len(roots)-1,
+			prev:     prev,
+			rootHash: hashOf(root),
+		}
+
+		if root != nil {
+			p, cerr := w.copy(root, copied)
+
+			if cerr != nil {
+				err = cerr
+				break
+			}
+
+			meta.root = &p
+		}
+
+		mp, aerr := w.append(s.encodeMeta(meta))
+
+		if aerr != nil {
+			err = aerr
+			break
+		}
+
+		prev = &mp
+	}
+
+	if err == nil {
+		err = w.flush()
+	}
+
+	if err != nil {
+		w.rollback(index, pos)
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	s.meta = prev
+
+	old := s.epoch
+	old.retired = true
+	s.epoch = &epoch{}
+
+	for i := range s.files {
+		if i < first {
+			old.files = append(old.files, i)
+		}
+	}
+
+	s.mu.Unlock()
+
+	return s.rootNode(meta), old, nil
+}
+
+// drop removes the files of a retired epoch. They are unlinked right away
+// so a crash cannot leave them behind, but only closed, and their space
+// freed, once no reader holds the epoch.
+func (s *store) drop(old *epoch) error {
+	var err error
+
+	s.mu.Lock()
+
+	if old.refs == 0 {
+		err = s.closeFiles(old.files)
+	}
+
+	s.mu.Unlock()
+
+	for _, i := range old.files {
+		if rerr := os.Remove(filePath(s.dir, i)); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+
+	return err
+}
+
+// copy appends the subtree at n, reusing nodes copied before, and returns
+// its new location.
+func (w *writer) copy(n node, copied map[ptr]ptr) (ptr, error) {
+	old := ptrOf(n)
+
+	if old == nil {
+		return ptr{}, ErrCorruption
+	}
+
+	if p, ok := copied[*old]; ok {
+		return p, nil
+	}
+
+	nn, err := w.s.readNode(*old, n.hash())
+
+	if err != nil {
+		return ptr{}, err
+	}
+
+	// Nodes read from the store may be cached, so the copy with the new
+	// child locations is a new node.
+	if in, ok := nn.(*internalNode); ok {
+		cp := &internalNode{prefix: in.prefix, h: in.h}
+
+		if cp.left, err = w.copyChild(in.left, copied); err != nil {
+			return ptr{}, err
+		}
+
+		if cp.right, err = w.copyChild(in.right, copied); err != nil {
+			return ptr{}, err
+		}
+
+		nn = cp
+	}
+
+	p, err := w.appendNode(nn)
+
+	if err != nil {
+		return ptr{}, err
+	}
+
+	copied[*old] = p
+
+	return p, nil
+}
+
+func (w *writer) copyChild(n node, copied map[ptr]ptr) (node, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	p, err := w.copy(n, copied)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &hashNode{h: n.hash(), ptr: p, store: w.s}, nil
+}
@@ -0,0 +1,358 @@
+package urkel
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// storeSize adds up the data files the store holds open. Files unlinked
+// by compaction still take up space until they are closed, so they count.
+func storeSize(t *testing.T, tree *Tree) int64 {
+	t.Helper()
+
+	tree.store.mu.RLock()
+	defer tree.store.mu.RUnlock()
+
+	var size int64
+
+	for _, f := range tree.store.files {
+		info, err := f.Stat()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size += info.Size()
+	}
+
+	return size
+}
+
+// openFiles counts the open file descriptors of the process beyond the
+// store's own, or returns -1 where /proc is not available.
+func openFiles(tree *Tree) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+
+	if err != nil {
+		return -1
+	}
+
+	tree.store.mu.RLock()
+	defer tree.store.mu.RUnlock()
+
+	return len(entries) - len(tree.store.files)
+}
+
+func checkSnapshot(t *testing.T, tree *Tree, point commitPoint) {
+	t.Helper()
+
+	snap, err := tree.Snapshot(point.root)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range point.state {
+		got, err := snap.Get(key)
+
+		if err != nil || string(got) != string(value) {
+			t.Fatalf("snapshot value mismatch for %x", key)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	points := buildStore(t, dir, 34)
+	last := points[len(points)-1]
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree.store.maxFileSize = 4096
+	before := storeSize(t, tree)
+	fds := openFiles(tree)
+
+	// A snapshot of a dropped root taken before compacting.
+	old, err := tree.Snapshot(points[3].root)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := tree.Batch()
+
+	if err := tree.Compact([]proof.UrkelHash{points[10].root, points[20].root}); err != nil {
+		t.Fatal(err)
+	}
+
+	if storeSize(t, tree) <= before {
+		t.Fatal("old files closed while a snapshot holds them")
+	}
+
+	if tree.RootHash() != last.root {
+		t.Fatal("root changed")
+	}
+
+	checkState(t, tree, last.state)
+	checkSnapshot(t, tree, points[10])
+	checkSnapshot(t, tree, points[20])
+
+	if _, err := tree.Snapshot(points[3].root); err != ErrMissingRoot {
+		t.Fatalf("expected ErrMissingRoot, got %v", err)
+	}
+
+	for key, value := range points[3].state {
+		got, err := old.Get(key)
+
+		if err != nil || string(got) != string(value) {
+			t.Fatal("old snapshot broken by compaction")
+		}
+	}
+
+	if _, err := batch.Commit(); err != ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	batch.Abort()
+
+	if storeSize(t, tree) <= before {
+		t.Fatal("old files closed while a snapshot holds them")
+	}
+
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := old.Get(last.root); err != ErrSnapshotClosed {
+		t.Fatalf("expected ErrSnapshotClosed, got %v", err)
+	}
+
+	if storeSize(t, tree) >= before {
+		t.Fatal("compaction did not shrink the store")
+	}
+
+	if fds >= 0 && openFiles(tree) != fds {
+		t.Fatalf("leaked %d file descriptors", openFiles(tree)-fds)
+	}
+
+	keys, values := randomItems(35, 1)
+
+	if err := tree.Insert(keys[0], values[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	root := tree.RootHash()
+	state := map[proof.UrkelHash][]byte{keys[0]: values[0]}
+
+	for key, value := range last.state {
+		state[key] = value
+	}
+
+	tree.Close()
+
+	if tree, err = Open(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	if tree.RootHash() != root {
+		t.Fatal("root mismatch after reopen")
+	}
+
+	checkState(t, tree, state)
+	checkSnapshot(t, tree, points[10])
+	checkSnapshot(t, tree, points[20])
+}
+
+func TestCompactMissingRoot(t *testing.T) {
+	dir := t.TempDir()
+	buildStore(t, dir, 36)
+	rng := rand.New(rand.NewSource(37))
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	if err := tree.Compact([]proof.UrkelHash{randomKey(rng)}); err != ErrMissingRoot {
+		t.Fatalf("expected ErrMissingRoot, got %v", err)
+	}
+}
+
+func TestCompactFileLimit(t *testing.T) {
+	dir := t.TempDir()
+	points := buildStore(t, dir, 42)
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	index := tree.store.index
+	tree.store.index = 0xffff
+
+	if err := tree.Compact(nil); err != ErrFileLimit {
+		t.Fatalf("expected ErrFileLimit, got %v", err)
+	}
+
+	tree.store.index = index
+
+	if tree.RootHash() != points[len(points)-1].root {
+		t.Fatal("root changed")
+	}
+
+	checkState(t, tree, points[len(points)-1].state)
+}
+
+func TestCompactCrash(t *testing.T) {
+	src := t.TempDir()
+	points := buildStore(t, src, 38)
+	last := points[len(points)-1]
+	rng := rand.New(rand.NewSource(39))
+
+	// The state before compacting, and the files compaction adds.
+	pristine := copyDir(t, src)
+
+	tree, err := Open(src, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree.store.maxFileSize = 4096
+	first := tree.store.index + 1
+
+	if err := tree.Compact([]proof.UrkelHash{points[15].root}); err != nil {
+		t.Fatal(err)
+	}
+
+	end := tree.store.index
+	tree.Close()
+
+	for trial := 0; trial < 30; trial++ {
+		dir := copyDir(t, pristine)
+
+		// Crash while writing the compacted files: the old files are
+		// all there and the new ones are cut short.
+		index := first + uint16(rng.Intn(int(end-first)+1))
+
+		for i := first; i <= index; i++ {
+			data, err := os.ReadFile(filePath(src, i))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if i == index {
+				data = data[:rng.Intn(len(data)+1)]
+			}
+
+			if err := os.WriteFile(filePath(dir, i), data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tree, err := Open(dir, nil)
+
+		if err != nil {
+			t.Fatalf("trial %d: %v", trial, err)
+		}
+
+		if tree.RootHash() != last.root {
+			t.Fatalf("trial %d: recovered the wrong root", trial)
+		}
+
+		checkState(t, tree, last.state)
+		checkSnapshot(t, tree, points[15])
+
+		tree.Close()
+	}
+}
+
+func TestCompactMemory(t *testing.T) {
+	keys, values := randomItems(40, 10)
+	tree := New()
+	var roots []proof.UrkelHash
+
+	for i := range keys {
+		if err := tree.Insert(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		roots = append(roots, tree.RootHash())
+	}
+
+	if err := tree.Compact(roots[2:3]); err != nil {
+		t.Fatal(err)
+	}
+
+	if !tree.HasRoot(roots[2]) || !tree.HasRoot(roots[9]) {
+		t.Fatal("kept root dropped")
+	}
+
+	if tree.HasRoot(roots[5]) {
+		t.Fatal("root not dropped")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	keys, values := randomItems(41, 10)
+	tree := New()
+	var roots []proof.UrkelHash
+
+	for i := range keys {
+		if err := tree.Insert(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		roots = append(roots, tree.RootHash())
+	}
+
+	snap, err := tree.Snapshot(roots[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree.Prune(3)
+
+	for i, root := range roots {
+		if tree.HasRoot(root) != (i >= 7) {
+			t.Fatalf("%d: unexpected HasRoot %v", i, !(i >= 7))
+		}
+	}
+
+	if _, err := snap.Get(keys[0]); err != nil {
+		t.Fatalf("snapshot of a pruned root: %s", err)
+	}
+
+	tree.Prune(0)
+
+	if !tree.HasRoot(roots[9]) || tree.HasRoot(roots[8]) {
+		t.Fatal("Prune(0) must keep only the current root")
+	}
+
+	if err := tree.Insert(keys[0], values[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	tree.Prune(1)
+
+	if !tree.HasRoot(tree.RootHash()) || tree.HasRoot(roots[9]) {
+		t.Fatal("Prune(1) must keep only the newest root")
+	}
+}
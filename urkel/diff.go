@@ -0,0 +1,300 @@
+package urkel
+
+import (
+	"bytes"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+type ChangeType int
+
+const (
+	ChangeInsert ChangeType = iota
+	ChangeUpdate
+	ChangeRemove
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeRemove:
+		return "remove"
+	}
+
+	return "unknown"
+}
+
+// Change is a key that differs between two roots. Old is nil for inserted
+// keys and New is nil for removed keys.
+type Change struct {
+	Type ChangeType
+	Key  proof.UrkelHash
+	Old  []byte
+	New  []byte
+}
+
+// Diff returns the keys that changed going from one root to another, in
+// key order. Subtrees with the same hash in both roots are skipped.
+func (t *Tree) Diff(from, to proof.UrkelHash) ([]Change, error) {
+	t.mu.RLock()
+	a, err := t.getRoot(from)
+
+	if err != nil {
+		t.mu.RUnlock()
+		return nil, err
+	}
+
+	b, err := t.getRoot(to)
+
+	if err != nil {
+		t.mu.RUnlock()
+		return nil, err
+	}
+
+	e := t.hold()
+	t.mu.RUnlock()
+
+	defer t.release(e)
+
+	var changes []Change
+
+	if err := diff(cursor{n: a}, cursor{n: b}, &changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// cursor is a subtree viewed from inside an internal node's prefix: off
+// bits of the prefix have already been consumed.
+type cursor struct {
+	n   node
+	off int
+}
+
+// rest returns the number of prefix bits left before the node branches.
+func (c cursor) rest() int {
+	if nn, ok := c.n.(*internalNode); ok {
+		return nn.prefix.Size() - c.off
+	}
+
+	return 0
+}
+
+func (c cursor) bit() int {
+	nn := c.n.(*internalNode)
+	return nn.prefix.GetBit(c.off)
+}
+
+// diff compares two subtrees that start at the same depth.
+func diff(a, b cursor, changes *[]Change) error {
+	var err error
+
+	if a.off == b.off && hashOf(a.n) == hashOf(b.n) {
+		return nil
+	}
+
+	if a.n, err = resolve(a.n); err != nil {
+		return err
+	}
+
+	if b.n, err = resolve(b.n); err != nil {
+		return err
+	}
+
+	ai, aok := a.n.(*internalNode)
+	bi, bok := b.n.(*internalNode)
+
+	// Leaves and empty subtrees have no structure left to align, so
+	// their keys are compared directly.
+	if !aok || !bok {
+		left, err := leavesOf(a.n)
+
+		if err != nil {
+			return err
+		}
+
+		right, err := leavesOf(b.n)
+
+		if err != nil {
+			return err
+		}
+
+		diffLeaves(left, right, changes)
+
+		return nil
+	}
+
+	ar, br := a.rest(), b.rest()
+
+	switch {
+	case ar == 0 && br == 0:
+		if err := diff(cursor{n: ai.left}, cursor{n: bi.left}, changes); err != nil {
+			return err
+		}
+
+		return diff(cursor{n: ai.right}, cursor{n: bi.right}, changes)
+	case ar == 0:
+		// b lies entirely on one side of a's branch.
+		bit := b.bit()
+		b.off++
+
+		if bit == 1 {
+			if err := addAll(ai.left, ChangeRemove, changes); err != nil {
+				return err
+			}
+
+			return diff(cursor{n: ai.right}, b, changes)
+		}
+
+		if err := diff(cursor{n: ai.left}, b, changes); err != nil {
+			return err
+		}
+
+		return addAll(ai.right, ChangeRemove, changes)
+	case br == 0:
+		bit := a.bit()
+		a.off++
+
+		if bit == 1 {
+			if err := addAll(bi.left, ChangeInsert, changes); err != nil {
+				return err
+			}
+
+			return diff(a, cursor{n: bi.right}, changes)
+		}
+
+		if err := diff(a, cursor{n: bi.left}, changes); err != nil {
+			return err
+		}
+
+		return addAll(bi.right, ChangeInsert, changes)
+	case a.bit() == b.bit():
+		a.off++
+		b.off++
+
+		return diff(a, b, changes)
+	case a.bit() == 0:
+		// The prefixes diverge, so the key ranges do not overlap.
+		if err := addAll(a.n, ChangeRemove, changes); err != nil {
+			return err
+		}
+
+		return addAll(b.n, ChangeInsert, changes)
+	default:
+		if err := addAll(b.n, ChangeInsert, changes); err != nil {
+			return err
+		}
+
+		return addAll(a.n, ChangeRemove, changes)
+	}
+}
+
+func leavesOf(n node) ([]*leafNode, error) {
+	var leaves []*leafNode
+
+	var walk func(n node) error
+
+	walk = func(n node) error {
+		n, err := resolve(n)
+
+		if err != nil {
+			return err
+		}
+
+		switch nn := n.(type) {
+		case *internalNode:
+			if err := walk(nn.left); err != nil {
+				return err
+			}
+
+			return walk(nn.right)
+		case *leafNode:
+			leaves = append(leaves, nn)
+		}
+
+		return nil
+	}
+
+	if err := walk(n); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+func diffLeaves(a, b []*leafNode, changes *[]Change) {
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		cmp := 0
+
+		switch {
+		case i == len(a):
+			cmp = 1
+		case j == len(b):
+			cmp = -1
+		default:
+			cmp = bytes.Compare(a[i].key[:], b[j].key[:])
+		}
+
+		switch {
+		case cmp < 0:
+			*changes = append(*changes, removeChange(a[i]))
+			i++
+		case cmp > 0:
+			*changes = append(*changes, insertChange(b[j]))
+			j++
+		default:
+			if a[i].vhash != b[j].vhash {
+				*changes = append(*changes, Change{
+					Type: ChangeUpdate,
+					Key:  a[i].key,
+					Old:  append([]byte(nil), a[i].value...),
+					New:  append([]byte(nil), b[j].value...),
+				})
+			}
+
+			i++
+			j++
+		}
+	}
+}
+
+// addAll records every key under n as inserted or removed.
+func addAll(n node, typ ChangeType, changes *[]Change) error {
+	leaves, err := leavesOf(n)
+
+	if err != nil {
+		return err
+	}
+
+	for _, leaf := range leaves {
+		if typ == ChangeInsert {
+			*changes = append(*changes, insertChange(leaf))
+		} else {
+			*changes = append(*changes, removeChange(leaf))
+		}
+	}
+
+	return nil
+}
+
+func insertChange(leaf *leafNode) Change {
+	return Change{
+		Type: ChangeInsert,
+		Key:  leaf.key,
+		New:  append([]byte(nil), leaf.value...),
+	}
+}
+
+func removeChange(leaf *leafNode) Change {
+	return Change{
+		Type: ChangeRemove,
+		Key:  leaf.key,
+		Old:  append([]byte(nil), leaf.value...),
+	}
+}
@@ -0,0 +1,127 @@
+package urkel
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// expectDiff computes the changes between two key/value maps by brute
+// force.
+func expectDiff(a, b map[proof.UrkelHash][]byte) []Change {
+	var changes []Change
+	var keys []proof.UrkelHash
+
+	for key := range a {
+		keys = append(keys, key)
+	}
+
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range sortedKeys(keys) {
+		old, inA := a[key]
+		cur, inB := b[key]
+
+		switch {
+		case !inB:
+			changes = append(changes, Change{Type: ChangeRemove, Key: key, Old: old})
+		case !inA:
+			changes = append(changes, Change{Type: ChangeInsert, Key: key, New: cur})
+		case !bytes.Equal(old, cur):
+			changes = append(changes, Change{Type: ChangeUpdate, Key: key, Old: old, New: cur})
+		}
+	}
+
+	return changes
+}
+
+func checkDiff(t *testing.T, got, expect []Change) {
+	t.Helper()
+
+	if len(got) != len(expect) {
+		t.Fatalf("got %d changes, expected %d", len(got), len(expect))
+	}
+
+	for i := range got {
+		if got[i].Type != expect[i].Type || got[i].Key != expect[i].Key ||
+			!bytes.Equal(got[i].Old, expect[i].Old) || !bytes.Equal(got[i].New, expect[i].New) {
+			t.Fatalf("change %d: got %s %x, expected %s %x",
+				i, got[i].Type, got[i].Key, expect[i].Type, expect[i].Key)
+		}
+	}
+}
+
+func TestTreeDiff(t *testing.T) {
+	keys, values := randomItems(22, 400)
+	tree := New()
+	rng := rand.New(rand.NewSource(23))
+
+	state := make(map[proof.UrkelHash][]byte)
+	var roots []proof.UrkelHash
+	var states []map[proof.UrkelHash][]byte
+
+	snapshot := func() {
+		copied := make(map[proof.UrkelHash][]byte, len(state))
+
+		for key, value := range state {
+			copied[key] = value
+		}
+
+		roots = append(roots, tree.RootHash())
+		states = append(states, copied)
+	}
+
+	snapshot()
+
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 40; i++ {
+			key := keys[rng.Intn(len(keys))]
+
+			switch rng.Intn(3) {
+			case 0:
+				if err := tree.Remove(key); err != nil {
+					t.Fatal(err)
+				}
+
+				delete(state, key)
+			default:
+				value := values[rng.Intn(len(values))]
+
+				if err := tree.Insert(key, value); err != nil {
+					t.Fatal(err)
+				}
+
+				state[key] = value
+			}
+		}
+
+		snapshot()
+	}
+
+	for i := range roots {
+		for j := range roots {
+			changes, err := tree.Diff(roots[i], roots[j])
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkDiff(t, changes, expectDiff(states[i], states[j]))
+		}
+	}
+}
+
+func TestTreeDiffMissingRoot(t *testing.T) {
+	tree := New()
+	rng := rand.New(rand.NewSource(24))
+
+	if _, err := tree.Diff(proof.UrkelHash{}, randomKey(rng)); err != ErrMissingRoot {
+		t.Fatalf("expected ErrMissingRoot, got %v", err)
+	}
+}
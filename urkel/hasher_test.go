@@ -0,0 +1,116 @@
+package urkel
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+func checkHasherProofs(t *testing.T, tree *Tree, hasher proof.Hasher, keys []proof.UrkelHash, values [][]byte) {
+	t.Helper()
+
+	root := tree.RootHash()
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, value := p.VerifyWith(hasher, root, key)
+
+		if code != proof.ProofOk {
+			t.Fatalf("key %d: expected ok, got %s", i, code)
+		}
+
+		if !bytes.Equal(value, values[i]) {
+			t.Fatalf("key %d: value mismatch", i)
+		}
+
+		if code, _ := p.Verify(root, key); code != proof.ProofHashMismatch {
+			t.Fatalf("key %d: default hasher should not verify, got %s", i, code)
+		}
+	}
+}
+
+func TestTreeSHA256(t *testing.T) {
+	keys, values := randomItems(11, 200)
+
+	tree := NewWithHasher(proof.SHA256)
+	blake := New()
+
+	for i, key := range keys {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := blake.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if tree.RootHash() == blake.RootHash() {
+		t.Fatal("roots should differ between hashers")
+	}
+
+	checkHasherProofs(t, tree, proof.SHA256, keys, values)
+
+	for _, key := range keys[:100] {
+		if err := tree.Remove(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkHasherProofs(t, tree, proof.SHA256, keys[100:], values[100:])
+}
+
+func TestStoreSHA256(t *testing.T) {
+	dir := t.TempDir()
+	keys, values := randomItems(12, 100)
+	opts := &Options{Hasher: proof.SHA256}
+
+	tree, err := Open(dir, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range keys {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := tree.RootHash()
+
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree, err = Open(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	if tree.RootHash() != root {
+		t.Fatal("root mismatch after reopen")
+	}
+
+	checkHasherProofs(t, tree, proof.SHA256, keys, values)
+
+	// The same files read with the default hasher fail the hash check.
+	other, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer other.Close()
+
+	if _, err := other.Get(keys[0]); err != ErrCorruption {
+		t.Fatalf("expected corruption, got %v", err)
+	}
+}
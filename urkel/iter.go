@@ -0,0 +1,147 @@
+package urkel
+
+import (
+	"bytes"
+	"iter"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// Entry is a key and its value.
+type Entry struct {
+	Key   proof.UrkelHash
+	Value []byte
+}
+
+// All returns an iterator over every key and value of the tree in key
+// order. It iterates the root at the time iteration starts. If a node
+// cannot be read, the error is yielded and iteration stops.
+func (t *Tree) All() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		root, e := t.acquire()
+		defer t.release(e)
+
+		walkAll(root, yield)
+	}
+}
+
+// From returns an iterator over the keys greater than or equal to start,
+// in key order.
+func (t *Tree) From(start proof.UrkelHash) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		root, e := t.acquire()
+		defer t.release(e)
+
+		walkFrom(root, start, 0, yield)
+	}
+}
+
+// Prefix returns an iterator over the keys starting with prefix, in key
+// order.
+func (t *Tree) Prefix(prefix proof.Bits) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		root, e := t.acquire()
+		defer t.release(e)
+
+		walkPrefix(root, prefix, 0, yield)
+	}
+}
+
+func yieldLeaf(n *leafNode, yield func(Entry, error) bool) bool {
+	return yield(Entry{Key: n.key, Value: append([]byte(nil), n.value...)}, nil)
+}
+
+// walkAll visits every leaf under n and reports whether to continue.
+func walkAll(n node, yield func(Entry, error) bool) bool {
+	n, err := resolve(n)
+
+	if err != nil {
+		yield(Entry{}, err)
+		return false
+	}
+
+	switch nn := n.(type) {
+	case *internalNode:
+		return walkAll(nn.left, yield) && walkAll(nn.right, yield)
+	case *leafNode:
+		return yieldLeaf(nn, yield)
+	}
+
+	return true
+}
+
+func walkFrom(n node, start proof.UrkelHash, depth int, yield func(Entry, error) bool) bool {
+	n, err := resolve(n)
+
+	if err != nil {
+		yield(Entry{}, err)
+		return false
+	}
+
+	switch nn := n.(type) {
+	case *internalNode:
+		prefix := nn.prefix
+
+		// Where the prefix diverges from start decides whether the whole
+		// subtree sorts before or after it.
+		if count := prefix.Count(start, depth); count < prefix.Size() {
+			if prefix.GetBit(count) == 1 {
+				return walkAll(nn, yield)
+			}
+
+			return true
+		}
+
+		depth += prefix.Size()
+
+		if getBit(start, depth) == 1 {
+			return walkFrom(nn.right, start, depth+1, yield)
+		}
+
+		return walkFrom(nn.left, start, depth+1, yield) && walkAll(nn.right, yield)
+	case *leafNode:
+		if bytes.Compare(nn.key[:], start[:]) >= 0 {
+			return yieldLeaf(nn, yield)
+		}
+	}
+
+	return true
+}
+
+func walkPrefix(n node, prefix proof.Bits, depth int, yield func(Entry, error) bool) bool {
+	if depth >= prefix.Size() {
+		return walkAll(n, yield)
+	}
+
+	n, err := resolve(n)
+
+	if err != nil {
+		yield(Entry{}, err)
+		return false
+	}
+
+	switch nn := n.(type) {
+	case *internalNode:
+		size := nn.prefix.Size()
+
+		for i := 0; i < size && depth+i < prefix.Size(); i++ {
+			if nn.prefix.GetBit(i) != prefix.GetBit(depth+i) {
+				return true
+			}
+		}
+
+		depth += size
+
+		if depth >= prefix.Size() {
+			return walkAll(nn, yield)
+		}
+
+		return walkPrefix(nn.child(prefix.GetBit(depth)), prefix, depth+1, yield)
+	case *leafNode:
+		if prefix.Has(nn.key, 0) {
+			return yieldLeaf(nn, yield)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,148 @@
+package urkel
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+func sortedKeys(keys []proof.UrkelHash) []proof.UrkelHash {
+	sorted := append([]proof.UrkelHash(nil), keys...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	return sorted
+}
+
+func collect(t *testing.T, tree *Tree, seq func(func(Entry, error) bool)) []proof.UrkelHash {
+	var keys []proof.UrkelHash
+
+	for entry, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expect, err := tree.Get(entry.Key)
+
+		if err != nil || !bytes.Equal(entry.Value, expect) {
+			t.Fatalf("value mismatch for %x", entry.Key)
+		}
+
+		keys = append(keys, entry.Key)
+	}
+
+	return keys
+}
+
+func equalKeys(a, b []proof.UrkelHash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestTreeAll(t *testing.T) {
+	keys, values := randomItems(17, 500)
+	tree := buildTree(t, keys, values)
+
+	if !equalKeys(collect(t, tree, tree.All()), sortedKeys(keys)) {
+		t.Fatal("keys not in order")
+	}
+
+	count := 0
+
+	for range tree.All() {
+		count++
+
+		if count == 10 {
+			break
+		}
+	}
+
+	if count != 10 {
+		t.Fatal("early exit failed")
+	}
+
+	for range New().All() {
+		t.Fatal("empty tree yielded a key")
+	}
+}
+
+func TestTreeFrom(t *testing.T) {
+	keys, values := randomItems(18, 500)
+	tree := buildTree(t, keys, values)
+	sorted := sortedKeys(keys)
+	rng := rand.New(rand.NewSource(19))
+
+	var max proof.UrkelHash
+
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	starts := []proof.UrkelHash{{}, max, sorted[0], sorted[250], sorted[499]}
+
+	for i := 0; i < 50; i++ {
+		starts = append(starts, randomKey(rng))
+	}
+
+	for _, start := range starts {
+		var expect []proof.UrkelHash
+
+		for _, key := range sorted {
+			if bytes.Compare(key[:], start[:]) >= 0 {
+				expect = append(expect, key)
+			}
+		}
+
+		if !equalKeys(collect(t, tree, tree.From(start)), expect) {
+			t.Fatalf("iteration from %x mismatch", start)
+		}
+	}
+}
+
+func TestTreePrefix(t *testing.T) {
+	keys, values := randomItems(20, 500)
+	tree := buildTree(t, keys, values)
+	sorted := sortedKeys(keys)
+	rng := rand.New(rand.NewSource(21))
+
+	sizes := []int{0, 1, 3, 8, 12, 255, 256}
+
+	for _, size := range sizes {
+		for i := 0; i < 20; i++ {
+			// Half the prefixes are taken from keys in the tree.
+			key := randomKey(rng)
+
+			if i%2 == 0 {
+				key = keys[rng.Intn(len(keys))]
+			}
+
+			prefix := keyBits(key, 0, size)
+
+			var expect []proof.UrkelHash
+
+			for _, k := range sorted {
+				if prefix.Has(k, 0) {
+					expect = append(expect, k)
+				}
+			}
+
+			if !equalKeys(collect(t, tree, tree.Prefix(prefix)), expect) {
+				t.Fatalf("prefix %s mismatch", prefix.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package urkel
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("mmap not supported")
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package urkel
+
+import (
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// node is either *internalNode, *leafNode or *hashNode. A nil node is the
+// empty subtree and hashes to zero.
+type node interface {
+	hash() proof.UrkelHash
+}
+
+// ptr is the location of a node record in a store. It is set once the
+// node has been written.
+type ptr struct {
+	index uint16
+	pos   uint32
+	size  uint16
+}
+
+type internalNode struct {
+	prefix proof.Bits
+	left   node
+	right  node
+	h      proof.UrkelHash
+	ptr    *ptr
+}
+
+type leafNode struct {
+	key   proof.UrkelHash
+	value []byte
+	vhash proof.UrkelHash
+	h     proof.UrkelHash
+	ptr   *ptr
+}
+
+// hashNode is a stored subtree that has not been read yet.
+type hashNode struct {
+	h     proof.UrkelHash
+	ptr   ptr
+	store *store
+}
+
+func (n *internalNode) hash() proof.UrkelHash {
+	return n.h
+}
+
+func (n *leafNode) hash() proof.UrkelHash {
+	return n.h
+}
+
+func (n *hashNode) hash() proof.UrkelHash {
+	return n.h
+}
+
+// resolve reads n from its store if it is a hash node.
+func resolve(n node) (node, error) {
+	if hn, ok := n.(*hashNode); ok {
+		return hn.store.readNode(hn.ptr, hn.h)
+	}
+
+	return n, nil
+}
+
+func hashOf(n node) proof.UrkelHash {
+	if n == nil {
+		return proof.UrkelHash{}
+	}
+
+	return n.hash()
+}
+
+func newInternal(hasher proof.Hasher, prefix proof.Bits, left, right node) (*internalNode, error) {
+	h, err := proof.HashInternalWith(hasher, prefix, hashOf(left), hashOf(right))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &internalNode{
+		prefix: prefix,
+		left:   left,
+		right:  right,
+		h:      h,
+	}, nil
+}
+
+func newLeaf(hasher proof.Hasher, key proof.UrkelHash, value []byte) (*leafNode, error) {
+	vhash, err := proof.HashDataWith(hasher, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := proof.HashLeafWith(hasher, key, vhash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &leafNode{
+		key:   key,
+		value: append([]byte(nil), value...),
+		vhash: vhash,
+		h:     h,
+	}, nil
+}
+
+func (n *internalNode) child(bit int) node {
+	if bit == 1 {
+		return n.right
+	}
+
+	return n.left
+}
+
+// keyBits returns size bits of key starting at depth as a prefix.
+func keyBits(key proof.UrkelHash, depth int, size int) proof.Bits {
+	var bits proof.Bits
+
+	// Depths and sizes stay within the key, so this cannot fail.
+	bits.FromKey(key, depth, size)
+
+	return bits
+}
+
+// joinBits returns a followed by bit and then b.
+func joinBits(a proof.Bits, bit int, b proof.Bits) (proof.Bits, error) {
+	// A single bit is always a valid size.
+	branch, _ := proof.NewBitsFromSize(1)
+	branch.SetBit(0, bit)
+
+	bits, err := a.Join(*branch)
+
+	if err != nil {
+		return bits, err
+	}
+
+	return bits.Join(b)
+}
+
+func getBit(key proof.UrkelHash, index int) int {
+	return int(key[index>>3]>>(7-(index&7))) & 1
+}
+
+// commonBits counts the bits a and b share starting at depth.
+func commonBits(a, b proof.UrkelHash, depth int) int {
+	count := 0
+
+	for i := depth; i < proof.UrkelKeyBits; i++ {
+		if getBit(a, i) != getBit(b, i) {
+			break
+		}
+
+		count++
+	}
+
+	return count
+}
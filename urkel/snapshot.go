@@ -0,0 +1,81 @@
+package urkel
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+var ErrSnapshotClosed = errors.New("snapshot closed")
+
+// Snapshot is a read-only view of the tree at one root. It stays valid
+// while the tree is written to and is safe for concurrent use.
+//
+// A snapshot of a stored tree keeps the data files it reads from open,
+// even after Compact replaces them, until it is closed.
+type Snapshot struct {
+	mu     sync.RWMutex
+	tree   *Tree
+	root   node
+	epoch  *epoch
+	closed bool
+}
+
+// Snapshot returns a view of the tree at root, which must be a root the
+// tree has had.
+func (t *Tree) Snapshot(root proof.UrkelHash) (*Snapshot, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n, err := t.getRoot(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{tree: t, root: n, epoch: t.hold()}, nil
+}
+
+func (s *Snapshot) RootHash() proof.UrkelHash {
+	return hashOf(s.root)
+}
+
+func (s *Snapshot) Get(key proof.UrkelHash) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrSnapshotClosed
+	}
+
+	return get(s.root, key)
+}
+
+// Prove creates an inclusion or exclusion proof for key at the snapshot's
+// root.
+func (s *Snapshot) Prove(key proof.UrkelHash) (*proof.Proof, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrSnapshotClosed
+	}
+
+	return prove(s.root, key)
+}
+
+// Close releases the snapshot's hold on the tree's data files. Closing a
+// snapshot twice is a no-op.
+func (s *Snapshot) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	return s.tree.release(s.epoch)
+}
@@ -0,0 +1,1040 @@
+package urkel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/nodech/go-hsd-utils/proof"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// MaxFileSize is the size at which the store starts a new data file.
+	MaxFileSize = 0x7ffff000
+
+	metaMagic        = 0x6c6b7275
+	metaHistoryMagic = 0x686b7275
+	metaSize         = 4 + ptrSize + 1 + ptrSize + proof.UrkelHashSize + metaChecksumSize
+	ptrSize          = 2 + 4 + 2
+
+	metaChecksumSize = 32
+	metaKeySize      = 32
+	metaKeyFile      = "meta"
+
+	recordInternal byte = 1
+	recordLeaf     byte = 2
+
+	scanChunkSize = 1 << 20
+)
+
+// store keeps nodes in append-only data files named by their index. Each
+// commit appends the new nodes followed by a meta record that points to
+// the root and to the previous meta record. The meta record is checksummed
+// with a random key kept in the meta file, so values written to the tree
+// can never pass for one.
+//
+// On open the last data file is scanned back to the last valid meta
+// record and anything after it, left over from a torn write, is dropped.
+// History meta records, written by compaction for the older roots it
+// keeps, are part of the chain but never taken as the last root.
+type store struct {
+	dir string
+	key [metaKeySize]byte
+
+	mu    sync.RWMutex
+	files map[uint16]*os.File
+
+	// maps holds the read-only mappings of full data files when reads go
+	// through mmap.
+	mmap bool
+	maps map[uint16][]byte
+
+	cache *nodeCache
+
+	// index and pos are the current data file and its size. index is only
+	// changed with mu held, since reads check it.
+	index uint16
+	pos   int64
+
+	// meta is the location of the last meta record, nil if there is none.
+	meta *ptr
+
+	// epoch holds the data files in use since the last compaction.
+	epoch *epoch
+
+	maxFileSize int64
+
+	hasher proof.Hasher
+}
+
+// Options configures a stored tree.
+type Options struct {
+	// CacheSize is the number of internal nodes kept in the cache. Zero
+	// means DefaultCacheSize and a negative size disables the cache.
+	CacheSize int
+
+	// Mmap reads data files that are no longer written to through
+	// read-only memory maps. It is ignored where mmap is not supported.
+	Mmap bool
+
+	// Hasher hashes the tree's nodes. Nil means proof.DefaultHasher. A
+	// store must always be opened with the hasher it was written with.
+	Hasher proof.Hasher
+}
+
+// epoch is the set of data files one compaction replaces. Snapshots,
+// batches and reads in progress hold the epoch their nodes were read in,
+// and the files of a retired epoch are closed once the last one is done.
+type epoch struct {
+	refs    int
+	retired bool
+	files   []uint16
+}
+
+type metaRecord struct {
+	history  bool
+	prev     *ptr
+	root     *ptr
+	rootHash proof.UrkelHash
+}
+
+func filePath(dir string, index uint16) string {
+	return filepath.Join(dir, fmt.Sprintf("%010d", index))
+}
+
+// openStore opens or creates the store in dir and returns its last root.
+func openStore(dir string, opts *Options) (*store, node, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, err
+	}
+
+	s := &store{
+		dir:         dir,
+		files:       make(map[uint16]*os.File),
+		mmap:        opts.Mmap,
+		maps:        make(map[uint16][]byte),
+		epoch:       &epoch{},
+		maxFileSize: MaxFileSize,
+		hasher:      opts.Hasher,
+	}
+
+	if s.hasher == nil {
+		s.hasher = proof.DefaultHasher()
+	}
+
+	switch {
+	case opts.CacheSize == 0:
+		s.cache = newNodeCache(DefaultCacheSize)
+	case opts.CacheSize > 0:
+		s.cache = newNodeCache(opts.CacheSize)
+	}
+
+	if err := s.readKey(); err != nil {
+		return nil, nil, err
+	}
+
+	root, err := s.recover()
+
+	if err != nil {
+		s.close()
+		return nil, nil, err
+	}
+
+	return s, root, nil
+}
+
+// readKey reads the checksum key, creating it for a new store.
+func (s *store) readKey() error {
+	path := filepath.Join(s.dir, metaKeyFile)
+	data, err := os.ReadFile(path)
+
+	if err == nil {
+		if len(data) != metaKeySize {
+			return ErrCorruption
+		}
+
+		copy(s.key[:], data)
+
+		return nil
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, err := rand.Read(s.key[:]); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(s.key[:]); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// dataFiles returns the indexes of the data files in ascending order.
+func (s *store) dataFiles() ([]uint16, error) {
+	entries, err := os.ReadDir(s.dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []uint16
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if len(name) != 10 || entry.IsDir() {
+			continue
+		}
+
+		index, err := strconv.ParseUint(name, 10, 16)
+
+		if err != nil || index == 0 {
+			continue
+		}
+
+		indexes = append(indexes, uint16(index))
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i] < indexes[j]
+	})
+
+	return indexes, nil
+}
+
+// recover opens the data files, finds the last valid meta record and
+// drops everything written after it.
+func (s *store) recover() (node, error) {
+	indexes, err := s.dataFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(indexes) - 1; i >= 0; i-- {
+		index := indexes[i]
+		path := filePath(s.dir, index)
+		f, err := os.OpenFile(path, os.O_RDWR, 0o600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := f.Stat()
+
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		pos, meta, err := s.findMeta(f, info.Size())
+
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if meta == nil {
+			// Nothing in this file was ever committed.
+			f.Close()
+
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		end := pos + metaSize
+
+		if info.Size() > end {
+			if err := f.Truncate(end); err != nil {
+				f.Close()
+				return nil, err
+			}
+
+			if err := f.Sync(); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+
+		s.files[index] = f
+		s.index = index
+		s.pos = end
+		s.meta = &ptr{index: index, pos: uint32(pos), size: metaSize}
+
+		for _, prev := range indexes[:i] {
+			pf, err := os.OpenFile(filePath(s.dir, prev), os.O_RDWR, 0o600)
+
+			if err != nil {
+				return nil, err
+			}
+
+			s.files[prev] = pf
+		}
+
+		return s.rootNode(meta), nil
+	}
+
+	// An empty store.
+	if err := s.openFile(1); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// findMeta scans f back from size for the last valid meta record.
+func (s *store) findMeta(f *os.File, size int64) (int64, *metaRecord, error) {
+	end := size
+
+	for end >= metaSize {
+		start := end - scanChunkSize
+
+		if start < 0 {
+			start = 0
+		}
+
+		buf := make([]byte, end-start)
+
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+
+		for i := len(buf) - metaSize; i >= 0; i-- {
+			if meta := s.parseMeta(buf[i : i+metaSize]); meta != nil && !meta.history {
+				return start + int64(i), meta, nil
+			}
+		}
+
+		if start == 0 {
+			break
+		}
+
+		// Overlap the chunks so records across the boundary are seen.
+		end = start + metaSize - 1
+	}
+
+	return 0, nil, nil
+}
+
+func (s *store) checksum(data []byte) [metaChecksumSize]byte {
+	var sum [metaChecksumSize]byte
+
+	// The key size is valid, so this cannot fail.
+	h, _ := blake2b.New256(s.key[:])
+	h.Write(data)
+	copy(sum[:], h.Sum(nil))
+
+	return sum
+}
+
+func (s *store) parseMeta(data []byte) *metaRecord {
+	magic := binary.LittleEndian.Uint32(data)
+
+	if magic != metaMagic && magic != metaHistoryMagic {
+		return nil
+	}
+
+	body := data[:metaSize-metaChecksumSize]
+	sum := s.checksum(body)
+
+	if !bytes.Equal(sum[:], data[len(body):]) {
+		return nil
+	}
+
+	meta := &metaRecord{history: magic == metaHistoryMagic}
+
+	if p := decodePtr(data[4:]); p.size != 0 {
+		meta.prev = &p
+	}
+
+	if data[4+ptrSize] == 1 {
+		p := decodePtr(data[4+ptrSize+1:])
+		meta.root = &p
+	}
+
+	copy(meta.rootHash[:], data[4+2*ptrSize+1:])
+
+	return meta
+}
+
+func (s *store) encodeMeta(meta *metaRecord) []byte {
+	data := make([]byte, metaSize)
+
+	if meta.history {
+		binary.LittleEndian.PutUint32(data, metaHistoryMagic)
+	} else {
+		binary.LittleEndian.PutUint32(data, metaMagic)
+	}
+
+	if meta.prev != nil {
+		encodePtr(data[4:], *meta.prev)
+	}
+
+	if meta.root != nil {
+		data[4+ptrSize] = 1
+		encodePtr(data[4+ptrSize+1:], *meta.root)
+	}
+
+	copy(data[4+2*ptrSize+1:], meta.rootHash[:])
+
+	sum := s.checksum(data[:metaSize-metaChecksumSize])
+	copy(data[metaSize-metaChecksumSize:], sum[:])
+
+	return data
+}
+
+func (s *store) readMeta(p ptr) (*metaRecord, error) {
+	data, err := s.read(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) != metaSize {
+		return nil, ErrCorruption
+	}
+
+	meta := s.parseMeta(data)
+
+	if meta == nil {
+		return nil, ErrCorruption
+	}
+
+	return meta, nil
+}
+
+func (s *store) rootNode(meta *metaRecord) node {
+	if meta.root == nil {
+		return nil
+	}
+
+	return &hashNode{h: meta.rootHash, ptr: *meta.root, store: s}
+}
+
+// lookupRoot walks the meta records back to find an earlier root.
+func (s *store) lookupRoot(hash proof.UrkelHash) (node, error) {
+	s.mu.RLock()
+	next := s.meta
+	s.mu.RUnlock()
+
+	for next != nil {
+		meta, err := s.readMeta(*next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if meta.root != nil && meta.rootHash == hash {
+			return s.rootNode(meta), nil
+		}
+
+		next = meta.prev
+	}
+
+	return nil, ErrMissingRoot
+}
+
+func (s *store) openFile(index uint16) error {
+	f, err := os.OpenFile(filePath(s.dir, index), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.files[index] = f
+	s.index = index
+	s.mu.Unlock()
+
+	s.pos = 0
+
+	return nil
+}
+
+func (s *store) read(p ptr) ([]byte, error) {
+	s.mu.RLock()
+	f, ok := s.files[p.index]
+	m, mapped := s.maps[p.index]
+	current := p.index == s.index
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrCorruption
+	}
+
+	data := make([]byte, p.size)
+	end := int(p.pos) + int(p.size)
+
+	if s.mmap && !current && !mapped {
+		m = s.mapFile(p.index, f)
+		mapped = m != nil
+	}
+
+	if mapped {
+		if end > len(m) {
+			return nil, ErrCorruption
+		}
+
+		copy(data, m[p.pos:end])
+
+		return data, nil
+	}
+
+	if _, err := f.ReadAt(data, int64(p.pos)); err != nil {
+		if err == io.EOF {
+			return nil, ErrCorruption
+		}
+
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// mapFile maps a data file that is no longer written to. It returns nil
+// if the file cannot be mapped, and reads fall back to the file.
+func (s *store) mapFile(index uint16, f *os.File) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.maps[index]; ok {
+		return m
+	}
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return nil
+	}
+
+	m, err := mmapFile(f, info.Size())
+
+	if err != nil || m == nil {
+		return nil
+	}
+
+	s.maps[index] = m
+
+	return m
+}
+
+// acquire holds the current epoch until release.
+func (s *store) acquire() *epoch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epoch.refs++
+
+	return s.epoch
+}
+
+// release drops a hold on e, closing its files if it was the last one
+// on a retired epoch.
+func (s *store) release(e *epoch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.refs--
+
+	if e.refs > 0 || !e.retired {
+		return nil
+	}
+
+	return s.closeFiles(e.files)
+}
+
+// closeFiles unmaps and closes the given data files. It must be called
+// with mu held. Files already closed by close are skipped.
+func (s *store) closeFiles(indexes []uint16) error {
+	var err error
+
+	for _, index := range indexes {
+		if m, ok := s.maps[index]; ok {
+			if merr := munmap(m); merr != nil && err == nil {
+				err = merr
+			}
+
+			delete(s.maps, index)
+		}
+
+		if f, ok := s.files[index]; ok {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+
+			delete(s.files, index)
+		}
+	}
+
+	return err
+}
+
+// readNode reads the node at p and checks it against the expected hash.
+// Internal nodes are served from and added to the cache.
+func (s *store) readNode(p ptr, hash proof.UrkelHash) (node, error) {
+	if s.cache != nil {
+		if n := s.cache.get(p); n != nil && n.h == hash {
+			return n, nil
+		}
+	}
+
+	data, err := s.read(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.decodeNode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n.hash() != hash {
+		return nil, ErrCorruption
+	}
+
+	switch nn := n.(type) {
+	case *internalNode:
+		nn.ptr = &p
+
+		if s.cache != nil {
+			s.cache.put(p, nn)
+		}
+	case *leafNode:
+		nn.ptr = &p
+	}
+
+	return n, nil
+}
+
+func (s *store) stats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+
+	return s.cache.stats()
+}
+
+func (s *store) decodeNode(data []byte) (node, error) {
+	if len(data) == 0 {
+		return nil, ErrCorruption
+	}
+
+	r := bytes.NewReader(data[1:])
+
+	switch data[0] {
+	case recordInternal:
+		var prefix proof.Bits
+
+		if err := prefix.Deserialize(r); err != nil {
+			return nil, ErrCorruption
+		}
+
+		left, err := s.decodeChild(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := s.decodeChild(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newInternal(s.hasher, prefix, left, right)
+	case recordLeaf:
+		var key proof.UrkelHash
+		var size [2]byte
+
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return nil, ErrCorruption
+		}
+
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, ErrCorruption
+		}
+
+		value := make([]byte, binary.LittleEndian.Uint16(size[:]))
+
+		if len(value) > proof.UrkelValueSize {
+			return nil, ErrCorruption
+		}
+
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, ErrCorruption
+		}
+
+		return newLeaf(s.hasher, key, value)
+	}
+
+	return nil, ErrCorruption
+}
+
+func (s *store) decodeChild(r *bytes.Reader) (node, error) {
+	flag, err := r.ReadByte()
+
+	if err != nil {
+		return nil, ErrCorruption
+	}
+
+	if flag == 0 {
+		return nil, nil
+	}
+
+	var buf [ptrSize + proof.UrkelHashSize]byte
+
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, ErrCorruption
+	}
+
+	hn := &hashNode{ptr: decodePtr(buf[:]), store: s}
+	copy(hn.h[:], buf[ptrSize:])
+
+	return hn, nil
+}
+
+func encodeChild(buf *bytes.Buffer, n node) error {
+	if n == nil {
+		return buf.WriteByte(0)
+	}
+
+	p := ptrOf(n)
+
+	if p == nil {
+		return errors.New("child not written")
+	}
+
+	var data [ptrSize]byte
+	encodePtr(data[:], *p)
+
+	buf.WriteByte(1)
+	buf.Write(data[:])
+
+	h := n.hash()
+	buf.Write(h[:])
+
+	return nil
+}
+
+func ptrOf(n node) *ptr {
+	switch nn := n.(type) {
+	case *internalNode:
+		return nn.ptr
+	case *leafNode:
+		return nn.ptr
+	case *hashNode:
+		return &nn.ptr
+	}
+
+	return nil
+}
+
+func encodePtr(data []byte, p ptr) {
+	binary.LittleEndian.PutUint16(data, p.index)
+	binary.LittleEndian.PutUint32(data[2:], p.pos)
+	binary.LittleEndian.PutUint16(data[6:], p.size)
+}
+
+func decodePtr(data []byte) ptr {
+	return ptr{
+		index: binary.LittleEndian.Uint16(data),
+		pos:   binary.LittleEndian.Uint32(data[2:]),
+		size:  binary.LittleEndian.Uint16(data[6:]),
+	}
+}
+
+// writer appends records for one commit. Records are buffered per data
+// file and written when the file fills up or the commit ends.
+type writer struct {
+	s       *store
+	buf     bytes.Buffer
+	written []*ptr
+	nodes   []node
+}
+
+func (w *writer) append(record []byte) (ptr, error) {
+	s := w.s
+
+	if s.pos+int64(w.buf.Len()+len(record)) > s.maxFileSize && s.pos+int64(w.buf.Len()) > 0 {
+		if err := w.flush(); err != nil {
+			return ptr{}, err
+		}
+
+		if s.index == 0xffff {
+			return ptr{}, ErrFileLimit
+		}
+
+		if err := s.openFile(s.index + 1); err != nil {
+			return ptr{}, err
+		}
+	}
+
+	p := ptr{
+		index: s.index,
+		pos:   uint32(s.pos + int64(w.buf.Len())),
+		size:  uint16(len(record)),
+	}
+
+	w.buf.Write(record)
+
+	return p, nil
+}
+
+// flush writes the buffered records to the current data file.
+func (w *writer) flush() error {
+	s := w.s
+
+	s.mu.RLock()
+	f := s.files[s.index]
+	s.mu.RUnlock()
+
+	if _, err := f.WriteAt(w.buf.Bytes(), s.pos); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	s.pos += int64(w.buf.Len())
+	w.buf.Reset()
+
+	return nil
+}
+
+// write appends n and every unwritten node below it, children first.
+func (w *writer) write(n node) error {
+	switch nn := n.(type) {
+	case *internalNode:
+		if nn.ptr != nil {
+			return nil
+		}
+
+		if err := w.write(nn.left); err != nil {
+			return err
+		}
+
+		if err := w.write(nn.right); err != nil {
+			return err
+		}
+
+		p, err := w.appendNode(nn)
+
+		if err != nil {
+			return err
+		}
+
+		nn.ptr = &p
+		w.nodes = append(w.nodes, nn)
+	case *leafNode:
+		if nn.ptr != nil {
+			return nil
+		}
+
+		p, err := w.appendNode(nn)
+
+		if err != nil {
+			return err
+		}
+
+		nn.ptr = &p
+		w.nodes = append(w.nodes, nn)
+	}
+
+	return nil
+}
+
+// appendNode encodes a node whose children are written and appends it.
+func (w *writer) appendNode(n node) (ptr, error) {
+	var record bytes.Buffer
+
+	switch nn := n.(type) {
+	case *internalNode:
+		record.WriteByte(recordInternal)
+
+		if err := nn.prefix.Serialize(&record); err != nil {
+			return ptr{}, err
+		}
+
+		if err := encodeChild(&record, nn.left); err != nil {
+			return ptr{}, err
+		}
+
+		if err := encodeChild(&record, nn.right); err != nil {
+			return ptr{}, err
+		}
+	case *leafNode:
+		var size [2]byte
+		binary.LittleEndian.PutUint16(size[:], uint16(len(nn.value)))
+
+		record.WriteByte(recordLeaf)
+		record.Write(nn.key[:])
+		record.Write(size[:])
+		record.Write(nn.value)
+	default:
+		return ptr{}, ErrCorruption
+	}
+
+	return w.append(record.Bytes())
+}
+
+// rollback forgets the locations handed out by a failed commit and drops
+// whatever part of it reached the disk.
+func (w *writer) rollback(index uint16, pos int64) {
+	s := w.s
+
+	for _, n := range w.nodes {
+		switch nn := n.(type) {
+		case *internalNode:
+			nn.ptr = nil
+		case *leafNode:
+			nn.ptr = nil
+		}
+	}
+
+	s.mu.Lock()
+
+	for i, f := range s.files {
+		if i > index {
+			if m, ok := s.maps[i]; ok {
+				munmap(m)
+				delete(s.maps, i)
+			}
+
+			f.Close()
+			os.Remove(filePath(s.dir, i))
+			delete(s.files, i)
+		}
+	}
+
+	if f, ok := s.files[index]; ok {
+		f.Truncate(pos)
+	}
+
+	s.index = index
+	s.mu.Unlock()
+
+	s.pos = pos
+}
+
+// commit writes root and a meta record for it. It returns the root to
+// keep in memory, with its children replaced by hash nodes.
+func (s *store) commit(root node) (node, error) {
+	w := &writer{s: s}
+	index, pos := s.index, s.pos
+
+	err := w.write(root)
+
+	meta := &metaRecord{
+		prev:     s.meta,
+		root:     ptrOf(root),
+		rootHash: hashOf(root),
+	}
+
+	var mp ptr
+
+	if err == nil {
+		mp, err = w.append(s.encodeMeta(meta))
+	}
+
+	if err == nil {
+		err = w.flush()
+	}
+
+	if err != nil {
+		w.rollback(index, pos)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.meta = &mp
+	s.mu.Unlock()
+
+	if nn, ok := root.(*internalNode); ok {
+		return &internalNode{
+			prefix: nn.prefix,
+			left:   s.hashNodeOf(nn.left),
+			right:  s.hashNodeOf(nn.right),
+			h:      nn.h,
+			ptr:    nn.ptr,
+		}, nil
+	}
+
+	return root, nil
+}
+
+func (s *store) hashNodeOf(n node) node {
+	if n == nil {
+		return nil
+	}
+
+	if hn, ok := n.(*hashNode); ok {
+		return hn
+	}
+
+	return &hashNode{h: n.hash(), ptr: *ptrOf(n), store: s}
+}
+
+func (s *store) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+
+	for index, m := range s.maps {
+		if merr := munmap(m); merr != nil && err == nil {
+			err = merr
+		}
+
+		delete(s.maps, index)
+	}
+
+	for index, f := range s.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		delete(s.files, index)
+	}
+
+	return err
+}
@@ -0,0 +1,397 @@
+package urkel
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+type commitPoint struct {
+	root  proof.UrkelHash
+	index uint16
+	pos   int64
+	state map[proof.UrkelHash][]byte
+}
+
+func copyDir(t *testing.T, src string) string {
+	dst := t.TempDir()
+	entries, err := os.ReadDir(src)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		in, err := os.Open(filepath.Join(src, entry.Name()))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := os.Create(filepath.Join(dst, entry.Name()))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.Copy(out, in); err != nil {
+			t.Fatal(err)
+		}
+
+		in.Close()
+		out.Close()
+	}
+
+	return dst
+}
+
+func checkState(t *testing.T, tree *Tree, state map[proof.UrkelHash][]byte) {
+	t.Helper()
+
+	root := tree.RootHash()
+	count := 0
+
+	for entry, err := range tree.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(entry.Value, state[entry.Key]) {
+			t.Fatalf("value mismatch for %x", entry.Key)
+		}
+
+		count++
+	}
+
+	if count != len(state) {
+		t.Fatalf("tree has %d keys, expected %d", count, len(state))
+	}
+
+	for key := range state {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code, _ := p.Verify(root, key); code != proof.ProofOk {
+			t.Fatalf("proof for %x: %s", key, code)
+		}
+	}
+}
+
+// buildStore writes a store in small data files, committing in batches,
+// and records every commit.
+func buildStore(t *testing.T, dir string, seed int64) []commitPoint {
+	keys, values := randomItems(seed, 120)
+	rng := rand.New(rand.NewSource(seed))
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	tree.store.maxFileSize = 4096
+
+	state := make(map[proof.UrkelHash][]byte)
+	points := []commitPoint{{index: 1, pos: 0, state: map[proof.UrkelHash][]byte{}}}
+
+	for round := 0; round < 30; round++ {
+		batch := tree.Batch()
+
+		for i := 0; i < 8; i++ {
+			key := keys[rng.Intn(len(keys))]
+
+			if rng.Intn(4) == 0 {
+				if err := batch.Remove(key); err != nil {
+					t.Fatal(err)
+				}
+
+				delete(state, key)
+
+				continue
+			}
+
+			value := values[rng.Intn(len(values))]
+
+			if err := batch.Insert(key, value); err != nil {
+				t.Fatal(err)
+			}
+
+			state[key] = value
+		}
+
+		root, err := batch.Commit()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		copied := make(map[proof.UrkelHash][]byte, len(state))
+
+		for key, value := range state {
+			copied[key] = value
+		}
+
+		points = append(points, commitPoint{
+			root:  root,
+			index: tree.store.index,
+			pos:   tree.store.pos,
+			state: copied,
+		})
+	}
+
+	return points
+}
+
+func TestStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	points := buildStore(t, dir, 29)
+	last := points[len(points)-1]
+
+	if last.index < 2 {
+		t.Fatal("expected several data files")
+	}
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	if tree.RootHash() != last.root {
+		t.Fatal("root mismatch after reopen")
+	}
+
+	checkState(t, tree, last.state)
+
+	// Earlier roots stay readable.
+	for _, point := range points[1:] {
+		snap, err := tree.Snapshot(point.root)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range point.state {
+			got, err := snap.Get(key)
+
+			if err != nil || !bytes.Equal(got, value) {
+				t.Fatalf("snapshot value mismatch for %x", key)
+			}
+		}
+	}
+
+	changes, err := tree.Diff(points[1].root, last.root)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkDiff(t, changes, expectDiff(points[1].state, last.state))
+}
+
+func TestStoreSingleWrites(t *testing.T) {
+	dir := t.TempDir()
+	keys, values := randomItems(30, 50)
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range keys {
+		if err := tree.Insert(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range keys[:10] {
+		if err := tree.Remove(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := tree.RootHash()
+
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := buildTree(t, keys[10:], values[10:])
+
+	if root != expect.RootHash() {
+		t.Fatal("root mismatch with in-memory tree")
+	}
+
+	tree, err = Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	if tree.RootHash() != root {
+		t.Fatal("root mismatch after reopen")
+	}
+
+	state := make(map[proof.UrkelHash][]byte)
+
+	for i := 10; i < len(keys); i++ {
+		state[keys[i]] = values[i]
+	}
+
+	checkState(t, tree, state)
+}
+
+func TestStoreRecovery(t *testing.T) {
+	src := t.TempDir()
+	points := buildStore(t, src, 31)
+	last := points[len(points)-1]
+	rng := rand.New(rand.NewSource(32))
+
+	for trial := 0; trial < 40; trial++ {
+		dir := copyDir(t, src)
+
+		// Crash somewhere in a data file: it is cut short and no later
+		// file was written.
+		index := uint16(1 + rng.Intn(int(last.index)))
+		path := filePath(dir, index)
+		info, err := os.Stat(path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := rng.Int63n(info.Size() + 1)
+
+		if err := os.Truncate(path, size); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := index + 1; i <= last.index; i++ {
+			os.Remove(filePath(dir, i))
+		}
+
+		// Garbage from a torn write.
+		if trial%2 == 0 {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			junk := make([]byte, rng.Intn(200))
+			rng.Read(junk)
+			f.Write(junk)
+			f.Close()
+		}
+
+		expect := points[0]
+
+		for _, point := range points {
+			if point.index < index || (point.index == index && point.pos <= size) {
+				expect = point
+			}
+		}
+
+		tree, err := Open(dir, nil)
+
+		if err != nil {
+			t.Fatalf("trial %d: %v", trial, err)
+		}
+
+		if tree.RootHash() != expect.root {
+			t.Fatalf("trial %d: recovered the wrong root", trial)
+		}
+
+		checkState(t, tree, expect.state)
+
+		// The recovered store accepts new writes.
+		keys, values := randomItems(int64(trial), 1)
+
+		if err := tree.Insert(keys[0], values[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		root := tree.RootHash()
+		tree.Close()
+
+		if tree, err = Open(dir, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if tree.RootHash() != root {
+			t.Fatalf("trial %d: root mismatch after write", trial)
+		}
+
+		tree.Close()
+	}
+}
+
+func TestStoreCorruptNode(t *testing.T) {
+	dir := t.TempDir()
+	keys, values := randomItems(33, 20)
+
+	tree, err := Open(dir, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := tree.Batch()
+
+	for i := range keys {
+		batch.Insert(keys[i], values[i])
+	}
+
+	if _, err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tree.Close()
+
+	// Flip a byte in the first record, a leaf value.
+	path := filePath(dir, 1)
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[40] ^= 0xff
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree, err = Open(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	defer tree.Close()
+
+	failed := false
+
+	for _, key := range keys {
+		if _, err := tree.Get(key); err == ErrCorruption {
+			failed = true
+		}
+	}
+
+	if !failed {
+		t.Fatal("expected ErrCorruption")
+	}
+}
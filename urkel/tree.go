@@ -0,0 +1,518 @@
+package urkel
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+var (
+	ErrNotFound    = errors.New("key not found")
+	ErrValueSize   = errors.New("value too large")
+	ErrCorruption  = errors.New("tree corruption")
+	ErrMissingRoot = errors.New("missing root")
+	ErrFileLimit   = errors.New("too many data files")
+)
+
+// Tree is an in-memory base-2 merkelized radix tree, the same shape hsd
+// uses for its name tree. Nodes are never mutated, so every root stays a
+// valid, consistent view of the tree. Every root the tree has had is
+// kept and can be looked up by its hash.
+//
+// An in-memory tree therefore holds the nodes of all its past roots until
+// Prune or Compact drops them. Long-lived trees with many changes should
+// call one of them periodically.
+//
+// A tree created with New lives in memory. A tree opened with Open is
+// backed by a store on disk and reads nodes as they are needed.
+type Tree struct {
+	mu    sync.RWMutex
+	root  node
+	roots map[proof.UrkelHash]node
+	store *store
+
+	// order holds the hashes of roots in the order they were first
+	// reached, for Prune.
+	order []proof.UrkelHash
+
+	hasher proof.Hasher
+
+	// gen changes whenever Compact replaces the stored nodes.
+	gen uint64
+}
+
+func New() *Tree {
+	return NewWithHasher(proof.DefaultHasher())
+}
+
+// NewWithHasher creates an in-memory tree that hashes its nodes with
+// hasher. Its proofs verify with Proof.VerifyWith and the same hasher.
+func NewWithHasher(hasher proof.Hasher) *Tree {
+	return &Tree{
+		roots:  make(map[proof.UrkelHash]node),
+		hasher: hasher,
+	}
+}
+
+// Open opens the tree stored in dir, creating it if needed. Changes are
+// written to disk before Insert, Remove or a batch's Commit return. opts
+// may be nil for the defaults.
+func Open(dir string, opts *Options) (*Tree, error) {
+	s, root, err := openStore(dir, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree{
+		root:   root,
+		store:  s,
+		hasher: s.hasher,
+	}, nil
+}
+
+// Close closes the tree's store. It is a no-op for in-memory trees.
+func (t *Tree) Close() error {
+	if t.store == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.store.close()
+}
+
+// RootHash returns the hash of the current root.
+func (t *Tree) RootHash() proof.UrkelHash {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return hashOf(t.root)
+}
+
+func (t *Tree) Get(key proof.UrkelHash) ([]byte, error) {
+	root, e := t.acquire()
+	defer t.release(e)
+
+	return get(root, key)
+}
+
+func (t *Tree) Insert(key proof.UrkelHash, value []byte) error {
+	if len(value) > proof.UrkelValueSize {
+		return ErrValueSize
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := insert(t.hasher, t.root, key, value, 0)
+
+	if err != nil {
+		return err
+	}
+
+	return t.setRoot(root)
+}
+
+// Remove deletes key from the tree. Removing a missing key is a no-op.
+func (t *Tree) Remove(key proof.UrkelHash) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := remove(t.hasher, t.root, key, 0)
+
+	if err == ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return t.setRoot(root)
+}
+
+// CacheStats returns the node cache counters of a stored tree.
+func (t *Tree) CacheStats() CacheStats {
+	if t.store == nil {
+		return CacheStats{}
+	}
+
+	return t.store.stats()
+}
+
+// HasRoot reports whether hash is a root the tree has had.
+func (t *Tree) HasRoot(hash proof.UrkelHash) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	_, err := t.getRoot(hash)
+
+	return err == nil
+}
+
+// Prove creates an inclusion or exclusion proof for key.
+func (t *Tree) Prove(key proof.UrkelHash) (*proof.Proof, error) {
+	root, e := t.acquire()
+	defer t.release(e)
+
+	return prove(root, key)
+}
+
+func (t *Tree) setRoot(root node) error {
+	if t.store != nil {
+		root, err := t.store.commit(root)
+
+		if err != nil {
+			return err
+		}
+
+		t.root = root
+
+		return nil
+	}
+
+	t.root = root
+
+	if root == nil {
+		return nil
+	}
+
+	if _, ok := t.roots[root.hash()]; !ok {
+		t.order = append(t.order, root.hash())
+	}
+
+	t.roots[root.hash()] = root
+
+	return nil
+}
+
+// Prune drops all but the keep newest roots of an in-memory tree, along
+// with the nodes only they reference. Roots are ordered by when the tree
+// first reached them and the current root is always kept. Snapshots
+// already taken stay valid. Stored trees keep their roots on disk and
+// Prune is a no-op for them; use Compact instead.
+func (t *Tree) Prune(keep int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.store != nil {
+		return
+	}
+
+	hashes := append([]proof.UrkelHash(nil), t.order[max(len(t.order)-keep, 0):]...)
+
+	if t.root != nil {
+		hashes = append(hashes, t.root.hash())
+	}
+
+	t.keepRoots(hashes)
+}
+
+// keepRoots drops the in-memory roots not in hashes, which must all be
+// known.
+func (t *Tree) keepRoots(hashes []proof.UrkelHash) {
+	roots := make(map[proof.UrkelHash]node, len(hashes))
+
+	for _, hash := range hashes {
+		roots[hash] = t.roots[hash]
+	}
+
+	order := make([]proof.UrkelHash, 0, len(roots))
+
+	for _, hash := range t.order {
+		if _, ok := roots[hash]; ok {
+			order = append(order, hash)
+		}
+	}
+
+	t.roots = roots
+	t.order = order
+}
+
+// acquire returns the current root and holds the files it is read from
+// until release.
+func (t *Tree) acquire() (node, *epoch) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.root, t.hold()
+}
+
+// hold holds the current epoch of a stored tree until release. It must
+// be called with mu held, so the epoch matches the roots read with it.
+func (t *Tree) hold() *epoch {
+	if t.store == nil {
+		return nil
+	}
+
+	return t.store.acquire()
+}
+
+func (t *Tree) release(e *epoch) error {
+	if e == nil {
+		return nil
+	}
+
+	return t.store.release(e)
+}
+
+// getRoot returns the root node for hash. The empty root is always known.
+func (t *Tree) getRoot(hash proof.UrkelHash) (node, error) {
+	if hash == (proof.UrkelHash{}) {
+		return nil, nil
+	}
+
+	if t.store != nil {
+		if hash == hashOf(t.root) {
+			return t.root, nil
+		}
+
+		return t.store.lookupRoot(hash)
+	}
+
+	root, ok := t.roots[hash]
+
+	if !ok {
+		return nil, ErrMissingRoot
+	}
+
+	return root, nil
+}
+
+func get(n node, key proof.UrkelHash) ([]byte, error) {
+	var err error
+
+	depth := 0
+
+	for {
+		if n, err = resolve(n); err != nil {
+			return nil, err
+		}
+
+		switch nn := n.(type) {
+		case nil:
+			return nil, ErrNotFound
+		case *internalNode:
+			if !nn.prefix.Has(key, depth) {
+				return nil, ErrNotFound
+			}
+
+			depth += nn.prefix.Size()
+			n = nn.child(getBit(key, depth))
+			depth++
+		case *leafNode:
+			if nn.key != key {
+				return nil, ErrNotFound
+			}
+
+			return append([]byte(nil), nn.value...), nil
+		default:
+			return nil, ErrCorruption
+		}
+	}
+}
+
+func insert(hasher proof.Hasher, n node, key proof.UrkelHash, value []byte, depth int) (node, error) {
+	n, err := resolve(n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch nn := n.(type) {
+	case nil:
+		return newLeaf(hasher, key, value)
+	case *leafNode:
+		if nn.key == key {
+			return newLeaf(hasher, key, value)
+		}
+
+		bits := commonBits(nn.key, key, depth)
+		prefix := keyBits(key, depth, bits)
+		leaf, err := newLeaf(hasher, key, value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if getBit(key, depth+bits) == 1 {
+			return newInternal(hasher, prefix, nn, leaf)
+		}
+
+		return newInternal(hasher, prefix, leaf, nn)
+	case *internalNode:
+		prefix := nn.prefix
+		bits := prefix.Count(key, depth)
+
+		if bits == prefix.Size() {
+			depth += bits
+			bit := getBit(key, depth)
+
+			child, err := insert(hasher, nn.child(bit), key, value, depth+1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if bit == 1 {
+				return newInternal(hasher, prefix, nn.left, child)
+			}
+
+			return newInternal(hasher, prefix, child, nn.right)
+		}
+
+		// The key diverges inside the prefix: split it.
+		front, err := prefix.Slice(0, bits)
+
+		if err != nil {
+			return nil, err
+		}
+
+		back, err := prefix.Slice(bits+1, prefix.Size())
+
+		if err != nil {
+			return nil, err
+		}
+
+		old, err := newInternal(hasher, back, nn.left, nn.right)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf, err := newLeaf(hasher, key, value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if getBit(key, depth+bits) == 1 {
+			return newInternal(hasher, front, old, leaf)
+		}
+
+		return newInternal(hasher, front, leaf, old)
+	}
+
+	return nil, ErrCorruption
+}
+
+func remove(hasher proof.Hasher, n node, key proof.UrkelHash, depth int) (node, error) {
+	n, err := resolve(n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch nn := n.(type) {
+	case nil:
+		return nil, ErrNotFound
+	case *leafNode:
+		if nn.key != key {
+			return nil, ErrNotFound
+		}
+
+		return nil, nil
+	case *internalNode:
+		prefix := nn.prefix
+
+		if !prefix.Has(key, depth) {
+			return nil, ErrNotFound
+		}
+
+		depth += prefix.Size()
+		bit := getBit(key, depth)
+
+		child, err := remove(hasher, nn.child(bit), key, depth+1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if child == nil {
+			// Collapse: the sibling takes this node's place.
+			sibling, err := resolve(nn.child(bit ^ 1))
+
+			if err != nil {
+				return nil, err
+			}
+
+			if side, ok := sibling.(*internalNode); ok {
+				joined, err := joinBits(prefix, bit^1, side.prefix)
+
+				if err != nil {
+					return nil, err
+				}
+
+				return newInternal(hasher, joined, side.left, side.right)
+			}
+
+			return sibling, nil
+		}
+
+		if bit == 1 {
+			return newInternal(hasher, prefix, nn.left, child)
+		}
+
+		return newInternal(hasher, prefix, child, nn.right)
+	}
+
+	return nil, ErrCorruption
+}
+
+func prove(n node, key proof.UrkelHash) (*proof.Proof, error) {
+	type step struct {
+		prefix proof.Bits
+		hash   proof.UrkelHash
+	}
+
+	var steps []step
+	var result *proof.Proof
+	var err error
+
+	depth := 0
+
+	for result == nil {
+		if n, err = resolve(n); err != nil {
+			return nil, err
+		}
+
+		switch nn := n.(type) {
+		case nil:
+			result = proof.NewDeadEnd(depth)
+		case *internalNode:
+			prefix := nn.prefix
+
+			if !prefix.Has(key, depth) {
+				result = proof.NewShort(depth, prefix, hashOf(nn.left), hashOf(nn.right))
+				break
+			}
+
+			depth += prefix.Size()
+			bit := getBit(key, depth)
+
+			steps = append(steps, step{prefix, hashOf(nn.child(bit ^ 1))})
+
+			n = nn.child(bit)
+			depth++
+		case *leafNode:
+			if nn.key == key {
+				if result, err = proof.NewExists(depth, nn.value); err != nil {
+					return nil, err
+				}
+			} else {
+				result = proof.NewCollision(depth, nn.key, nn.vhash)
+			}
+		default:
+			return nil, ErrCorruption
+		}
+	}
+
+	for _, s := range steps {
+		result.Push(s.prefix, s.hash)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package urkel
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+func randomKey(rng *rand.Rand) proof.UrkelHash {
+	var key proof.UrkelHash
+	rng.Read(key[:])
+	return key
+}
+
+func randomItems(seed int64, count int) ([]proof.UrkelHash, [][]byte) {
+	rng := rand.New(rand.NewSource(seed))
+	keys := make([]proof.UrkelHash, count)
+	values := make([][]byte, count)
+
+	for i := range keys {
+		keys[i] = randomKey(rng)
+		values[i] = make([]byte, 1+rng.Intn(64))
+		rng.Read(values[i])
+	}
+
+	return keys, values
+}
+
+func buildTree(t *testing.T, keys []proof.UrkelHash, values [][]byte) *Tree {
+	tree := New()
+
+	for i, key := range keys {
+		if err := tree.Insert(key, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tree
+}
+
+func TestTreeInsertGet(t *testing.T) {
+	keys, values := randomItems(1, 500)
+	tree := buildTree(t, keys, values)
+
+	for i, key := range keys {
+		value, err := tree.Get(key)
+
+		if err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+
+		if !bytes.Equal(value, values[i]) {
+			t.Errorf("Value mismatch for %x", key)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(2))
+
+	if _, err := tree.Get(randomKey(rng)); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if tree.RootHash() == (proof.UrkelHash{}) {
+		t.Errorf("Root of non-empty tree is zero")
+	}
+}
+
+func TestTreeOrderIndependence(t *testing.T) {
+	keys, values := randomItems(3, 200)
+	tree := buildTree(t, keys, values)
+
+	// Radix trees are canonical: insertion order does not matter.
+	rkeys := make([]proof.UrkelHash, len(keys))
+	rvalues := make([][]byte, len(values))
+
+	for i := range keys {
+		rkeys[len(keys)-1-i] = keys[i]
+		rvalues[len(keys)-1-i] = values[i]
+	}
+
+	if buildTree(t, rkeys, rvalues).RootHash() != tree.RootHash() {
+		t.Errorf("Root depends on insertion order")
+	}
+}
+
+func TestTreeRemove(t *testing.T) {
+	keys, values := randomItems(4, 300)
+	tree := buildTree(t, keys, values)
+
+	for i := 0; i < len(keys); i += 2 {
+		if err := tree.Remove(keys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rkeys []proof.UrkelHash
+	var rvalues [][]byte
+
+	for i := 1; i < len(keys); i += 2 {
+		rkeys = append(rkeys, keys[i])
+		rvalues = append(rvalues, values[i])
+	}
+
+	if buildTree(t, rkeys, rvalues).RootHash() != tree.RootHash() {
+		t.Errorf("Root after removal differs from fresh tree")
+	}
+
+	for i := 1; i < len(keys); i += 2 {
+		if err := tree.Remove(keys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if tree.RootHash() != (proof.UrkelHash{}) {
+		t.Errorf("Empty tree root is not zero")
+	}
+}
+
+func TestTreeProve(t *testing.T) {
+	keys, values := randomItems(5, 300)
+	tree := buildTree(t, keys, values)
+	root := tree.RootHash()
+
+	for i, key := range keys {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Type() != proof.ProofTypeExists {
+			t.Errorf("Expected TYPE_EXISTS, got %s", p.Type())
+		}
+
+		code, value := p.Verify(root, key)
+
+		if code != proof.ProofOk {
+			t.Fatalf("Verify failed: %d", code)
+		}
+
+		if !bytes.Equal(value, values[i]) {
+			t.Errorf("Proof value mismatch")
+		}
+	}
+
+	types := make(map[proof.ProofType]int)
+	rng := rand.New(rand.NewSource(6))
+
+	for i := 0; i < 300; i++ {
+		key := randomKey(rng)
+
+		// Share a long prefix with an existing key to produce collisions.
+		if i%3 == 0 {
+			key = keys[i]
+			key[31] ^= 1
+		}
+
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		types[p.Type()]++
+
+		if code, _ := p.Verify(root, key); code != proof.ProofOk {
+			t.Fatalf("Verify of %s failed: %d", p.Type(), code)
+		}
+	}
+
+	if types[proof.ProofTypeCollision] == 0 {
+		t.Errorf("No collision proofs generated")
+	}
+
+	if types[proof.ProofTypeShort]+types[proof.ProofTypeDeadEnd] == 0 {
+		t.Errorf("No short or dead end proofs generated")
+	}
+
+	empty := New()
+	p, err := empty.Prove(keys[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := p.Verify(empty.RootHash(), keys[0]); code != proof.ProofOk || p.Type() != proof.ProofTypeDeadEnd {
+		t.Errorf("Empty tree proof failed: %d %s", code, p.Type())
+	}
+}
+
+func TestTreeDeepPrefix(t *testing.T) {
+	tree := New()
+	rng := rand.New(rand.NewSource(7))
+	base := randomKey(rng)
+
+	// Keys sharing 200 and 250 bits produce skip prefixes far past the
+	// first byte of the key.
+	keys := []proof.UrkelHash{base, base, base}
+	keys[1][25] ^= 0x80
+	keys[2][31] ^= 0x20
+
+	for i, key := range keys {
+		if err := tree.Insert(key, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	missing := base
+	missing[30] ^= 0x01
+
+	for i, key := range append(keys, missing) {
+		p, err := tree.Prove(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, value := p.Verify(tree.RootHash(), key)
+
+		if code != proof.ProofOk {
+			t.Errorf("Verify of %s failed: %d", p.Type(), code)
+		}
+
+		if i < len(keys) && (p.Type() != proof.ProofTypeExists || value[0] != byte(i)) {
+			t.Errorf("Expected TYPE_EXISTS for key %d, got %s", i, p.Type())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package wire
+
+const (
+	HashSize = 32
+
+	// HeaderSize is the size of the packet header: magic, type and size.
+	HeaderSize = 9
+
+	// MaxMessage is the maximum payload size of a single packet.
+	MaxMessage = 8 * 1000 * 1000
+
+	MaxInv       = 50000
+	MaxAddr      = 1000
+	MaxHeaders   = 2000
+	MaxAgentSize = 255
+
+	ProtocolVersion = 3
+)
+
+// Network magic values.
+const (
+	MagicMain    uint32 = 0x5b6ef2d3
+	MagicTestnet uint32 = 0x8efa1fbe
+	MagicRegtest uint32 = 0xbcf173aa
+	MagicSimnet  uint32 = 0x473bd012
+)
+
+type Hash [HashSize]byte
+type PacketType uint8
+type InvType uint32
+type RejectCode uint8
+
+// Packet types
+const (
+	PacketVersion PacketType = iota
+	PacketVerack
+	PacketPing
+	PacketPong
+	PacketGetAddr
+	PacketAddr
+	PacketInv
+	PacketGetData
+	PacketNotFound
+	PacketGetBlocks
+	PacketGetHeaders
+	PacketHeaders
+	PacketSendHeaders
+	PacketBlock
+	PacketTX
+	PacketReject
+	PacketMempool
+	PacketFilterLoad
+	PacketFilterAdd
+	PacketFilterClear
+	PacketMerkleBlock
+	PacketFeeFilter
+	PacketSendCmpct
+	PacketCmpctBlock
+	PacketGetBlockTxn
+	PacketBlockTxn
+	PacketGetProof
+	PacketProof
+	PacketClaim
+	PacketAirdrop
+	PacketUnknown
+)
+
+// Inventory types
+const (
+	InvTX InvType = iota + 1
+	InvBlock
+	InvFilteredBlock
+	InvCmpctBlock
+	InvClaim
+	InvAirdrop
+)
+
+// Reject codes
+const (
+	RejectMalformed       RejectCode = 0x01
+	RejectInvalid         RejectCode = 0x10
+	RejectObsolete        RejectCode = 0x11
+	RejectDuplicate       RejectCode = 0x12
+	RejectNonstandard     RejectCode = 0x40
+	RejectDust            RejectCode = 0x41
+	RejectInsufficientFee RejectCode = 0x42
+	RejectCheckpoint      RejectCode = 0x43
+)
+
+var packetTypeNames = [...]string{
+	"VERSION",
+	"VERACK",
+	"PING",
+	"PONG",
+	"GETADDR",
+	"ADDR",
+	"INV",
+	"GETDATA",
+	"NOTFOUND",
+	"GETBLOCKS",
+	"GETHEADERS",
+	"HEADERS",
+	"SENDHEADERS",
+	"BLOCK",
+	"TX",
+	"REJECT",
+	"MEMPOOL",
+	"FILTERLOAD",
+	"FILTERADD",
+	"FILTERCLEAR",
+	"MERKLEBLOCK",
+	"FEEFILTER",
+	"SENDCMPCT",
+	"CMPCTBLOCK",
+	"GETBLOCKTXN",
+	"BLOCKTXN",
+	"GETPROOF",
+	"PROOF",
+	"CLAIM",
+	"AIRDROP",
+	"UNKNOWN",
+}
+
+func (pt PacketType) String() string {
+	if int(pt) < len(packetTypeNames) {
+		return packetTypeNames[pt]
+	}
+
+	return "UNKNOWN"
+}
+
+func (c RejectCode) String() string {
+	switch c {
+	case RejectMalformed:
+		return "malformed"
+	case RejectInvalid:
+		return "invalid"
+	case RejectObsolete:
+		return "obsolete"
+	case RejectDuplicate:
+		return "duplicate"
+	case RejectNonstandard:
+		return "nonstandard"
+	case RejectDust:
+		return "dust"
+	case RejectInsufficientFee:
+		return "insufficientfee"
+	case RejectCheckpoint:
+		return "checkpoint"
+	}
+
+	return "unknown"
+}
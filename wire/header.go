@@ -0,0 +1,283 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	BlockHeaderSize = 236
+	ExtraNonceSize  = 24
+)
+
+// BlockHeader is a Handshake block header.
+type BlockHeader struct {
+	Nonce        uint32
+	Time         uint64
+	PrevBlock    Hash
+	TreeRoot     Hash
+	ExtraNonce   [ExtraNonceSize]byte
+	ReservedRoot Hash
+	WitnessRoot  Hash
+	MerkleRoot   Hash
+	Version      uint32
+	Bits         uint32
+	Mask         Hash
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+func NewHashFromString(s string) (Hash, error) {
+	var hash Hash
+
+	if len(s) != HashSize*2 {
+		return hash, errors.New("invalid hash length")
+	}
+
+	if _, err := hex.Decode(hash[:], []byte(s)); err != nil {
+		return hash, err
+	}
+
+	return hash, nil
+}
+
+func (bh *BlockHeader) Serialize(w io.Writer) error {
+	if err := writeUint32(w, bh.Nonce); err != nil {
+		return err
+	}
+
+	if err := writeUint64(w, bh.Time); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, bh.PrevBlock[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, bh.TreeRoot[:]); err != nil {
+		return err
+	}
+
+	if err := bh.writeSubHead(w); err != nil {
+		return err
+	}
+
+	return writeBytes(w, bh.Mask[:])
+}
+
+func (bh *BlockHeader) Deserialize(r io.Reader) error {
+	var err error
+
+	if bh.Nonce, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if bh.Time, err = readUint64(r); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.PrevBlock[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.TreeRoot[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.ExtraNonce[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.ReservedRoot[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.WitnessRoot[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, bh.MerkleRoot[:]); err != nil {
+		return err
+	}
+
+	if bh.Version, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if bh.Bits, err = readUint32(r); err != nil {
+		return err
+	}
+
+	return readBytes(r, bh.Mask[:])
+}
+
+func (bh *BlockHeader) writeSubHead(w io.Writer) error {
+	if err := writeBytes(w, bh.ExtraNonce[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, bh.ReservedRoot[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, bh.WitnessRoot[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, bh.MerkleRoot[:]); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, bh.Version); err != nil {
+		return err
+	}
+
+	return writeUint32(w, bh.Bits)
+}
+
+func (bh *BlockHeader) padding(size int) []byte {
+	pad := make([]byte, size)
+
+	for i := 0; i < size; i++ {
+		pad[i] = bh.PrevBlock[i%32] ^ bh.TreeRoot[i%32]
+	}
+
+	return pad
+}
+
+func (bh *BlockHeader) commitHash() []byte {
+	var sub bytes.Buffer
+
+	bh.writeSubHead(&sub)
+
+	subHash := blake2b.Sum256(sub.Bytes())
+	maskHash := blake2b.Sum256(append(bh.PrevBlock[:], bh.Mask[:]...))
+	commit := blake2b.Sum256(append(subHash[:], maskHash[:]...))
+
+	return commit[:]
+}
+
+func (bh *BlockHeader) preHead() []byte {
+	var buf bytes.Buffer
+
+	writeUint32(&buf, bh.Nonce)
+	writeUint64(&buf, bh.Time)
+	buf.Write(bh.padding(20))
+	buf.Write(bh.PrevBlock[:])
+	buf.Write(bh.TreeRoot[:])
+	buf.Write(bh.commitHash())
+
+	return buf.Bytes()
+}
+
+// Hash returns the proof-of-work hash, which is also the block hash.
+func (bh *BlockHeader) Hash() Hash {
+	var hash Hash
+
+	data := bh.preHead()
+	left := blake2b.Sum512(data)
+
+	sh := sha3.New256()
+	sh.Write(data)
+	sh.Write(bh.padding(8))
+	right := sh.Sum(nil)
+
+	h, _ := blake2b.New256(nil)
+	h.Write(left[:])
+	h.Write(bh.padding(32))
+	h.Write(right)
+	copy(hash[:], h.Sum(nil))
+
+	for i := 0; i < HashSize; i++ {
+		hash[i] ^= bh.Mask[i]
+	}
+
+	return hash
+}
+
+// VerifyPOW checks the header hash against its own compact target.
+func (bh *BlockHeader) VerifyPOW() bool {
+	target := CompactToBig(bh.Bits)
+
+	if target.Sign() <= 0 || target.BitLen() > 256 {
+		return false
+	}
+
+	hash := bh.Hash()
+
+	return new(big.Int).SetBytes(hash[:]).Cmp(target) <= 0
+}
+
+// CompactToBig expands a compact target representation.
+func CompactToBig(compact uint32) *big.Int {
+	exponent := uint(compact >> 24)
+	mantissa := int64(compact & 0x007fffff)
+	negative := compact&0x00800000 != 0
+
+	var target *big.Int
+
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		target = big.NewInt(mantissa)
+	} else {
+		target = big.NewInt(mantissa)
+		target.Lsh(target, 8*(exponent-3))
+	}
+
+	if negative && mantissa != 0 {
+		target.Neg(target)
+	}
+
+	return target
+}
+
+// BigToCompact encodes a target in compact form, the inverse of
+// CompactToBig up to the precision of the mantissa.
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() == 0 {
+		return 0
+	}
+
+	num := new(big.Int).Abs(target)
+	exponent := uint((num.BitLen() + 7) / 8)
+
+	var mantissa uint32
+
+	if exponent <= 3 {
+		mantissa = uint32(num.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(num.Rsh(num, 8*(exponent-3)).Uint64())
+	}
+
+	// The sign bit is not part of the mantissa.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	compact := uint32(exponent<<24) | mantissa
+
+	if target.Sign() < 0 {
+		compact |= 0x00800000
+	}
+
+	return compact
+}
+
+func NewBlockHeaderFromBytes(b []byte) (*BlockHeader, error) {
+	bh := &BlockHeader{}
+	err := bh.Deserialize(bytes.NewReader(b))
+	return bh, err
+}
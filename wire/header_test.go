@@ -0,0 +1,123 @@
+package wire
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func mainGenesis(t *testing.T) *BlockHeader {
+	merkleRoot, err := NewHashFromString("8e4c9756fef2ad10375f360e0560fcc7587eb5223ddf8cd7c7e06e60a1140b15")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	witnessRoot, err := NewHashFromString("1a2c60b9439206938f8d7823782abdb8b211a57431e9c9b6a6365d8d42893351")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &BlockHeader{
+		Time:        1580745078,
+		Bits:        0x1c00ffff,
+		MerkleRoot:  merkleRoot,
+		WitnessRoot: witnessRoot,
+	}
+}
+
+func TestHeaderHash(t *testing.T) {
+	header := mainGenesis(t)
+	expected := "5b6ef2d3c1f3cdcadfd9a030ba1811efdd17740f14e166489760741d075992e0"
+
+	if header.Hash().String() != expected {
+		t.Errorf("Genesis hash mismatch: %s != %s", header.Hash(), expected)
+	}
+}
+
+func TestHeaderReserialize(t *testing.T) {
+	header := mainGenesis(t)
+	header.Nonce = 0xdeadbeef
+	header.ExtraNonce[0] = 0x01
+	header.Mask[31] = 0xff
+
+	var buf bytes.Buffer
+
+	if err := header.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != BlockHeaderSize {
+		t.Errorf("Header size mismatch: %d != %d", buf.Len(), BlockHeaderSize)
+	}
+
+	decoded, err := NewBlockHeaderFromBytes(buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *decoded != *header {
+		t.Errorf("Header mismatch after reserialize")
+	}
+
+	if decoded.Hash() != header.Hash() {
+		t.Errorf("Hash mismatch after reserialize")
+	}
+}
+
+func TestCompactToBig(t *testing.T) {
+	vectors := []struct {
+		compact uint32
+		hex     string
+	}{
+		{0x1d00ffff, "ffff0000000000000000000000000000000000000000000000000000"},
+		{0x1c00ffff, "ffff00000000000000000000000000000000000000000000000000"},
+		{0x03123456, "123456"},
+		{0x02123456, "1234"},
+		{0x207fffff, "7fffff0000000000000000000000000000000000000000000000000000000000"},
+	}
+
+	for _, vec := range vectors {
+		target := CompactToBig(vec.compact)
+
+		if target.Text(16) != vec.hex {
+			t.Errorf("Target mismatch for %08x: %s != %s", vec.compact, target.Text(16), vec.hex)
+		}
+	}
+}
+
+func TestBigToCompact(t *testing.T) {
+	for _, compact := range []uint32{0x1d00ffff, 0x1c00ffff, 0x03123456, 0x207fffff, 0x1b0404cb, 0} {
+		if got := BigToCompact(CompactToBig(compact)); got != compact {
+			t.Errorf("Compact mismatch for %08x: %08x", compact, got)
+		}
+	}
+
+	// Mantissas are truncated and never carry the sign bit.
+	target, _ := new(big.Int).SetString("ffffffff", 16)
+
+	if got := BigToCompact(target); got != 0x0500ffff {
+		t.Errorf("Compact mismatch for ffffffff: %08x", got)
+	}
+}
+
+func TestVerifyPOW(t *testing.T) {
+	header := mainGenesis(t)
+
+	if header.VerifyPOW() {
+		t.Errorf("Genesis should not satisfy its own target")
+	}
+
+	// Regtest difficulty is satisfied by roughly half of all hashes.
+	header.Bits = 0x207fffff
+
+	for !header.VerifyPOW() {
+		header.Nonce++
+	}
+
+	if header.Nonce > 1000 {
+		t.Errorf("Unexpectedly high nonce: %d", header.Nonce)
+	}
+}
@@ -0,0 +1,123 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Packet is a single peer-to-peer message payload.
+type Packet interface {
+	Type() PacketType
+	Serialize(w io.Writer) error
+	Deserialize(r io.Reader) error
+}
+
+// NewPacket returns an empty packet for the given type. Types without a
+// dedicated implementation are returned as *UnknownPacket.
+func NewPacket(pt PacketType) Packet {
+	switch pt {
+	case PacketVersion:
+		return &VersionPacket{}
+	case PacketVerack:
+		return &VerackPacket{}
+	case PacketPing:
+		return &PingPacket{}
+	case PacketPong:
+		return &PongPacket{}
+	case PacketGetAddr:
+		return &GetAddrPacket{}
+	case PacketAddr:
+		return &AddrPacket{}
+	case PacketInv:
+		return &InvPacket{}
+	case PacketGetData:
+		return &GetDataPacket{}
+	case PacketNotFound:
+		return &NotFoundPacket{}
+	case PacketGetBlocks:
+		return &GetBlocksPacket{}
+	case PacketGetHeaders:
+		return &GetHeadersPacket{}
+	case PacketHeaders:
+		return &HeadersPacket{}
+	case PacketSendHeaders:
+		return &SendHeadersPacket{}
+	case PacketBlock:
+		return &BlockPacket{}
+	case PacketTX:
+		return &TXPacket{}
+	case PacketReject:
+		return &RejectPacket{}
+	case PacketGetProof:
+		return &GetProofPacket{}
+	case PacketProof:
+		return &ProofPacket{}
+	}
+
+	return &UnknownPacket{PType: pt}
+}
+
+// WriteMessage frames the packet with magic, type and size and writes it.
+func WriteMessage(w io.Writer, magic uint32, pkt Packet) error {
+	var payload bytes.Buffer
+
+	if err := pkt.Serialize(&payload); err != nil {
+		return err
+	}
+
+	if payload.Len() > MaxMessage {
+		return errors.New("packet too large")
+	}
+
+	var buf bytes.Buffer
+
+	buf.Grow(HeaderSize + payload.Len())
+	writeUint32(&buf, magic)
+	writeUint8(&buf, uint8(pkt.Type()))
+	writeUint32(&buf, uint32(payload.Len()))
+	buf.Write(payload.Bytes())
+
+	return writeBytes(w, buf.Bytes())
+}
+
+// ReadMessage reads a single framed packet, checking its magic.
+func ReadMessage(r io.Reader, magic uint32) (Packet, error) {
+	var header [HeaderSize]byte
+
+	if err := readBytes(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	hr := bytes.NewReader(header[:])
+	gotMagic, _ := readUint32(hr)
+	ptype, _ := readUint8(hr)
+	size, _ := readUint32(hr)
+
+	if gotMagic != magic {
+		return nil, errors.New("invalid magic")
+	}
+
+	if size > MaxMessage {
+		return nil, errors.New("packet too large")
+	}
+
+	payload := make([]byte, size)
+
+	if err := readBytes(r, payload); err != nil {
+		return nil, err
+	}
+
+	return DecodePacket(PacketType(ptype), payload)
+}
+
+// DecodePacket decodes an unframed payload of the given type.
+func DecodePacket(pt PacketType, payload []byte) (Packet, error) {
+	pkt := NewPacket(pt)
+
+	if err := pkt.Deserialize(bytes.NewReader(payload)); err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
@@ -0,0 +1,233 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+// TYPE_EXISTS proof from the README.
+const existsProofHex = "07c0060010b38bd3162de23fd4242511f3d96518b41fb5ceaba1b2604718e9ff4905decc96a725d93e5424af84461ac485543bd9fc84875ef8b9eccdaec714cecc03cea73bee019110c7f43813690f676307494281bb4d30e9b96334dc561ea506413827fb0180d8fcc02cfdf8f1ab87ddddf80d1ab24e2bbc73b8ccf2e48e0e5b45b0a68b2ae2cee75163e06169984dff42cbce4ce516eeda81135f4f2b41899df4a532bb718409daecd329756ed5e608f784903e4ec0570d8f30a1c9cfd7c6c3b8d997c47a57070068656c6c6f2032"
+
+func testPackets(t *testing.T) []Packet {
+	raw, err := hex.DecodeString(existsProofHex)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nameProof, err := proof.NewFromBytes(raw)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := Hash{0x01, 0x02, 0x03}
+	addr := NetAddress{
+		Time:     1700000000,
+		Services: 1,
+		IP:       net.ParseIP("127.0.0.1").To16(),
+		Port:     12038,
+		Key:      [PubKeySize]byte{0x02, 0xff},
+	}
+
+	tx := TX{
+		Version: 0,
+		Inputs: []*Input{{
+			Prevout:  Outpoint{Hash: hash, Index: 1},
+			Sequence: 0xffffffff,
+			Witness:  [][]byte{{0x01, 0x02}, {}},
+		}},
+		Outputs: []*Output{{
+			Value:    1000,
+			Address:  Address{Version: 0, Hash: make([]byte, 20)},
+			Covenant: Covenant{Type: 2, Items: [][]byte{hash[:], {0x00, 0x00, 0x00, 0x00}}},
+		}},
+		Locktime: 10,
+	}
+
+	return []Packet{
+		&VersionPacket{
+			Version:  ProtocolVersion,
+			Services: 1,
+			Time:     1700000000,
+			Remote:   addr,
+			Nonce:    [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+			Agent:    "/go-hsd-utils:0.0.0/",
+			Height:   100,
+			NoRelay:  true,
+		},
+		&VerackPacket{},
+		&PingPacket{Nonce: [8]byte{1}},
+		&PongPacket{Nonce: [8]byte{2}},
+		&GetAddrPacket{},
+		&AddrPacket{Items: []*NetAddress{&addr, &addr}},
+		&InvPacket{Items: []*InvItem{{Type: InvTX, Hash: hash}, {Type: InvBlock, Hash: hash}}},
+		&GetDataPacket{Items: []*InvItem{{Type: InvBlock, Hash: hash}}},
+		&NotFoundPacket{Items: []*InvItem{{Type: InvTX, Hash: hash}}},
+		&GetBlocksPacket{Locator: []Hash{hash, {}}, Stop: hash},
+		&GetHeadersPacket{Locator: []Hash{hash}},
+		&HeadersPacket{Items: []*BlockHeader{{Nonce: 1, Bits: 0x207fffff}, {Nonce: 2}}},
+		&SendHeadersPacket{},
+		&BlockPacket{Block: Block{Header: BlockHeader{Nonce: 3}, TXs: []*TX{&tx, &tx}}},
+		&TXPacket{TX: tx},
+		&RejectPacket{Message: PacketTX, Code: RejectInvalid, Reason: "bad-txns"},
+		&RejectPacket{Message: PacketBlock, Code: RejectDuplicate, Reason: "duplicate", Hash: &hash},
+		&GetProofPacket{Root: proof.UrkelHash{0xaa}, Key: proof.UrkelHash{0xbb}},
+		&ProofPacket{Root: proof.UrkelHash{0xaa}, Key: proof.UrkelHash{0xbb}, Proof: nameProof},
+		&UnknownPacket{PType: PacketMempool, Payload: []byte{}},
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	for _, pkt := range testPackets(t) {
+		var framed bytes.Buffer
+
+		if err := WriteMessage(&framed, MagicMain, pkt); err != nil {
+			t.Errorf("WriteMessage(%s) failed: %s", pkt.Type(), err)
+			continue
+		}
+
+		encoded := append([]byte(nil), framed.Bytes()...)
+		decoded, err := ReadMessage(&framed, MagicMain)
+
+		if err != nil {
+			t.Errorf("ReadMessage(%s) failed: %s", pkt.Type(), err)
+			continue
+		}
+
+		if decoded.Type() != pkt.Type() {
+			t.Errorf("Type mismatch: %s != %s", decoded.Type(), pkt.Type())
+		}
+
+		var reencoded bytes.Buffer
+
+		if err = WriteMessage(&reencoded, MagicMain, decoded); err != nil {
+			t.Errorf("WriteMessage(%s) failed: %s", pkt.Type(), err)
+		}
+
+		if !bytes.Equal(encoded, reencoded.Bytes()) {
+			t.Errorf("Reencode mismatch for %s", pkt.Type())
+		}
+
+		if pkt.Type() != PacketProof && !reflect.DeepEqual(decoded, pkt) {
+			t.Errorf("Decoded %s differs from original", pkt.Type())
+		}
+	}
+}
+
+func TestMessageHeader(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := WriteMessage(&buf, MagicMain, &PingPacket{Nonce: [8]byte{0xff}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d3f26e5b02080000" + "00ff00000000000000"
+
+	if hex.EncodeToString(buf.Bytes()) != expected {
+		t.Errorf("Frame mismatch: %x != %s", buf.Bytes(), expected)
+	}
+
+	if _, err := ReadMessage(bytes.NewReader(buf.Bytes()), MagicTestnet); err == nil {
+		t.Errorf("Expected magic mismatch error")
+	}
+
+	if _, err := ReadMessage(bytes.NewReader(buf.Bytes()[:12]), MagicMain); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected unexpected EOF, got %v", err)
+	}
+
+	oversized := []byte{0xd3, 0xf2, 0x6e, 0x5b, 0x02, 0xff, 0xff, 0xff, 0xff}
+
+	if _, err := ReadMessage(bytes.NewReader(oversized), MagicMain); err == nil {
+		t.Errorf("Expected oversized packet error")
+	}
+}
+
+// Payloads laid out as in hsd's packets.js: varint count, hashes, stop.
+func TestLocatorEncoding(t *testing.T) {
+	a := strings.Repeat("aa", HashSize)
+	b := strings.Repeat("bb", HashSize)
+	zero := strings.Repeat("00", HashSize)
+
+	hashA, _ := NewHashFromString(a)
+	hashB, _ := NewHashFromString(b)
+
+	for _, tc := range []struct {
+		pkt    Packet
+		expect string
+	}{
+		{&GetHeadersPacket{Locator: []Hash{hashA, hashB}}, "d3f26e5b" + "0a" + "61000000" + "02" + a + b + zero},
+		{&GetBlocksPacket{Locator: []Hash{hashA}, Stop: hashB}, "d3f26e5b" + "09" + "41000000" + "01" + a + b},
+		{&GetHeadersPacket{Locator: []Hash{}}, "d3f26e5b" + "0a" + "21000000" + "00" + zero},
+	} {
+		var buf bytes.Buffer
+
+		if err := WriteMessage(&buf, MagicMain, tc.pkt); err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(buf.Bytes()) != tc.expect {
+			t.Fatalf("%s mismatch:\n%x\n%s", tc.pkt.Type(), buf.Bytes(), tc.expect)
+		}
+
+		raw, _ := hex.DecodeString(tc.expect)
+		decoded, err := ReadMessage(bytes.NewReader(raw), MagicMain)
+
+		if err != nil {
+			t.Fatalf("%s: %s", tc.pkt.Type(), err)
+		}
+
+		if !reflect.DeepEqual(decoded, tc.pkt) {
+			t.Fatalf("%s decoded differently", tc.pkt.Type())
+		}
+	}
+
+	// hsd accepts locators up to MAX_INV hashes.
+	locator := &GetHeadersPacket{Locator: make([]Hash, MaxInv)}
+
+	if err := locator.Serialize(io.Discard); err != nil {
+		t.Fatalf("locator of %d hashes rejected: %s", MaxInv, err)
+	}
+
+	locator.Locator = append(locator.Locator, Hash{})
+
+	if err := locator.Serialize(io.Discard); err == nil {
+		t.Fatal("oversized locator accepted")
+	}
+}
+
+func TestProofPacketVerify(t *testing.T) {
+	root, _ := hex.DecodeString("4ec83628341d5b97a6259e069dc5d83af77446301fafcb393772e3e90829ec41")
+	key, _ := hex.DecodeString("76726046e2db690179b6be0e81348068b15c1323f5d02ed39b24842fb1947262")
+	raw, _ := hex.DecodeString(existsProofHex)
+
+	var payload bytes.Buffer
+
+	payload.Write(root)
+	payload.Write(key)
+	payload.Write(raw)
+
+	pkt, err := DecodePacket(PacketProof, payload.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofPacket := pkt.(*ProofPacket)
+	code, value := proofPacket.Proof.Verify(proofPacket.Root, proofPacket.Key)
+
+	if code != proof.ProofOk {
+		t.Errorf("Verify failed: %d", code)
+	}
+
+	if string(value) != "hello 2" {
+		t.Errorf("Value mismatch: %q", value)
+	}
+}
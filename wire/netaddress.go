@@ -0,0 +1,126 @@
+package wire
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+const (
+	NetAddressSize = 88
+	PubKeySize     = 33
+)
+
+// NetAddress is a peer address as advertised in version and addr packets.
+type NetAddress struct {
+	Time     uint64
+	Services uint32
+	IP       net.IP
+	Port     uint16
+	Key      [PubKeySize]byte
+}
+
+func (na *NetAddress) Serialize(w io.Writer) error {
+	var ip [16]byte
+	var reserved [20]byte
+
+	if na.IP != nil {
+		ip16 := na.IP.To16()
+
+		if ip16 == nil {
+			return errors.New("invalid ip address")
+		}
+
+		copy(ip[:], ip16)
+	}
+
+	if err := writeUint64(w, na.Time); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, na.Services); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, 0); err != nil {
+		return err
+	}
+
+	// Address type, only IP is supported.
+	if err := writeUint8(w, 0); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, ip[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, reserved[:]); err != nil {
+		return err
+	}
+
+	if err := writeUint16(w, na.Port); err != nil {
+		return err
+	}
+
+	return writeBytes(w, na.Key[:])
+}
+
+func (na *NetAddress) Deserialize(r io.Reader) error {
+	var ip [16]byte
+	var reserved [20]byte
+	var atype uint8
+	var err error
+
+	if na.Time, err = readUint64(r); err != nil {
+		return err
+	}
+
+	if na.Services, err = readUint32(r); err != nil {
+		return err
+	}
+
+	// High bits of services.
+	if _, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if atype, err = readUint8(r); err != nil {
+		return err
+	}
+
+	if atype != 0 {
+		return errors.New("unknown address type")
+	}
+
+	if err = readBytes(r, ip[:]); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, reserved[:]); err != nil {
+		return err
+	}
+
+	if na.Port, err = readUint16(r); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, na.Key[:]); err != nil {
+		return err
+	}
+
+	na.IP = net.IP(ip[:])
+
+	return nil
+}
+
+// HasKey returns true if the address advertises a brontide identity key.
+func (na *NetAddress) HasKey() bool {
+	for _, b := range na.Key {
+		if b != 0 {
+			return true
+		}
+	}
+
+	return false
+}
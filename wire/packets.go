@@ -0,0 +1,559 @@
+package wire
+
+import (
+	"errors"
+	"io"
+
+	"github.com/nodech/go-hsd-utils/proof"
+)
+
+type VersionPacket struct {
+	Version  uint32
+	Services uint32
+	Time     uint64
+	Remote   NetAddress
+	Nonce    [8]byte
+	Agent    string
+	Height   uint32
+	NoRelay  bool
+}
+
+type VerackPacket struct{}
+
+type PingPacket struct {
+	Nonce [8]byte
+}
+
+type PongPacket struct {
+	Nonce [8]byte
+}
+
+type GetAddrPacket struct{}
+
+type AddrPacket struct {
+	Items []*NetAddress
+}
+
+type InvItem struct {
+	Type InvType
+	Hash Hash
+}
+
+type InvPacket struct {
+	Items []*InvItem
+}
+
+type GetDataPacket struct {
+	Items []*InvItem
+}
+
+type NotFoundPacket struct {
+	Items []*InvItem
+}
+
+type GetBlocksPacket struct {
+	Locator []Hash
+	Stop    Hash
+}
+
+type GetHeadersPacket struct {
+	Locator []Hash
+	Stop    Hash
+}
+
+type HeadersPacket struct {
+	Items []*BlockHeader
+}
+
+type SendHeadersPacket struct{}
+
+type BlockPacket struct {
+	Block Block
+}
+
+type TXPacket struct {
+	TX TX
+}
+
+type RejectPacket struct {
+	Message PacketType
+	Code    RejectCode
+	Reason  string
+	Hash    *Hash
+}
+
+type GetProofPacket struct {
+	Root proof.UrkelHash
+	Key  proof.UrkelHash
+}
+
+type ProofPacket struct {
+	Root  proof.UrkelHash
+	Key   proof.UrkelHash
+	Proof *proof.Proof
+}
+
+// UnknownPacket carries the raw payload of a packet this package does not
+// decode.
+type UnknownPacket struct {
+	PType   PacketType
+	Payload []byte
+}
+
+func (p *VersionPacket) Type() PacketType     { return PacketVersion }
+func (p *VerackPacket) Type() PacketType      { return PacketVerack }
+func (p *PingPacket) Type() PacketType        { return PacketPing }
+func (p *PongPacket) Type() PacketType        { return PacketPong }
+func (p *GetAddrPacket) Type() PacketType     { return PacketGetAddr }
+func (p *AddrPacket) Type() PacketType        { return PacketAddr }
+func (p *InvPacket) Type() PacketType         { return PacketInv }
+func (p *GetDataPacket) Type() PacketType     { return PacketGetData }
+func (p *NotFoundPacket) Type() PacketType    { return PacketNotFound }
+func (p *GetBlocksPacket) Type() PacketType   { return PacketGetBlocks }
+func (p *GetHeadersPacket) Type() PacketType  { return PacketGetHeaders }
+func (p *HeadersPacket) Type() PacketType     { return PacketHeaders }
+func (p *SendHeadersPacket) Type() PacketType { return PacketSendHeaders }
+func (p *BlockPacket) Type() PacketType       { return PacketBlock }
+func (p *TXPacket) Type() PacketType          { return PacketTX }
+func (p *RejectPacket) Type() PacketType      { return PacketReject }
+func (p *GetProofPacket) Type() PacketType    { return PacketGetProof }
+func (p *ProofPacket) Type() PacketType       { return PacketProof }
+func (p *UnknownPacket) Type() PacketType     { return p.PType }
+
+func (p *VersionPacket) Serialize(w io.Writer) error {
+	if err := writeUint32(w, p.Version); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, p.Services); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, 0); err != nil {
+		return err
+	}
+
+	if err := writeUint64(w, p.Time); err != nil {
+		return err
+	}
+
+	if err := p.Remote.Serialize(w); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, p.Nonce[:]); err != nil {
+		return err
+	}
+
+	if err := writeString8(w, p.Agent); err != nil {
+		return err
+	}
+
+	if err := writeUint32(w, p.Height); err != nil {
+		return err
+	}
+
+	noRelay := uint8(0)
+
+	if p.NoRelay {
+		noRelay = 1
+	}
+
+	return writeUint8(w, noRelay)
+}
+
+func (p *VersionPacket) Deserialize(r io.Reader) error {
+	var noRelay uint8
+	var err error
+
+	if p.Version, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if p.Services, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if _, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if p.Time, err = readUint64(r); err != nil {
+		return err
+	}
+
+	if err = p.Remote.Deserialize(r); err != nil {
+		return err
+	}
+
+	if err = readBytes(r, p.Nonce[:]); err != nil {
+		return err
+	}
+
+	if p.Agent, err = readString8(r); err != nil {
+		return err
+	}
+
+	if p.Height, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if noRelay, err = readUint8(r); err != nil {
+		return err
+	}
+
+	p.NoRelay = noRelay == 1
+
+	return nil
+}
+
+func (p *VerackPacket) Serialize(w io.Writer) error        { return nil }
+func (p *VerackPacket) Deserialize(r io.Reader) error      { return nil }
+func (p *GetAddrPacket) Serialize(w io.Writer) error       { return nil }
+func (p *GetAddrPacket) Deserialize(r io.Reader) error     { return nil }
+func (p *SendHeadersPacket) Serialize(w io.Writer) error   { return nil }
+func (p *SendHeadersPacket) Deserialize(r io.Reader) error { return nil }
+
+func (p *PingPacket) Serialize(w io.Writer) error {
+	return writeBytes(w, p.Nonce[:])
+}
+
+func (p *PingPacket) Deserialize(r io.Reader) error {
+	return readBytes(r, p.Nonce[:])
+}
+
+func (p *PongPacket) Serialize(w io.Writer) error {
+	return writeBytes(w, p.Nonce[:])
+}
+
+func (p *PongPacket) Deserialize(r io.Reader) error {
+	return readBytes(r, p.Nonce[:])
+}
+
+func (p *AddrPacket) Serialize(w io.Writer) error {
+	if len(p.Items) > MaxAddr {
+		return errors.New("too many addresses")
+	}
+
+	if err := writeVarint(w, uint64(len(p.Items))); err != nil {
+		return err
+	}
+
+	for _, addr := range p.Items {
+		if err := addr.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *AddrPacket) Deserialize(r io.Reader) error {
+	count, err := readCount(r, MaxAddr)
+
+	if err != nil {
+		return err
+	}
+
+	p.Items = make([]*NetAddress, count)
+
+	for i := range p.Items {
+		addr := &NetAddress{}
+
+		if err = addr.Deserialize(r); err != nil {
+			return err
+		}
+
+		p.Items[i] = addr
+	}
+
+	return nil
+}
+
+func (p *InvPacket) Serialize(w io.Writer) error      { return writeInv(w, p.Items) }
+func (p *GetDataPacket) Serialize(w io.Writer) error  { return writeInv(w, p.Items) }
+func (p *NotFoundPacket) Serialize(w io.Writer) error { return writeInv(w, p.Items) }
+
+func (p *InvPacket) Deserialize(r io.Reader) (err error) {
+	p.Items, err = readInv(r)
+	return
+}
+
+func (p *GetDataPacket) Deserialize(r io.Reader) (err error) {
+	p.Items, err = readInv(r)
+	return
+}
+
+func (p *NotFoundPacket) Deserialize(r io.Reader) (err error) {
+	p.Items, err = readInv(r)
+	return
+}
+
+func (p *GetBlocksPacket) Serialize(w io.Writer) error {
+	return writeLocator(w, p.Locator, p.Stop)
+}
+
+func (p *GetBlocksPacket) Deserialize(r io.Reader) (err error) {
+	p.Locator, p.Stop, err = readLocator(r)
+	return
+}
+
+func (p *GetHeadersPacket) Serialize(w io.Writer) error {
+	return writeLocator(w, p.Locator, p.Stop)
+}
+
+func (p *GetHeadersPacket) Deserialize(r io.Reader) (err error) {
+	p.Locator, p.Stop, err = readLocator(r)
+	return
+}
+
+func (p *HeadersPacket) Serialize(w io.Writer) error {
+	if len(p.Items) > MaxHeaders {
+		return errors.New("too many headers")
+	}
+
+	if err := writeVarint(w, uint64(len(p.Items))); err != nil {
+		return err
+	}
+
+	for _, header := range p.Items {
+		if err := header.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *HeadersPacket) Deserialize(r io.Reader) error {
+	count, err := readCount(r, MaxHeaders)
+
+	if err != nil {
+		return err
+	}
+
+	p.Items = make([]*BlockHeader, count)
+
+	for i := range p.Items {
+		header := &BlockHeader{}
+
+		if err = header.Deserialize(r); err != nil {
+			return err
+		}
+
+		p.Items[i] = header
+	}
+
+	return nil
+}
+
+func (p *BlockPacket) Serialize(w io.Writer) error   { return p.Block.Serialize(w) }
+func (p *BlockPacket) Deserialize(r io.Reader) error { return p.Block.Deserialize(r) }
+func (p *TXPacket) Serialize(w io.Writer) error      { return p.TX.Serialize(w) }
+func (p *TXPacket) Deserialize(r io.Reader) error    { return p.TX.Deserialize(r) }
+
+func (p *RejectPacket) Serialize(w io.Writer) error {
+	if err := writeUint8(w, uint8(p.Message)); err != nil {
+		return err
+	}
+
+	if err := writeUint8(w, uint8(p.Code)); err != nil {
+		return err
+	}
+
+	if err := writeString8(w, p.Reason); err != nil {
+		return err
+	}
+
+	if p.Hash != nil {
+		return writeBytes(w, p.Hash[:])
+	}
+
+	return nil
+}
+
+func (p *RejectPacket) Deserialize(r io.Reader) error {
+	var message, code uint8
+	var err error
+
+	if message, err = readUint8(r); err != nil {
+		return err
+	}
+
+	if code, err = readUint8(r); err != nil {
+		return err
+	}
+
+	if p.Reason, err = readString8(r); err != nil {
+		return err
+	}
+
+	p.Message = PacketType(message)
+	p.Code = RejectCode(code)
+	p.Hash = nil
+
+	var hash Hash
+
+	err = readBytes(r, hash[:])
+
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.Hash = &hash
+
+	return nil
+}
+
+func (p *GetProofPacket) Serialize(w io.Writer) error {
+	if err := writeBytes(w, p.Root[:]); err != nil {
+		return err
+	}
+
+	return writeBytes(w, p.Key[:])
+}
+
+func (p *GetProofPacket) Deserialize(r io.Reader) error {
+	if err := readBytes(r, p.Root[:]); err != nil {
+		return err
+	}
+
+	return readBytes(r, p.Key[:])
+}
+
+func (p *ProofPacket) Serialize(w io.Writer) error {
+	if p.Proof == nil {
+		return errors.New("missing proof")
+	}
+
+	if err := writeBytes(w, p.Root[:]); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, p.Key[:]); err != nil {
+		return err
+	}
+
+	return p.Proof.Serialize(w)
+}
+
+func (p *ProofPacket) Deserialize(r io.Reader) error {
+	if err := readBytes(r, p.Root[:]); err != nil {
+		return err
+	}
+
+	if err := readBytes(r, p.Key[:]); err != nil {
+		return err
+	}
+
+	p.Proof = proof.New()
+
+	return p.Proof.Deserialize(r)
+}
+
+func (p *UnknownPacket) Serialize(w io.Writer) error {
+	return writeBytes(w, p.Payload)
+}
+
+func (p *UnknownPacket) Deserialize(r io.Reader) (err error) {
+	p.Payload, err = io.ReadAll(r)
+	return
+}
+
+func writeInv(w io.Writer, items []*InvItem) error {
+	if len(items) > MaxInv {
+		return errors.New("too many inv items")
+	}
+
+	if err := writeVarint(w, uint64(len(items))); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := writeUint32(w, uint32(item.Type)); err != nil {
+			return err
+		}
+
+		if err := writeBytes(w, item.Hash[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readInv(r io.Reader) ([]*InvItem, error) {
+	count, err := readCount(r, MaxInv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*InvItem, count)
+
+	for i := range items {
+		item := &InvItem{}
+		var itype uint32
+
+		if itype, err = readUint32(r); err != nil {
+			return nil, err
+		}
+
+		if err = readBytes(r, item.Hash[:]); err != nil {
+			return nil, err
+		}
+
+		item.Type = InvType(itype)
+		items[i] = item
+	}
+
+	return items, nil
+}
+
+// writeLocator writes hsd's getblocks and getheaders payload: varint
+// count, the locator hashes and the stop hash.
+func writeLocator(w io.Writer, locator []Hash, stop Hash) error {
+	if len(locator) > MaxInv {
+		return errors.New("locator too large")
+	}
+
+	if err := writeVarint(w, uint64(len(locator))); err != nil {
+		return err
+	}
+
+	for _, hash := range locator {
+		if err := writeBytes(w, hash[:]); err != nil {
+			return err
+		}
+	}
+
+	return writeBytes(w, stop[:])
+}
+
+func readLocator(r io.Reader) ([]Hash, Hash, error) {
+	var stop Hash
+
+	count, err := readCount(r, MaxInv)
+
+	if err != nil {
+		return nil, stop, err
+	}
+
+	locator := make([]Hash, count)
+
+	for i := range locator {
+		if err = readBytes(r, locator[i][:]); err != nil {
+			return nil, stop, err
+		}
+	}
+
+	if err = readBytes(r, stop[:]); err != nil {
+		return nil, stop, err
+	}
+
+	return locator, stop, nil
+}
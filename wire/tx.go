@@ -0,0 +1,323 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	MaxTXInputs      = 10000
+	MaxTXOutputs     = 10000
+	MaxBlockTXs      = 100000
+	MaxWitnessItems  = 10000
+	MaxCovenantItems = 255
+	MaxItemSize      = 10000
+	MaxAddressHash   = 40
+)
+
+type Outpoint struct {
+	Hash  Hash
+	Index uint32
+}
+
+type Input struct {
+	Prevout  Outpoint
+	Sequence uint32
+	Witness  [][]byte
+}
+
+type Address struct {
+	Version uint8
+	Hash    []byte
+}
+
+type Covenant struct {
+	Type  uint8
+	Items [][]byte
+}
+
+type Output struct {
+	Value    uint64
+	Address  Address
+	Covenant Covenant
+}
+
+// TX is a Handshake transaction, witnesses included.
+type TX struct {
+	Version  uint32
+	Inputs   []*Input
+	Outputs  []*Output
+	Locktime uint32
+}
+
+// Block is a full block: header followed by its transactions.
+type Block struct {
+	Header BlockHeader
+	TXs    []*TX
+}
+
+func (tx *TX) serializeBase(w io.Writer) error {
+	if err := writeUint32(w, tx.Version); err != nil {
+		return err
+	}
+
+	if err := writeVarint(w, uint64(len(tx.Inputs))); err != nil {
+		return err
+	}
+
+	for _, input := range tx.Inputs {
+		if err := writeBytes(w, input.Prevout.Hash[:]); err != nil {
+			return err
+		}
+
+		if err := writeUint32(w, input.Prevout.Index); err != nil {
+			return err
+		}
+
+		if err := writeUint32(w, input.Sequence); err != nil {
+			return err
+		}
+	}
+
+	if err := writeVarint(w, uint64(len(tx.Outputs))); err != nil {
+		return err
+	}
+
+	for _, output := range tx.Outputs {
+		if err := output.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return writeUint32(w, tx.Locktime)
+}
+
+func (tx *TX) Serialize(w io.Writer) error {
+	if err := tx.serializeBase(w); err != nil {
+		return err
+	}
+
+	for _, input := range tx.Inputs {
+		if err := writeItems(w, input.Witness); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *TX) Deserialize(r io.Reader) error {
+	var count int
+	var err error
+
+	if tx.Version, err = readUint32(r); err != nil {
+		return err
+	}
+
+	if count, err = readCount(r, MaxTXInputs); err != nil {
+		return err
+	}
+
+	tx.Inputs = make([]*Input, count)
+
+	for i := range tx.Inputs {
+		input := &Input{}
+
+		if err = readBytes(r, input.Prevout.Hash[:]); err != nil {
+			return err
+		}
+
+		if input.Prevout.Index, err = readUint32(r); err != nil {
+			return err
+		}
+
+		if input.Sequence, err = readUint32(r); err != nil {
+			return err
+		}
+
+		tx.Inputs[i] = input
+	}
+
+	if count, err = readCount(r, MaxTXOutputs); err != nil {
+		return err
+	}
+
+	tx.Outputs = make([]*Output, count)
+
+	for i := range tx.Outputs {
+		output := &Output{}
+
+		if err = output.Deserialize(r); err != nil {
+			return err
+		}
+
+		tx.Outputs[i] = output
+	}
+
+	if tx.Locktime, err = readUint32(r); err != nil {
+		return err
+	}
+
+	for _, input := range tx.Inputs {
+		if input.Witness, err = readItems(r, MaxWitnessItems); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Hash returns the transaction id, which does not commit to witnesses.
+func (tx *TX) Hash() Hash {
+	var buf bytes.Buffer
+
+	tx.serializeBase(&buf)
+
+	return Hash(blake2b.Sum256(buf.Bytes()))
+}
+
+func (o *Output) Serialize(w io.Writer) error {
+	if len(o.Address.Hash) > MaxAddressHash {
+		return errors.New("address hash too long")
+	}
+
+	if err := writeUint64(w, o.Value); err != nil {
+		return err
+	}
+
+	if err := writeUint8(w, o.Address.Version); err != nil {
+		return err
+	}
+
+	if err := writeUint8(w, uint8(len(o.Address.Hash))); err != nil {
+		return err
+	}
+
+	if err := writeBytes(w, o.Address.Hash); err != nil {
+		return err
+	}
+
+	if err := writeUint8(w, o.Covenant.Type); err != nil {
+		return err
+	}
+
+	return writeItems(w, o.Covenant.Items)
+}
+
+func (o *Output) Deserialize(r io.Reader) error {
+	var size uint8
+	var err error
+
+	if o.Value, err = readUint64(r); err != nil {
+		return err
+	}
+
+	if o.Address.Version, err = readUint8(r); err != nil {
+		return err
+	}
+
+	if size, err = readUint8(r); err != nil {
+		return err
+	}
+
+	if size > MaxAddressHash {
+		return errors.New("address hash too long")
+	}
+
+	o.Address.Hash = make([]byte, size)
+
+	if err = readBytes(r, o.Address.Hash); err != nil {
+		return err
+	}
+
+	if o.Covenant.Type, err = readUint8(r); err != nil {
+		return err
+	}
+
+	o.Covenant.Items, err = readItems(r, MaxCovenantItems)
+
+	return err
+}
+
+func (b *Block) Serialize(w io.Writer) error {
+	if err := b.Header.Serialize(w); err != nil {
+		return err
+	}
+
+	if err := writeVarint(w, uint64(len(b.TXs))); err != nil {
+		return err
+	}
+
+	for _, tx := range b.TXs {
+		if err := tx.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Block) Deserialize(r io.Reader) error {
+	if err := b.Header.Deserialize(r); err != nil {
+		return err
+	}
+
+	count, err := readCount(r, MaxBlockTXs)
+
+	if err != nil {
+		return err
+	}
+
+	b.TXs = make([]*TX, count)
+
+	for i := range b.TXs {
+		tx := &TX{}
+
+		if err = tx.Deserialize(r); err != nil {
+			return err
+		}
+
+		b.TXs[i] = tx
+	}
+
+	return nil
+}
+
+func (b *Block) Hash() Hash {
+	return b.Header.Hash()
+}
+
+func writeItems(w io.Writer, items [][]byte) error {
+	if err := writeVarint(w, uint64(len(items))); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := writeVarBytes(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readItems(r io.Reader, max int) ([][]byte, error) {
+	count, err := readCount(r, max)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([][]byte, count)
+
+	for i := range items {
+		if items[i], err = readVarBytes(r, MaxItemSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
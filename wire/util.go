@@ -0,0 +1,244 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+func writeBytes(w io.Writer, data []byte) error {
+	total := 0
+
+	for total < len(data) {
+		written, err := w.Write(data[total:])
+
+		if err != nil {
+			return err
+		}
+
+		total += written
+	}
+
+	return nil
+}
+
+func readBytes(r io.Reader, data []byte) error {
+	_, err := io.ReadFull(r, data)
+	return err
+}
+
+func writeUint8(w io.Writer, n uint8) error {
+	return writeBytes(w, []byte{n})
+}
+
+func readUint8(r io.Reader) (uint8, error) {
+	var buf [1]byte
+
+	if err := readBytes(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+func writeUint16(w io.Writer, n uint16) error {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], n)
+	return writeBytes(w, buf[:])
+}
+
+func readUint16(r io.Reader) (uint16, error) {
+	var buf [2]byte
+
+	if err := readBytes(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint16(buf[:]), nil
+}
+
+func writeUint32(w io.Writer, n uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], n)
+	return writeBytes(w, buf[:])
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+
+	if err := readBytes(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func writeUint64(w io.Writer, n uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	return writeBytes(w, buf[:])
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+	var buf [8]byte
+
+	if err := readBytes(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+func varintSize(n uint64) int {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= 0xffff:
+		return 3
+	case n <= 0xffffffff:
+		return 5
+	}
+
+	return 9
+}
+
+func writeVarint(w io.Writer, n uint64) error {
+	switch {
+	case n < 0xfd:
+		return writeUint8(w, uint8(n))
+	case n <= 0xffff:
+		if err := writeUint8(w, 0xfd); err != nil {
+			return err
+		}
+
+		return writeUint16(w, uint16(n))
+	case n <= 0xffffffff:
+		if err := writeUint8(w, 0xfe); err != nil {
+			return err
+		}
+
+		return writeUint32(w, uint32(n))
+	}
+
+	if err := writeUint8(w, 0xff); err != nil {
+		return err
+	}
+
+	return writeUint64(w, n)
+}
+
+func readVarint(r io.Reader) (uint64, error) {
+	prefix, err := readUint8(r)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+
+	switch prefix {
+	case 0xfd:
+		var v uint16
+
+		if v, err = readUint16(r); err != nil {
+			return 0, err
+		}
+
+		n = uint64(v)
+
+		if n < 0xfd {
+			return 0, errors.New("non-canonical varint")
+		}
+	case 0xfe:
+		var v uint32
+
+		if v, err = readUint32(r); err != nil {
+			return 0, err
+		}
+
+		n = uint64(v)
+
+		if n <= 0xffff {
+			return 0, errors.New("non-canonical varint")
+		}
+	case 0xff:
+		if n, err = readUint64(r); err != nil {
+			return 0, err
+		}
+
+		if n <= 0xffffffff {
+			return 0, errors.New("non-canonical varint")
+		}
+	default:
+		n = uint64(prefix)
+	}
+
+	return n, nil
+}
+
+// readCount reads a varint and rejects it if it exceeds max.
+func readCount(r io.Reader, max int) (int, error) {
+	n, err := readVarint(r)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if n > uint64(max) {
+		return 0, errors.New("count too large")
+	}
+
+	return int(n), nil
+}
+
+func writeVarBytes(w io.Writer, data []byte) error {
+	if err := writeVarint(w, uint64(len(data))); err != nil {
+		return err
+	}
+
+	return writeBytes(w, data)
+}
+
+func readVarBytes(r io.Reader, max int) ([]byte, error) {
+	size, err := readCount(r, max)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+
+	if err = readBytes(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func writeString8(w io.Writer, s string) error {
+	if len(s) > 0xff {
+		return errors.New("string too long")
+	}
+
+	if err := writeUint8(w, uint8(len(s))); err != nil {
+		return err
+	}
+
+	return writeBytes(w, []byte(s))
+}
+
+func readString8(r io.Reader) (string, error) {
+	size, err := readUint8(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, size)
+
+	if err = readBytes(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}